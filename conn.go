@@ -3,6 +3,7 @@ package sqlite3
 import (
 	"context"
 	"fmt"
+	"io"
 	"iter"
 	"math"
 	"math/rand"
@@ -35,6 +36,8 @@ type Conn struct {
 	commit     func() bool
 	rollback   func()
 	preupdate  func(PreUpdateData)
+	clock      Clock
+	rand       io.Reader
 
 	busy1st time.Time
 	busylst time.Time
@@ -76,6 +79,7 @@ func newConn(ctx context.Context, filename string, flags OpenFlag) (ret *Conn, _
 	}
 
 	c := &Conn{interrupt: ctx}
+	c.clock, c.rand = entropy(ctx)
 	c.wrp, err = createWrapper(ctx)
 	if err != nil {
 		return nil, err
@@ -320,6 +324,18 @@ func (c *Conn) ReleaseMemory() error {
 	return c.error(rc)
 }
 
+// Clock returns the clock used by the connection:
+// the one configured with [WithClock], or the system clock.
+func (c *Conn) Clock() Clock {
+	return c.clock
+}
+
+// Randomness returns the source of randomness used by the connection:
+// the one configured with [WithRandomness], or [crypto/rand.Reader].
+func (c *Conn) Randomness() io.Reader {
+	return c.rand
+}
+
 // GetInterrupt gets the context set with [Conn.SetInterrupt].
 func (c *Conn) GetInterrupt() context.Context {
 	return c.interrupt
@@ -124,7 +124,7 @@ func ExtensionInit[Env any, Mod ExtensionLibrary](db *Conn, init func(env Env) M
 	}
 
 	e := &extEnv{
-		env:        &env{db.wrp},
+		env:        &env{Wrapper: db.wrp, clock: db.clock, rand: db.rand},
 		memoryBase: memBase,
 		tableBase:  int32(tableBase),
 	}
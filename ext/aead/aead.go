@@ -0,0 +1,187 @@
+// Package aead provides authenticated encryption functions.
+//
+// Provided functions:
+//   - encrypt(plaintext, key [, aad]) (XChaCha20-Poly1305)
+//   - encrypt_aes_gcm(plaintext, key [, aad]) (AES-GCM)
+//   - decrypt(ciphertext, key [, aad])
+//
+// Ciphertexts are self-describing blobs:
+// decrypt detects the algorithm used to encrypt,
+// and returns text or a blob, matching the plaintext.
+// If the ciphertext, or the associated data (aad), was tampered with,
+// decrypt fails with an error.
+//
+// XChaCha20-Poly1305 keys must be 32 bytes;
+// AES-GCM keys must be 16, 24 or 32 bytes.
+// Keys can be passed as blobs,
+// or looked up by name through a Go callback (see [RegisterKeys]),
+// so that raw keys never appear in SQL text.
+package aead
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// KeyFunc looks up a key by name.
+type KeyFunc func(name string) ([]byte, error)
+
+// Register registers authenticated encryption functions
+// for a database connection.
+// Keys must be passed as blobs.
+func Register(db *sqlite3.Conn) error {
+	return RegisterKeys(db, nil)
+}
+
+// RegisterKeys registers authenticated encryption functions
+// for a database connection.
+// Keys passed as blobs are used as is;
+// keys passed as text are names, looked up with keys.
+func RegisterKeys(db *sqlite3.Conn, keys KeyFunc) error {
+	const flags = sqlite3.DIRECTONLY
+	enc := encrypt(keys, algXChaCha20Poly1305, "encrypt")
+	gcm := encrypt(keys, algAESGCM, "encrypt_aes_gcm")
+	dec := decrypt(keys)
+	return errors.Join(
+		db.CreateFunction("encrypt", 2, flags, enc),
+		db.CreateFunction("encrypt", 3, flags, enc),
+		db.CreateFunction("encrypt_aes_gcm", 2, flags, gcm),
+		db.CreateFunction("encrypt_aes_gcm", 3, flags, gcm),
+		db.CreateFunction("decrypt", 2, flags|sqlite3.DETERMINISTIC, dec),
+		db.CreateFunction("decrypt", 3, flags|sqlite3.DETERMINISTIC, dec))
+}
+
+// The first byte of a ciphertext identifies the algorithm,
+// and whether the plaintext is text.
+// It is followed by the nonce, and the sealed plaintext.
+const (
+	algXChaCha20Poly1305 = 1
+	algAESGCM            = 2
+	algMask              = 0x7f
+	flagText             = 0x80
+)
+
+func newAEAD(alg byte, key []byte) (cipher.AEAD, error) {
+	switch alg {
+	case algXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	case algAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	return nil, errutil.ErrorString("unknown algorithm")
+}
+
+func getKey(keys KeyFunc, arg sqlite3.Value) ([]byte, error) {
+	switch arg.Type() {
+	case sqlite3.BLOB:
+		return arg.RawBlob(), nil
+	case sqlite3.TEXT:
+		if keys != nil {
+			return keys(arg.Text())
+		}
+	}
+	return nil, errutil.ErrorString("key must be a blob")
+}
+
+func getAAD(arg []sqlite3.Value, header byte) []byte {
+	aad := []byte{header}
+	if len(arg) > 2 {
+		switch arg[2].Type() {
+		case sqlite3.BLOB:
+			aad = append(aad, arg[2].RawBlob()...)
+		case sqlite3.NULL:
+		default:
+			aad = append(aad, arg[2].RawText()...)
+		}
+	}
+	return aad
+}
+
+func encrypt(keys KeyFunc, alg byte, name string) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		var data []byte
+		header := alg
+		switch arg[0].Type() {
+		case sqlite3.NULL:
+			return
+		case sqlite3.BLOB:
+			data = arg[0].RawBlob()
+		default:
+			data = arg[0].RawText()
+			header |= flagText
+		}
+
+		key, err := getKey(keys, arg[1])
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		aead, err := newAEAD(alg, key)
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("%s: %w", name, err))
+			return
+		}
+
+		buf := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(data)+aead.Overhead())
+		buf[0] = header
+		nonce := buf[1:]
+		rand.Read(nonce)
+		ctx.ResultBlob(aead.Seal(buf, nonce, data, getAAD(arg, header)))
+	}
+}
+
+func decrypt(keys KeyFunc) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		if arg[0].Type() == sqlite3.NULL {
+			return
+		}
+		data := arg[0].RawBlob()
+		if len(data) == 0 {
+			ctx.ResultError(errutil.ErrorString("decrypt: invalid ciphertext"))
+			return
+		}
+
+		key, err := getKey(keys, arg[1])
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("decrypt: %w", err))
+			return
+		}
+		header := data[0]
+		aead, err := newAEAD(header&algMask, key)
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("decrypt: %w", err))
+			return
+		}
+
+		data = data[1:]
+		if len(data) < aead.NonceSize()+aead.Overhead() {
+			ctx.ResultError(errutil.ErrorString("decrypt: invalid ciphertext"))
+			return
+		}
+		nonce := data[:aead.NonceSize()]
+		data = data[aead.NonceSize():]
+
+		res, err := aead.Open(nil, nonce, data, getAAD(arg, header))
+		if err != nil {
+			ctx.ResultError(errutil.ErrorString("decrypt: message authentication failed"))
+			return
+		}
+		if header&flagText != 0 {
+			ctx.ResultRawText(res)
+		} else {
+			ctx.ResultBlob(res)
+		}
+	}
+}
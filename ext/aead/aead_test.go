@@ -0,0 +1,130 @@
+package aead_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/aead"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = aead.Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []string{
+		`decrypt(encrypt('hello', ?1), ?1)`,
+		`decrypt(encrypt('hello', ?1, 'aad'), ?1, 'aad')`,
+		`decrypt(encrypt_aes_gcm('hello', ?1), ?1)`,
+		`decrypt(encrypt_aes_gcm('hello', substr(?1, 1, 16), 'aad'), substr(?1, 1, 16), 'aad')`,
+		`CAST(decrypt(encrypt(CAST('hello' AS BLOB), ?1), ?1) AS TEXT)`,
+	}
+	for _, sql := range tests {
+		stmt, _, err := db.Prepare(`SELECT ` + sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmt.BindBlob(1, key)
+		if !stmt.Step() {
+			t.Fatal(sql, stmt.Err())
+		}
+		if got := stmt.ColumnText(0); got != "hello" {
+			t.Errorf("%s: got %q", sql, got)
+		}
+		stmt.Close()
+	}
+
+	stmt, _, err := db.Prepare(`SELECT typeof(decrypt(encrypt(?2, ?1), ?1)), encrypt(NULL, ?1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt.BindBlob(1, key)
+	stmt.BindBlob(2, []byte("hello"))
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "blob" {
+		t.Errorf("got %q, want blob", got)
+	}
+	if got := stmt.ColumnType(1); got != sqlite3.NULL {
+		t.Errorf("got %v, want NULL", got)
+	}
+	stmt.Close()
+
+	for _, sql := range []string{
+		`decrypt(encrypt('hello', ?1, 'aad'), ?1, 'dda')`,
+		`decrypt(encrypt('hello', ?1), zeroblob(32))`,
+		`decrypt(encrypt('hello', ?1, 'aad'), ?1)`,
+		`decrypt(X'01', ?1)`,
+		`decrypt(encrypt('hello', ?1) || X'00', ?1)`,
+		`decrypt(X'03' || substr(encrypt('hello', ?1), 2), ?1)`,
+		`decrypt(X'81' || substr(encrypt(CAST('hello' AS BLOB), ?1), 2), ?1)`,
+		`encrypt('hello', substr(?1, 1, 16))`,
+		`encrypt('hello', 'name')`,
+	} {
+		stmt, _, err := db.Prepare(`SELECT ` + sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmt.BindBlob(1, key)
+		if stmt.Step() {
+			t.Errorf("%s: want error", sql)
+		}
+		stmt.Close()
+	}
+}
+
+func TestRegisterKeys(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = aead.RegisterKeys(db, func(name string) ([]byte, error) {
+		if name == "secret" {
+			return bytes.Repeat([]byte{1}, 32), nil
+		}
+		return nil, errors.New("unknown key")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT decrypt(encrypt('hello', 'secret'), 'secret')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "hello" {
+		t.Errorf("got %q", got)
+	}
+	stmt.Close()
+
+	stmt, _, err = db.Prepare(`SELECT encrypt('hello', 'public')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.Step() {
+		t.Error("want error")
+	}
+	stmt.Close()
+}
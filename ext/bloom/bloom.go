@@ -3,14 +3,26 @@
 // A Bloom filter is a space-efficient probabilistic data structure
 // used to test whether an element is a member of a set.
 //
+// A counting Bloom filter replaces each bit with a 4-bit counter,
+// using 4 times the space, but allowing elements to be deleted.
+// Deleting an element that was never inserted
+// may cause false negatives.
+//
+// Filters can be exported as BLOBs, and probed without the virtual table:
+//
+//	SELECT bloom_export('sports_cars');
+//	SELECT bloom_contains(:filter, 'ferrari');
+//
 // https://github.com/nalgeon/sqlean/issues/27#issuecomment-1002267134
 package bloom
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"strconv"
+	"strings"
 
 	"github.com/dchest/siphash"
 
@@ -22,17 +34,26 @@ import (
 // Register registers the bloom_filter virtual table:
 //
 //	CREATE VIRTUAL TABLE foo USING bloom_filter(nElements, falseProb, kHashes)
+//	CREATE VIRTUAL TABLE foo USING bloom_filter(nElements, falseProb, type=counting)
+//
+// And the SQL functions bloom_export and bloom_contains.
 func Register(db *sqlite3.Conn) error {
-	return sqlite3.CreateModule(db, "bloom_filter", create, connect)
+	const flags = sqlite3.DETERMINISTIC | sqlite3.INNOCUOUS
+	return errors.Join(
+		sqlite3.CreateModule(db, "bloom_filter", create, connect),
+		db.CreateFunction("bloom_export", 1, sqlite3.DIRECTONLY, export),
+		db.CreateFunction("bloom_export", 2, sqlite3.DIRECTONLY, export),
+		db.CreateFunction("bloom_contains", 2, flags, contains))
 }
 
 type bloom struct {
-	db      *sqlite3.Conn
-	schema  string
-	storage string
-	prob    float64
-	bytes   int64
-	hashes  int
+	db       *sqlite3.Conn
+	schema   string
+	storage  string
+	prob     float64
+	bytes    int64 // m/8
+	hashes   int
+	counting bool
 }
 
 const vtab = `CREATE TABLE x(present, word TEXT HIDDEN NOT NULL PRIMARY KEY) WITHOUT ROWID`
@@ -44,6 +65,11 @@ func create(db *sqlite3.Conn, _, schema, table string, arg ...string) (_ *bloom,
 		storage: table + "_storage",
 	}
 
+	arg, err = b.options(arg)
+	if err != nil {
+		return nil, err
+	}
+
 	var nelem int64
 	if len(arg) > 0 {
 		nelem, err = strconv.ParseInt(arg[0], 10, 64)
@@ -80,6 +106,10 @@ func create(db *sqlite3.Conn, _, schema, table string, arg ...string) (_ *bloom,
 	}
 
 	b.bytes = numBytes(nelem, b.prob)
+	size := b.bytes
+	if b.counting {
+		size *= 4
+	}
 
 	err = db.DeclareVTab(vtab)
 	if err != nil {
@@ -100,7 +130,7 @@ func create(db *sqlite3.Conn, _, schema, table string, arg ...string) (_ *bloom,
 		`INSERT INTO %s.%s (rowid, data, p, n, m, k)
 		 VALUES (1, zeroblob(%d), %f, %d, %d, %d)`,
 		sqlite3.QuoteIdentifier(b.schema), sqlite3.QuoteIdentifier(b.storage),
-		b.bytes, b.prob, nelem, 8*b.bytes, b.hashes))
+		size, b.prob, nelem, 8*b.bytes, b.hashes))
 	if err != nil {
 		b.Destroy()
 		return nil, err
@@ -121,7 +151,7 @@ func connect(db *sqlite3.Conn, _, schema, table string, arg ...string) (_ *bloom
 	}
 
 	load, _, err := db.PrepareFlags(fmt.Sprintf(
-		`SELECT m/8, p, k FROM %s.%s WHERE rowid = 1`,
+		`SELECT m/8, p, k, length(data) = m/2 FROM %s.%s WHERE rowid = 1`,
 		sqlite3.QuoteIdentifier(b.schema), sqlite3.QuoteIdentifier(b.storage)),
 		sqlite3.PREPARE_DONT_LOG)
 	if err != nil {
@@ -139,9 +169,35 @@ func connect(db *sqlite3.Conn, _, schema, table string, arg ...string) (_ *bloom
 	b.bytes = load.ColumnInt64(0)
 	b.prob = load.ColumnFloat(1)
 	b.hashes = load.ColumnInt(2)
+	b.counting = load.ColumnBool(3)
 	return &b, nil
 }
 
+// options parses named arguments,
+// returning the positional ones.
+func (b *bloom) options(arg []string) ([]string, error) {
+	var pos []string
+	for _, arg := range arg {
+		if !strings.Contains(arg, "=") {
+			pos = append(pos, arg)
+			continue
+		}
+		key, val := sql3util.NamedArg(arg)
+		if key != "type" {
+			return nil, fmt.Errorf("bloom: unknown %q parameter", key)
+		}
+		switch t := sql3util.Unquote(val); strings.ToLower(t) {
+		case "bloom":
+			b.counting = false
+		case "counting":
+			b.counting = true
+		default:
+			return nil, fmt.Errorf("bloom: unknown filter type %q", t)
+		}
+	}
+	return pos, nil
+}
+
 func (b *bloom) Destroy() error {
 	return b.db.Exec(fmt.Sprintf(`DROP TABLE %s.%s`,
 		sqlite3.QuoteIdentifier(b.schema),
@@ -184,7 +240,7 @@ func (t *bloom) Integrity(schema, table string, flags int) error {
 	}
 	if m := load.ColumnInt64(4); m <= 0 || m%8 != 0 {
 		return err
-	} else if load.ColumnInt64(1) != m/8 {
+	} else if n := load.ColumnInt64(1); n != m/8 && n != m/2 {
 		return err
 	}
 	if p := load.ColumnFloat(2); p <= 0 || p >= 1 {
@@ -215,17 +271,25 @@ func (b *bloom) BestIndex(idx *sqlite3.IndexInfo) error {
 }
 
 func (b *bloom) Update(arg ...sqlite3.Value) (rowid int64, err error) {
+	del := false
 	if arg[0].Type() != sqlite3.NULL {
-		if len(arg) == 1 {
+		if len(arg) != 1 {
+			return 0, errutil.ErrorString("bloom: elements cannot be updated")
+		}
+		if !b.counting {
 			return 0, errutil.ErrorString("bloom: elements cannot be deleted")
 		}
-		return 0, errutil.ErrorString("bloom: elements cannot be updated")
+		del = true
+	} else if arg[2].NoChange() {
+		return 0, nil
 	}
 
-	if arg[2].NoChange() {
-		return 0, nil
+	var blob []byte
+	if del {
+		blob = arg[0].RawBlob()
+	} else {
+		blob = arg[2].RawBlob()
 	}
-	blob := arg[2].RawBlob()
 
 	f, err := b.db.OpenBlob(b.schema, b.storage, "data", 1, true)
 	if err != nil {
@@ -234,10 +298,7 @@ func (b *bloom) Update(arg ...sqlite3.Value) (rowid int64, err error) {
 	defer f.Close()
 
 	for n := range b.hashes {
-		hash := calcHash(n, blob)
-		hash %= uint64(b.bytes * 8)
-		bitpos := byte(hash % 8)
-		bytepos := int64(hash / 8)
+		bytepos, shift, mask := b.locate(calcHash(n, blob))
 
 		var buf [1]byte
 		_, err = f.Seek(bytepos, io.SeekStart)
@@ -249,7 +310,15 @@ func (b *bloom) Update(arg ...sqlite3.Value) (rowid int64, err error) {
 			return 0, err
 		}
 
-		buf[0] |= 1 << bitpos
+		// Saturated counters are never decremented.
+		switch v := buf[0] >> shift & mask; {
+		case del && v != 0 && v != mask:
+			buf[0] -= 1 << shift
+		case !del && v != mask:
+			buf[0] += 1 << shift
+		default:
+			continue
+		}
 
 		_, err = f.Seek(bytepos, io.SeekStart)
 		if err != nil {
@@ -285,10 +354,7 @@ func (c *cursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) error {
 	defer f.Close()
 
 	for n := 0; n < c.hashes && !c.eof; n++ {
-		hash := calcHash(n, blob)
-		hash %= uint64(c.bytes * 8)
-		bitpos := byte(hash % 8)
-		bytepos := int64(hash / 8)
+		bytepos, shift, mask := c.locate(calcHash(n, blob))
 
 		var buf [1]byte
 		_, err = f.Seek(bytepos, io.SeekStart)
@@ -300,7 +366,7 @@ func (c *cursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) error {
 			return err
 		}
 
-		c.eof = buf[0]&(1<<bitpos) == 0
+		c.eof = buf[0]>>shift&mask == 0
 	}
 	return nil
 }
@@ -332,6 +398,15 @@ func (c *cursor) RowID() (int64, error) {
 	return 0, nil
 }
 
+// locate returns the position of the bit, or counter, for a hash.
+func (b *bloom) locate(hash uint64) (bytepos int64, shift, mask byte) {
+	hash %= uint64(b.bytes * 8)
+	if b.counting {
+		return int64(hash / 2), byte(hash%2) * 4, 0xf
+	}
+	return int64(hash / 8), byte(hash % 8), 1
+}
+
 func calcHash(k int, b []byte) uint64 {
 	return siphash.Hash(^uint64(k), uint64(k), b)
 }
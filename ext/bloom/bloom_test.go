@@ -89,6 +89,124 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegister_counting(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE sports_cars USING bloom_filter(20, type=counting);
+		INSERT INTO sports_cars VALUES ('ferrari'), ('lamborghini'), ('alfa romeo');
+		DELETE FROM sports_cars WHERE word = 'lamborghini';
+		DELETE FROM sports_cars WHERE word = 'bmw';
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query, _, err := db.Prepare(`SELECT COUNT(*) FROM sports_cars(?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer query.Close()
+
+	for word, want := range map[string]bool{
+		"ferrari":     true,
+		"alfa romeo":  true,
+		"lamborghini": false,
+		"bmw":         false,
+	} {
+		err = query.BindText(1, word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !query.Step() {
+			t.Error("no rows")
+		}
+		if got := query.ColumnBool(0); got != want {
+			t.Errorf("%s: got %v, want %v", word, got, want)
+		}
+		err = query.Reset()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = db.Exec(`UPDATE sports_cars SET word = 'ferrari' WHERE word = 'alfa romeo'`)
+	if err == nil {
+		t.Error("want error")
+	}
+
+	err = db.Exec(`PRAGMA integrity_check`)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE plain USING bloom_filter(20);
+		CREATE VIRTUAL TABLE temp.counting USING bloom_filter(20, 0.01, type='counting');
+		INSERT INTO plain VALUES ('ferrari'), ('lamborghini');
+		INSERT INTO counting VALUES ('ferrari'), ('lamborghini');
+		DELETE FROM counting WHERE word = 'lamborghini';
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT bloom_contains(bloom_export('plain'), 'ferrari'),
+		       bloom_contains(bloom_export('plain'), 'lamborghini'),
+		       bloom_contains(bloom_export('plain'), 'bmw'),
+		       bloom_contains(bloom_export('counting', 'temp'), 'ferrari'),
+		       bloom_contains(bloom_export('counting', 'temp'), 'lamborghini'),
+		       length(bloom_export('plain')) = length(bloom_export('counting', 'temp'))
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	for i, want := range []bool{true, true, false, true, false, true} {
+		if got := stmt.ColumnBool(i); got != want {
+			t.Errorf("column %d: got %v, want %v", i, got, want)
+		}
+	}
+
+	err = db.Exec(`SELECT bloom_export('missing')`)
+	if err == nil {
+		t.Error("want error")
+	}
+	for _, filter := range []string{
+		`x'00'`,
+		`x'0100000001'`,   // no data
+		`x'010000000000'`, // no hashes
+		`x'01ffffffff00'`, // too many hashes
+		`x'020000000100'`, // unknown version
+	} {
+		err = db.Exec(`SELECT bloom_contains(` + filter + `, 'ferrari')`)
+		if err == nil {
+			t.Errorf("%s: want error", filter)
+		}
+	}
+}
+
 //go:embed testdata/bloom.db
 var testDB []byte
 
@@ -191,4 +309,13 @@ func Test_errors(t *testing.T) {
 	if err == nil {
 		t.Error("want error")
 	}
+
+	err = db.Exec(`CREATE VIRTUAL TABLE sports_cars USING bloom_filter(20, type=cuckoo)`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE sports_cars USING bloom_filter(20, size=10)`)
+	if err == nil {
+		t.Error("want error")
+	}
 }
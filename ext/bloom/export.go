@@ -0,0 +1,107 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// An exported filter is:
+//   - a version byte (1);
+//   - the number of hash functions, as a big-endian uint32;
+//   - the m/8 bytes of the filter.
+//
+// Counting filters are exported as plain Bloom filters,
+// with a bit set for each nonzero counter.
+const exportVersion = 1
+
+// The most hash functions create chooses, for any probability.
+var maxHashes = numHashes(math.SmallestNonzeroFloat64)
+
+// export implements bloom_export(table [, schema]).
+func export(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	storage := sqlite3.QuoteIdentifier(arg[0].Text() + "_storage")
+	if len(arg) > 1 {
+		storage = sqlite3.QuoteIdentifier(arg[1].Text()) + "." + storage
+	}
+
+	load, _, err := ctx.Conn().PrepareFlags(fmt.Sprintf(
+		`SELECT data, m, k FROM %s WHERE rowid = 1`, storage),
+		sqlite3.PREPARE_DONT_LOG)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("bloom_export: %w", err))
+		return
+	}
+	defer load.Close()
+
+	if !load.Step() {
+		if err := load.Err(); err != nil {
+			ctx.ResultError(fmt.Errorf("bloom_export: %w", err))
+		} else {
+			ctx.ResultError(sqlite3.CORRUPT_VTAB)
+		}
+		return
+	}
+
+	data := load.ColumnRawBlob(0)
+	m := load.ColumnInt64(1)
+	k := load.ColumnInt64(2)
+	if m <= 0 || m%8 != 0 || k <= 0 || k > int64(maxHashes) ||
+		int64(len(data)) != m/8 && int64(len(data)) != m/2 {
+		ctx.ResultError(errutil.ErrorString("bloom_export: invalid parameters"))
+		return
+	}
+
+	buf := make([]byte, 5, 5+m/8)
+	buf[0] = exportVersion
+	binary.BigEndian.PutUint32(buf[1:], uint32(k))
+
+	if int64(len(data)) == m/8 {
+		buf = append(buf, data...)
+	} else {
+		bits := make([]byte, m/8)
+		for i, b := range data {
+			if b&0x0f != 0 {
+				bits[i/4] |= 1 << (2 * (i % 4))
+			}
+			if b&0xf0 != 0 {
+				bits[i/4] |= 2 << (2 * (i % 4))
+			}
+		}
+		buf = append(buf, bits...)
+	}
+	ctx.ResultBlob(buf)
+}
+
+// contains implements bloom_contains(filter, word).
+func contains(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() == sqlite3.NULL || arg[1].Type() == sqlite3.NULL {
+		return
+	}
+
+	filter := arg[0].RawBlob()
+	if len(filter) < 5 || filter[0] != exportVersion {
+		ctx.ResultError(errutil.ErrorString("bloom_contains: invalid filter"))
+		return
+	}
+	hashes := binary.BigEndian.Uint32(filter[1:])
+	data := filter[5:]
+	if hashes == 0 || hashes > uint32(maxHashes) || len(data) == 0 {
+		ctx.ResultError(errutil.ErrorString("bloom_contains: invalid filter"))
+		return
+	}
+
+	b := bloom{bytes: int64(len(data))}
+	word := arg[1].RawBlob()
+	for n := range int(hashes) {
+		bytepos, shift, mask := b.locate(calcHash(n, word))
+		if data[bytepos]>>shift&mask == 0 {
+			ctx.ResultBool(false)
+			return
+		}
+	}
+	ctx.ResultBool(true)
+}
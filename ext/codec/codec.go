@@ -0,0 +1,48 @@
+// Package codec provides compression and encoding functions.
+//
+// Provided functions:
+//   - compress(data, method) (default method zlib)
+//   - uncompress(data, method) (default method zlib)
+//   - compress_agg(data, method) (default method zlib)
+//   - base16(data)
+//   - base32(data)
+//   - base32hex(data)
+//   - base85(data)
+//   - ascii85(data)
+//
+// Compression methods are zlib, gzip and deflate.
+// The compress_agg aggregate compresses the concatenation of its inputs
+// into a single stream; as a window function,
+// its frames must start at UNBOUNDED PRECEDING.
+//
+// Like the built-in base64 function,
+// encoding functions encode blobs into text,
+// and decode text into blobs.
+// The base16 function encodes lowercase hexadecimal
+// (unlike SQLite's hex), and decodes either case.
+// The base85 function uses the RFC 1924 alphabet,
+// ascii85 the Adobe (btoa) alphabet.
+package codec
+
+import (
+	"errors"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Register registers compression and encoding functions for a database connection.
+func Register(db *sqlite3.Conn) error {
+	const flags = sqlite3.DETERMINISTIC | sqlite3.INNOCUOUS
+	return errors.Join(
+		db.CreateFunction("compress", 1, flags, compress),
+		db.CreateFunction("compress", 2, flags, compress),
+		db.CreateFunction("uncompress", 1, flags, uncompress),
+		db.CreateFunction("uncompress", 2, flags, uncompress),
+		db.CreateWindowFunction("compress_agg", 1, flags, newCompressAgg),
+		db.CreateWindowFunction("compress_agg", 2, flags, newCompressAgg),
+		db.CreateFunction("base16", 1, flags, encoding(base16Codec)),
+		db.CreateFunction("base32", 1, flags, encoding(base32Std)),
+		db.CreateFunction("base32hex", 1, flags, encoding(base32Hex)),
+		db.CreateFunction("base85", 1, flags, encoding(base85Codec)),
+		db.CreateFunction("ascii85", 1, flags, encoding(ascii85Codec)))
+}
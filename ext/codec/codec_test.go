@@ -0,0 +1,113 @@
+package codec
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`base16(NULL)`, ""},
+		{`base16(CAST('hello world' AS BLOB))`, "68656c6c6f20776f726c64"},
+		{`CAST(base16('68656C6C6F20776F726C64') AS TEXT)`, "hello world"},
+		{`base32(CAST('hello' AS BLOB))`, "NBSWY3DP"},
+		{`CAST(base32('nbswy3dp') AS TEXT)`, "hello"},
+		{`base32hex(CAST('hello' AS BLOB))`, "D1IMOR3F"},
+		{`ascii85(CAST('hello world' AS BLOB))`, "BOu!rD]j7BEbo7"},
+		{`CAST(ascii85('<~BOu!rD]j7BEbo7~>') AS TEXT)`, "hello world"},
+		{`base85(CAST('hello world' AS BLOB))`, "Xk~0{Zy<MXa%^M"},
+		{`CAST(base85('Xk~0{Zy<MXa%^M') AS TEXT)`, "hello world"},
+		{`CAST(uncompress(compress('hello world')) AS TEXT)`, "hello world"},
+		{`CAST(uncompress(compress('hello world', 'gzip'), 'gzip') AS TEXT)`, "hello world"},
+		{`CAST(uncompress(compress('hello world', 'deflate'), 'deflate') AS TEXT)`, "hello world"},
+		{`length(compress(zeroblob(10000))) < 100`, "1"},
+		{`CAST(uncompress(compress_agg(value)) AS TEXT) FROM json_each('["a","b","c"]')`, "abc"},
+		{`CAST(uncompress(compress_agg(value, 'gzip'), 'gzip') AS TEXT) FROM json_each('["a","b","c"]')`, "abc"},
+		{`CAST(uncompress(compress_agg(value, 'deflate'), 'deflate') AS TEXT) FROM json_each('["a","b","c"]')`, "abc"},
+	}
+
+	for _, tt := range tests {
+		stmt, _, err := db.Prepare(`SELECT ` + tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		if got := stmt.ColumnText(0); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.sql, got, tt.want)
+		}
+		stmt.Close()
+	}
+
+	for _, sql := range []string{
+		`base85('a')`,
+		`base16('xyz')`,
+		`uncompress(X'0102')`,
+		`compress('a', 'lz4')`,
+	} {
+		stmt, _, err := db.Prepare(`SELECT ` + sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt.Step() {
+			t.Errorf("%s: want error", sql)
+		}
+		stmt.Close()
+	}
+}
+
+func TestCompressAgg_window(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"zlib", "gzip", "deflate"} {
+		stmt, _, err := db.Prepare(`
+			SELECT CAST(uncompress(compress_agg(value, ?1) OVER (ORDER BY key), ?1) AS TEXT)
+			FROM json_each('["a","b","c","d"]')`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmt.BindText(1, method)
+
+		var got []string
+		for stmt.Step() {
+			got = append(got, stmt.ColumnText(0))
+		}
+		if err := stmt.Close(); err != nil {
+			t.Fatal(method, err)
+		}
+		if want := []string{"a", "ab", "abc", "abcd"}; !slices.Equal(got, want) {
+			t.Errorf("%s: got %q, want %q", method, got, want)
+		}
+	}
+}
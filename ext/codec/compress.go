@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Same as SQLite's default SQLITE_MAX_LENGTH.
+const maxLength = 1e9
+
+func method(arg []sqlite3.Value) (string, error) {
+	if len(arg) < 2 {
+		return "zlib", nil
+	}
+	m := strings.ToLower(arg[1].Text())
+	switch m {
+	case "zlib", "gzip", "deflate":
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown compression method: %q", arg[1].Text())
+}
+
+func newWriter(w io.Writer, method string) io.WriteCloser {
+	switch method {
+	case "gzip":
+		return gzip.NewWriter(w)
+	case "deflate":
+		f, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return f
+	default:
+		return zlib.NewWriter(w)
+	}
+}
+
+func newReader(r io.Reader, method string) (io.ReadCloser, error) {
+	switch method {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return flate.NewReader(r), nil
+	default:
+		return zlib.NewReader(r)
+	}
+}
+
+func data(arg sqlite3.Value) []byte {
+	if arg.Type() == sqlite3.BLOB {
+		return arg.RawBlob()
+	}
+	return arg.RawText()
+}
+
+func compress(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() == sqlite3.NULL {
+		return
+	}
+	m, err := method(arg)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("compress: %w", err))
+		return
+	}
+
+	var buf bytes.Buffer
+	w := newWriter(&buf, m)
+	w.Write(data(arg[0]))
+	w.Close()
+	ctx.ResultBlob(buf.Bytes())
+}
+
+func uncompress(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() == sqlite3.NULL {
+		return
+	}
+	m, err := method(arg)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("uncompress: %w", err))
+		return
+	}
+
+	r, err := newReader(bytes.NewReader(data(arg[0])), m)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("uncompress: %w", err))
+		return
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(r, maxLength+1))
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("uncompress: %w", err))
+		return
+	}
+	if n > maxLength {
+		ctx.ResultError(sqlite3.TOOBIG)
+		return
+	}
+	ctx.ResultBlob(buf.Bytes())
+}
+
+func newCompressAgg() sqlite3.AggregateFunction {
+	return &compressAgg{}
+}
+
+// compressAgg writes a raw deflate stream, and frames it itself,
+// so that Value can be called repeatedly (e.g. as a window function):
+// each call flushes the writer, and terminates a copy of the stream.
+type compressAgg struct {
+	buf    bytes.Buffer
+	w      *flate.Writer
+	sum    hash.Hash32
+	size   uint32
+	method string
+	err    error
+}
+
+func (a *compressAgg) Step(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if a.err != nil || arg[0].Type() == sqlite3.NULL {
+		return
+	}
+	if a.w == nil {
+		m, err := method(arg)
+		if err != nil {
+			a.err = fmt.Errorf("compress_agg: %w", err)
+			return
+		}
+		switch m {
+		case "zlib":
+			a.buf.Write([]byte{0x78, 0x9c})
+			a.sum = adler32.New()
+		case "gzip":
+			a.buf.Write([]byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff})
+			a.sum = crc32.NewIEEE()
+		}
+		a.w, _ = flate.NewWriter(&a.buf, flate.DefaultCompression)
+		a.method = m
+	}
+	b := data(arg[0])
+	if a.sum != nil {
+		a.sum.Write(b)
+	}
+	a.size += uint32(len(b))
+	_, a.err = a.w.Write(b)
+	if a.buf.Len() > maxLength {
+		a.err = sqlite3.TOOBIG
+	}
+}
+
+func (a *compressAgg) Value(ctx sqlite3.Context) {
+	if a.err != nil {
+		ctx.ResultError(a.err)
+		return
+	}
+	if a.w == nil {
+		return
+	}
+	if err := a.w.Flush(); err != nil {
+		ctx.ResultError(fmt.Errorf("compress_agg: %w", err))
+		return
+	}
+
+	res := make([]byte, 0, a.buf.Len()+13)
+	res = append(res, a.buf.Bytes()...)
+	// A final, empty, stored block.
+	res = append(res, 1, 0, 0, 0xff, 0xff)
+	switch a.method {
+	case "zlib":
+		res = binary.BigEndian.AppendUint32(res, a.sum.Sum32())
+	case "gzip":
+		res = binary.LittleEndian.AppendUint32(res, a.sum.Sum32())
+		res = binary.LittleEndian.AppendUint32(res, a.size)
+	}
+	ctx.ResultBlob(res)
+}
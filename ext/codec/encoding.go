@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/ascii85"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+type codec struct {
+	name   string
+	encode func([]byte) []byte
+	decode func([]byte) ([]byte, error)
+}
+
+// encoding returns an SQL function that
+// encodes blobs into text, and decodes text into blobs.
+func encoding(c codec) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		switch a := arg[0]; a.Type() {
+		case sqlite3.NULL:
+
+		case sqlite3.BLOB:
+			ctx.ResultRawText(c.encode(a.RawBlob()))
+
+		case sqlite3.TEXT:
+			data := bytes.Trim(a.RawText(), " \t\n\v\f\r")
+			res, err := c.decode(data)
+			if err != nil {
+				ctx.ResultError(fmt.Errorf("%s: %w", c.name, err))
+				return
+			}
+			ctx.ResultBlob(res)
+
+		default:
+			ctx.ResultError(fmt.Errorf("%s: accepts only blob or text", c.name))
+		}
+	}
+}
+
+var base16Codec = codec{
+	name: "base16",
+	encode: func(src []byte) []byte {
+		return hex.AppendEncode(nil, src)
+	},
+	decode: func(src []byte) ([]byte, error) {
+		return hex.AppendDecode(nil, src)
+	},
+}
+
+var base32Std = base32Codec("base32", base32.StdEncoding)
+var base32Hex = base32Codec("base32hex", base32.HexEncoding)
+
+func base32Codec(name string, enc *base32.Encoding) codec {
+	return codec{
+		name: name,
+		encode: func(src []byte) []byte {
+			return enc.AppendEncode(nil, src)
+		},
+		decode: func(src []byte) ([]byte, error) {
+			return enc.AppendDecode(nil, bytes.ToUpper(src))
+		},
+	}
+}
+
+var ascii85Codec = codec{
+	name: "ascii85",
+	encode: func(src []byte) []byte {
+		dst := make([]byte, ascii85.MaxEncodedLen(len(src)))
+		return dst[:ascii85.Encode(dst, src)]
+	},
+	decode: func(src []byte) ([]byte, error) {
+		src, _ = bytes.CutPrefix(src, []byte("<~"))
+		src, _ = bytes.CutSuffix(src, []byte("~>"))
+		dst := make([]byte, 4*len(src)) // 'z' decodes to 4 bytes
+		n, _, err := ascii85.Decode(dst, src, true)
+		return dst[:n], err
+	},
+}
+
+// https://www.rfc-editor.org/rfc/rfc1924
+const base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+
+var base85Decode = func() (dec [256]byte) {
+	for i := range dec {
+		dec[i] = 0xff
+	}
+	for i := range len(base85Alphabet) {
+		dec[base85Alphabet[i]] = byte(i)
+	}
+	return dec
+}()
+
+// base85Codec encodes groups of 4 bytes into 5 characters;
+// a final group of n bytes is encoded into n+1 characters.
+var base85Codec = codec{
+	name: "base85",
+	encode: func(src []byte) []byte {
+		dst := make([]byte, 0, (len(src)*5+3)/4)
+		for len(src) > 0 {
+			var group [4]byte
+			n := copy(group[:], src)
+			src = src[n:]
+
+			v := uint32(group[0])<<24 | uint32(group[1])<<16 | uint32(group[2])<<8 | uint32(group[3])
+			var chars [5]byte
+			for i := 4; i >= 0; i-- {
+				chars[i] = base85Alphabet[v%85]
+				v /= 85
+			}
+			dst = append(dst, chars[:n+1]...)
+		}
+		return dst
+	},
+	decode: func(src []byte) ([]byte, error) {
+		dst := make([]byte, 0, len(src)*4/5)
+		for len(src) > 0 {
+			n := min(5, len(src))
+			if n == 1 {
+				return nil, fmt.Errorf("illegal data at input byte %d", len(dst)/4*5)
+			}
+
+			var v uint64
+			for i := range 5 {
+				d := byte(84) // pad
+				if i < n {
+					d = base85Decode[src[i]]
+					if d == 0xff {
+						return nil, fmt.Errorf("illegal data at input byte %d", len(dst)/4*5+i)
+					}
+				}
+				v = v*85 + uint64(d)
+			}
+			if v > 0xffffffff && n == 5 {
+				return nil, fmt.Errorf("illegal data at input byte %d", len(dst)/4*5)
+			}
+			src = src[n:]
+
+			group := [4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+			dst = append(dst, group[:n-1]...)
+		}
+		return dst, nil
+	},
+}
@@ -3,6 +3,7 @@ package csv
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ncruces/go-sqlite3/util/sql3util"
 )
@@ -33,3 +34,15 @@ func runeArg(key, val string) (rune, error) {
 	}
 	return r, nil
 }
+
+func quoteArg(key, val string) (Quoting, error) {
+	switch strings.ToLower(sql3util.Unquote(val)) {
+	case "minimal":
+		return QuoteMinimal, nil
+	case "all":
+		return QuoteAll, nil
+	case "nonnumeric":
+		return QuoteNonNumeric, nil
+	}
+	return 0, fmt.Errorf("csv: invalid %q parameter: %s", key, val)
+}
@@ -2,16 +2,22 @@
 //
 // The CSV virtual table reads RFC 4180 formatted comma-separated values,
 // and returns that content as if it were rows and columns of an SQL table.
+// Writable CSV virtual tables also append inserted rows to the file.
+//
+// [Export] writes the results of a query as CSV.
 //
 // https://sqlite.org/csv.html
 package csv
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"os"
 	"strconv"
 	"strings"
 
@@ -21,110 +27,154 @@ import (
 	"github.com/ncruces/go-sqlite3/util/sql3util"
 )
 
-// Register registers the CSV virtual table.
+// Register registers the CSV virtual table,
+// and the SQL function csv_export.
 // If a filename is specified, [os.Open] is used to open the file.
 func Register(db *sqlite3.Conn) error {
-	return RegisterFS(db, osutil.FS{})
+	return errors.Join(
+		RegisterFS(db, osutil.FS{}),
+		db.CreateFunction("csv_export", -1, sqlite3.DIRECTONLY, export))
 }
 
 // RegisterFS registers the CSV virtual table.
 // If a filename is specified, fsys is used to open the file.
+// Only tables registered with [Register] can be writable.
 func RegisterFS(db *sqlite3.Conn, fsys fs.FS) error {
-	declare := func(db *sqlite3.Conn, _, _, _ string, arg ...string) (_ *table, err error) {
-		var (
-			filename string
-			data     string
-			schema   string
-			header   bool
-			columns  int  = -1
-			comma    rune = ','
-			comment  rune
-
-			done = set[string]{}
-		)
-
-		for _, arg := range arg {
-			key, val := sql3util.NamedArg(arg)
-			if done.has(key) {
-				return nil, fmt.Errorf("csv: more than one %q parameter", key)
-			}
-			switch key {
-			case "filename":
-				filename = sql3util.Unquote(val)
-			case "data":
-				data = sql3util.Unquote(val)
-			case "schema":
-				schema = sql3util.Unquote(val)
-			case "header":
-				header, err = boolArg(key, val)
-			case "columns":
-				columns, err = uintArg(key, val)
-			case "comma":
-				comma, err = runeArg(key, val)
-			case "comment":
-				comment, err = runeArg(key, val)
-			default:
-				return nil, fmt.Errorf("csv: unknown %q parameter", key)
-			}
-			if err != nil {
-				return nil, err
-			}
-			done.add(key)
-		}
+	create := func(db *sqlite3.Conn, _, _, _ string, arg ...string) (*table, error) {
+		return declare(db, fsys, true, arg...)
+	}
+	connect := func(db *sqlite3.Conn, _, _, _ string, arg ...string) (*table, error) {
+		return declare(db, fsys, false, arg...)
+	}
+	return sqlite3.CreateModule(db, "csv", create, connect)
+}
 
-		if (filename == "") == (data == "") {
-			return nil, errutil.ErrorString(`csv: must specify either "filename" or "data" but not both`)
-		}
+func declare(db *sqlite3.Conn, fsys fs.FS, create bool, arg ...string) (_ *table, err error) {
+	var (
+		filename string
+		data     string
+		schema   string
+		header   bool
+		writable bool
+		columns  int  = -1
+		comma    rune = ','
+		comment  rune
 
-		t := &table{
-			fsys:    fsys,
-			name:    filename,
-			data:    data,
-			comma:   comma,
-			comment: comment,
-			header:  header,
-		}
+		done = set[string]{}
+	)
 
-		hadSchema := schema != ""
-		if !hadSchema {
-			var row []string
-			if header || columns < 0 {
-				csv, c, err := t.newReader()
-				defer c.Close()
-				if err != nil {
-					return nil, err
-				}
-				row, err = csv.Read()
-				if err != nil {
-					return nil, err
-				}
-			}
-			schema = getSchema(header, columns, row)
-		}
-		err = db.DeclareVTab(schema)
-		if err == nil {
-			err = db.VTabConfig(sqlite3.VTAB_DIRECTONLY)
+	for _, arg := range arg {
+		key, val := sql3util.NamedArg(arg)
+		if done.has(key) {
+			return nil, fmt.Errorf("csv: more than one %q parameter", key)
 		}
-		if err == nil && hadSchema {
-			t.typs, err = getColumnAffinities(schema)
+		switch key {
+		case "filename":
+			filename = sql3util.Unquote(val)
+		case "data":
+			data = sql3util.Unquote(val)
+		case "schema":
+			schema = sql3util.Unquote(val)
+		case "header":
+			header, err = boolArg(key, val)
+		case "writable":
+			writable, err = boolArg(key, val)
+		case "columns":
+			columns, err = uintArg(key, val)
+		case "comma":
+			comma, err = runeArg(key, val)
+		case "comment":
+			comment, err = runeArg(key, val)
+		default:
+			return nil, fmt.Errorf("csv: unknown %q parameter", key)
 		}
 		if err != nil {
 			return nil, err
 		}
-		return t, nil
+		done.add(key)
 	}
 
-	return sqlite3.CreateModule(db, "csv", declare, declare)
+	if (filename == "") == (data == "") {
+		return nil, errutil.ErrorString(`csv: must specify either "filename" or "data" but not both`)
+	}
+	if writable {
+		if filename == "" {
+			return nil, errutil.ErrorString(`csv: writable tables must specify "filename"`)
+		}
+		if _, ok := fsys.(osutil.FS); !ok {
+			return nil, errutil.ErrorString(`csv: writable tables require the OS filesystem`)
+		}
+	}
+
+	t := &table{
+		fsys:     fsys,
+		name:     filename,
+		data:     data,
+		comma:    comma,
+		comment:  comment,
+		header:   header,
+		writable: writable,
+		rows:     -1,
+		synced:   -1,
+	}
+
+	// Create a missing file for a new writable table.
+	var missing bool
+	if writable && create {
+		_, err := fs.Stat(fsys, filename)
+		missing = errors.Is(err, fs.ErrNotExist)
+		if missing && schema == "" && columns < 0 {
+			return nil, errutil.ErrorString(`csv: must specify either "schema" or "columns" to create a file`)
+		}
+	}
+
+	hadSchema := schema != ""
+	if !hadSchema {
+		var row []string
+		if !missing && (header || columns < 0) {
+			csv, c, err := t.newReader()
+			defer c.Close()
+			if err != nil {
+				return nil, err
+			}
+			row, err = csv.Read()
+			if err != nil {
+				return nil, err
+			}
+		}
+		schema = getSchema(header, columns, row)
+	}
+	err = db.DeclareVTab(schema)
+	if err == nil {
+		err = db.VTabConfig(sqlite3.VTAB_DIRECTONLY)
+	}
+	if err == nil && hadSchema {
+		t.typs, err = getColumnAffinities(schema)
+	}
+	if err == nil && missing {
+		err = t.create(schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 type table struct {
-	fsys    fs.FS
-	name    string
-	data    string
-	typs    []sql3util.Affinity
-	comma   rune
-	comment rune
-	header  bool
+	fsys     fs.FS
+	name     string
+	data     string
+	typs     []sql3util.Affinity
+	comma    rune
+	comment  rune
+	header   bool
+	writable bool
+
+	// Rows inserted by the current transaction.
+	pending bytes.Buffer
+	writer  *csv.Writer
+	rows    int64
+	synced  int64 // file size before Sync appended to it, or -1
 }
 
 func (t *table) BestIndex(idx *sqlite3.IndexInfo) error {
@@ -153,6 +203,169 @@ func (t *table) Integrity(schema, table string, flags int) error {
 	return err
 }
 
+func (t *table) Update(arg ...sqlite3.Value) (rowid int64, err error) {
+	if !t.writable {
+		return 0, errutil.ErrorString("csv: table is read-only")
+	}
+	if len(arg) == 1 || arg[0].Type() != sqlite3.NULL {
+		return 0, errutil.ErrorString("csv: table is append-only")
+	}
+
+	if t.rows < 0 {
+		t.rows, err = t.count()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if t.writer == nil {
+		t.writer = csv.NewWriter(&t.pending)
+		t.writer.Comma = t.comma
+	}
+
+	row := make([]string, len(arg)-2)
+	for i, v := range arg[2:] {
+		if v.Type() != sqlite3.NULL {
+			row[i] = v.Text()
+		}
+	}
+	err = t.writer.Write(row)
+	if err != nil {
+		return 0, err
+	}
+	t.rows++
+	return t.rows, nil
+}
+
+func (t *table) Begin() error {
+	return nil
+}
+
+func (t *table) Sync() error {
+	if t.writer == nil {
+		return nil
+	}
+	t.writer.Flush()
+	if t.pending.Len() == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(t.name, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Remember the size of the file, so Rollback can undo the append.
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	if t.synced < 0 {
+		t.synced = size
+	}
+
+	// Make sure we append to a new line.
+	if size > 0 {
+		var last [1]byte
+		_, err := f.ReadAt(last[:], size-1)
+		if err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			_, err = f.WriteString("\n")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = t.pending.WriteTo(f)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (t *table) Commit() error {
+	t.pending.Reset()
+	t.synced = -1
+	return nil
+}
+
+func (t *table) Rollback() error {
+	if t.writer != nil {
+		t.writer.Flush()
+	}
+	t.pending.Reset()
+	t.rows = -1
+
+	// Undo the append, if Sync was called.
+	if t.synced < 0 {
+		return nil
+	}
+	size := t.synced
+	t.synced = -1
+	return os.Truncate(t.name, size)
+}
+
+// create creates the file of a writable table,
+// with a header row if requested.
+func (t *table) create(schema string) error {
+	f, err := os.OpenFile(t.name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if t.header {
+		tab, err := sql3util.ParseTable(schema)
+		if err != nil {
+			return err
+		}
+		row := make([]string, len(tab.Columns))
+		for i, col := range tab.Columns {
+			row[i] = col.Name
+		}
+
+		w := csv.NewWriter(f)
+		w.Comma = t.comma
+		w.Write(row)
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// count counts the rows of the table.
+func (t *table) count() (n int64, err error) {
+	csv, c, err := t.newReader()
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	if t.header {
+		n--
+	}
+	for {
+		_, err := csv.Read()
+		if err == io.EOF {
+			return max(0, n), nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
+
 func (t *table) newReader() (*csv.Reader, io.Closer, error) {
 	var r io.Reader
 	var c io.Closer
@@ -164,7 +377,7 @@ func (t *table) newReader() (*csv.Reader, io.Closer, error) {
 
 		buf := bufio.NewReader(f)
 		bom, err := buf.Peek(3)
-		if err != nil {
+		if err != nil && err != io.EOF {
 			return nil, f, err
 		}
 		if string(bom) == "\xEF\xBB\xBF" {
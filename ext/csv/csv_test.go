@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ncruces/go-sqlite3"
@@ -208,3 +210,167 @@ func TestRegister_errors(t *testing.T) {
 		t.Log(err)
 	}
 }
+
+func TestWritable(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	file := filepath.Join(t.TempDir(), "users.csv")
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE temp.users USING csv(
+			filename = ` + sqlite3.Quote(file) + `,
+			schema   = 'CREATE TABLE x(name, age INT)',
+			header   = YES,
+			writable = YES
+		)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`INSERT INTO temp.users VALUES ('Rob', 67), ('Ken, "K"', NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.LastInsertRowID(); got != 2 {
+		t.Errorf("got %d want 2", got)
+	}
+
+	err = db.Exec(`
+		BEGIN;
+		INSERT INTO temp.users VALUES ('Robert', 0);
+		ROLLBACK;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`UPDATE temp.users SET age = 0`)
+	if err == nil {
+		t.Error("want error")
+	}
+
+	// Rolls back rows appended by Sync,
+	// when syncing another table fails.
+	other := filepath.Join(t.TempDir(), "other.csv")
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE temp.other USING csv(
+			filename = ` + sqlite3.Quote(other) + `,
+			columns  = 1,
+			writable = YES
+		);
+		INSERT INTO temp.other VALUES ('x');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(other, 0777); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`
+		BEGIN;
+		INSERT INTO temp.users VALUES ('Robert', 0);
+		INSERT INTO temp.other VALUES ('y');
+		COMMIT;
+	`)
+	if err == nil {
+		t.Error("want error")
+	}
+	if !db.GetAutocommit() {
+		t.Error("want rollback")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "name,age\nRob,67\n\"Ken, \"\"K\"\"\",\n" {
+		t.Errorf("got %q", got)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT sum(age), count(*) FROM temp.users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 67 {
+		t.Errorf("got %d want 67", got)
+	}
+	if got := stmt.ColumnInt(1); got != 2 {
+		t.Errorf("got %d want 2", got)
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT 1 AS a, 'x,y' AS b, NULL AS c, 0.5 AS d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var buf strings.Builder
+	n, err := csv.Export(&buf, stmt, csv.ExportOptions{Header: true, Null: "NULL", CRLF: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d want 1", n)
+	}
+	if got := buf.String(); got != "a,b,c,d\r\n1,\"x,y\",NULL,0.5\r\n" {
+		t.Errorf("got %q", got)
+	}
+
+	stmt.Reset()
+	buf.Reset()
+	_, err = csv.Export(&buf, stmt, csv.ExportOptions{Comma: ';', Quoting: csv.QuoteNonNumeric})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "1;\"x,y\";;0.5\n" {
+		t.Errorf("got %q", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "out.csv")
+	exp, _, err := db.Prepare(`SELECT csv_export(?, 'SELECT * FROM (VALUES (1), (2), (3))', 'quote=all')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exp.Close()
+
+	err = exp.BindText(1, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exp.Step() {
+		t.Fatal(exp.Err())
+	}
+	if got := exp.ColumnInt(0); got != 3 {
+		t.Errorf("got %d want 3", got)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "\"1\"\n\"2\"\n\"3\"\n" {
+		t.Errorf("got %q", got)
+	}
+}
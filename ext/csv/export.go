@@ -0,0 +1,223 @@
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+	"github.com/ncruces/go-sqlite3/util/sql3util"
+)
+
+// Quoting controls which fields [Export] quotes.
+type Quoting uint8
+
+const (
+	QuoteMinimal    Quoting = iota // quote fields only when required
+	QuoteAll                       // quote all fields, except NULL
+	QuoteNonNumeric                // quote all fields, except numbers and NULL
+)
+
+// ExportOptions configure [Export].
+type ExportOptions struct {
+	Comma   rune    // field delimiter; defaults to ','
+	Quoting Quoting // which fields to quote
+	Null    string  // representation of NULL; never quoted
+	Header  bool    // write a header row with the column names
+	CRLF    bool    // terminate rows with \r\n instead of \n
+}
+
+// Export writes the rows returned by stmt to w,
+// as RFC 4180 formatted comma-separated values.
+// It returns the number of rows written, excluding the header.
+//
+// Integers, floats and text are written as SQLite converts them to text;
+// blobs are written as is.
+func Export(w io.Writer, stmt *sqlite3.Stmt, opts ExportOptions) (rows int64, err error) {
+	comma := opts.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	if !validDelim(comma) {
+		return 0, errutil.ErrorString("csv: invalid delimiter")
+	}
+	eol := "\n"
+	if opts.CRLF {
+		eol = "\r\n"
+	}
+
+	e := exporter{
+		w:     bufio.NewWriter(w),
+		comma: comma,
+		quote: opts.Quoting,
+	}
+
+	if opts.Header {
+		for i := range stmt.ColumnCount() {
+			e.field(i, stmt.ColumnName(i), true)
+		}
+		e.w.WriteString(eol)
+	}
+
+	for stmt.Step() {
+		for i := range stmt.ColumnCount() {
+			switch stmt.ColumnType(i) {
+			case sqlite3.NULL:
+				e.sep(i)
+				e.w.WriteString(opts.Null)
+			case sqlite3.INTEGER, sqlite3.FLOAT:
+				e.field(i, string(stmt.ColumnRawText(i)), false)
+			default:
+				e.field(i, string(stmt.ColumnRawBlob(i)), true)
+			}
+		}
+		e.w.WriteString(eol)
+		rows++
+	}
+	if err := stmt.Err(); err != nil {
+		return rows, err
+	}
+	return rows, e.w.Flush()
+}
+
+type exporter struct {
+	w     *bufio.Writer
+	comma rune
+	quote Quoting
+}
+
+func (e *exporter) sep(i int) {
+	if i > 0 {
+		e.w.WriteRune(e.comma)
+	}
+}
+
+func (e *exporter) field(i int, s string, text bool) {
+	e.sep(i)
+
+	var quote bool
+	switch e.quote {
+	case QuoteAll:
+		quote = true
+	case QuoteNonNumeric:
+		quote = text
+	}
+	if !quote && !e.needsQuotes(s) {
+		e.w.WriteString(s)
+		return
+	}
+
+	e.w.WriteByte('"')
+	for {
+		i := strings.IndexByte(s, '"')
+		if i < 0 {
+			break
+		}
+		e.w.WriteString(s[:i+1])
+		e.w.WriteByte('"')
+		s = s[i+1:]
+	}
+	e.w.WriteString(s)
+	e.w.WriteByte('"')
+}
+
+// needsQuotes follows [csv.Writer].
+func (e *exporter) needsQuotes(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s == `\.` {
+		return true
+	}
+	if e.comma < utf8.RuneSelf {
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c == '\n' || c == '\r' || c == '"' || c == byte(e.comma) {
+				return true
+			}
+		}
+	} else {
+		if strings.ContainsRune(s, e.comma) || strings.ContainsAny(s, "\"\r\n") {
+			return true
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func validDelim(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// export implements the csv_export SQL function:
+//
+//	csv_export(filename, query, [option=value]...)
+//
+// Options are header, comma, quote (minimal, all, nonnumeric), null and crlf.
+func export(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if len(arg) < 2 {
+		ctx.ResultError(errutil.ErrorString("csv_export: wrong number of arguments"))
+		return
+	}
+
+	var opts ExportOptions
+	for _, arg := range arg[2:] {
+		var err error
+		key, val := sql3util.NamedArg(arg.Text())
+		switch key {
+		case "header":
+			opts.Header, err = boolArg(key, val)
+		case "crlf":
+			opts.CRLF, err = boolArg(key, val)
+		case "comma":
+			opts.Comma, err = runeArg(key, val)
+		case "null":
+			opts.Null = sql3util.Unquote(val)
+		case "quote":
+			opts.Quoting, err = quoteArg(key, val)
+		default:
+			err = fmt.Errorf("csv: unknown %q parameter", key)
+		}
+		if err != nil {
+			ctx.ResultError(err)
+			return
+		}
+	}
+
+	stmt, tail, err := ctx.Conn().Prepare(arg[1].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("csv_export: %w", err))
+		return
+	}
+	defer stmt.Close()
+	if tail != "" {
+		ctx.ResultError(errutil.ErrorString("csv_export: multiple statements"))
+		return
+	}
+	if !stmt.ReadOnly() {
+		ctx.ResultError(errutil.ErrorString("csv_export: query is not read-only"))
+		return
+	}
+
+	f, err := os.Create(arg[0].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("csv_export: %w", err))
+		return
+	}
+	defer f.Close()
+
+	n, err := Export(f, stmt, opts)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("csv_export: %w", err))
+		return
+	}
+	ctx.ResultInt64(n)
+}
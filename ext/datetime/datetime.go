@@ -0,0 +1,298 @@
+// Package datetime provides time zone and calendar functions.
+//
+// Provided functions:
+//   - tz_convert(ts, from_zone, to_zone)
+//   - tz_offset(ts, zone)
+//   - date_trunc(unit, ts [, zone])
+//   - date_bin(stride, ts [, origin])
+//   - date_add(ts, interval [, zone])
+//   - date_diff(unit, start, end [, zone])
+//   - iso_year(ts [, zone])
+//   - iso_week(ts [, zone])
+//   - iso_weekday(ts [, zone])
+//
+// Time values can be numbers (interpreted like the SQLite auto modifier),
+// or text, optionally with a time zone offset.
+// Text without an offset is UTC,
+// except for tz_convert, which interprets it in from_zone.
+//
+// Functions that return a date-time return text,
+// formatted as YYYY-MM-DD HH:MM:SS.SSS, without an offset,
+// and with fractional seconds omitted when zero.
+// The result is the wall clock time in zone (UTC, if omitted),
+// so that:
+//
+//	SELECT date_trunc('day', created, 'America/New_York') AS day, count(*)
+//	FROM orders GROUP BY day;
+//
+// groups orders by New York day.
+//
+// Time zones are IANA names (like Europe/Lisbon), UTC, localtime,
+// or fixed offsets (like +05:30), and are loaded with [time.LoadLocation].
+// To embed a copy of the time zone database in your program,
+// build with the sqlite3_tzdata tag, or import [time/tzdata].
+//
+// Units are microsecond, millisecond, second, minute, hour,
+// day, week, month, quarter, and year; plurals are accepted.
+// Weeks are ISO weeks, starting on Monday.
+//
+// Intervals are one or more components like '1 day', '-2 hours',
+// or '01:30:00'.
+// Months and days are calendar units,
+// added to the wall clock time in zone:
+// adding '1 day' preserves the time of day across DST changes.
+// Like the SQLite date modifiers,
+// adding months to a day that overflows the target month normalizes it,
+// so 2001-01-31 plus '1 month' is 2001-03-03.
+//
+// date_bin bins ts into stride-wide buckets aligned with origin
+// (2000-01-03, a Monday, if omitted).
+// The stride must be a fixed duration (no months, or years);
+// days are 24 hours.
+package datetime
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// Register registers time zone and calendar functions for a database connection.
+func Register(db *sqlite3.Conn) error {
+	const flags = sqlite3.DETERMINISTIC | sqlite3.INNOCUOUS
+	return errors.Join(
+		db.CreateFunction("tz_convert", 3, flags, convert),
+		db.CreateFunction("tz_offset", 2, flags, offset),
+		db.CreateFunction("date_trunc", 2, flags, truncate),
+		db.CreateFunction("date_trunc", 3, flags, truncate),
+		db.CreateFunction("date_bin", 2, flags, bin),
+		db.CreateFunction("date_bin", 3, flags, bin),
+		db.CreateFunction("date_add", 2, flags, add),
+		db.CreateFunction("date_add", 3, flags, add),
+		db.CreateFunction("date_diff", 3, flags, difference),
+		db.CreateFunction("date_diff", 4, flags, difference),
+		db.CreateFunction("iso_year", 1, flags, isoYear),
+		db.CreateFunction("iso_year", 2, flags, isoYear),
+		db.CreateFunction("iso_week", 1, flags, isoWeek),
+		db.CreateFunction("iso_week", 2, flags, isoWeek),
+		db.CreateFunction("iso_weekday", 1, flags, isoWeekday),
+		db.CreateFunction("iso_weekday", 2, flags, isoWeekday))
+}
+
+func anyNull(arg []sqlite3.Value) bool {
+	for _, a := range arg {
+		if a.Type() == sqlite3.NULL {
+			return true
+		}
+	}
+	return false
+}
+
+func convert(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+	from, err := loadLocation(arg[1].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("tz_convert: %w", err))
+		return
+	}
+	to, err := loadLocation(arg[2].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("tz_convert: %w", err))
+		return
+	}
+	t, err := parseTime(arg[0], from)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("tz_convert: %w", err))
+		return
+	}
+	resultTime(ctx, t.In(to))
+}
+
+func offset(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+	loc, err := loadLocation(arg[1].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("tz_offset: %w", err))
+		return
+	}
+	t, err := parseTime(arg[0], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("tz_offset: %w", err))
+		return
+	}
+	_, off := t.In(loc).Zone()
+	ctx.ResultInt(off)
+}
+
+func truncate(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+	u, err := parseUnit(arg[0].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_trunc: %w", err))
+		return
+	}
+	loc, err := zoneArg(arg, 2)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_trunc: %w", err))
+		return
+	}
+	t, err := parseTime(arg[1], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_trunc: %w", err))
+		return
+	}
+	resultTime(ctx, trunc(t.In(loc), u))
+}
+
+// Default origin for date_bin: a Monday.
+var binOrigin = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+
+func bin(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+
+	var stride time.Duration
+	switch arg[0].Type() {
+	case sqlite3.INTEGER, sqlite3.FLOAT:
+		stride = time.Duration(arg[0].Float() * float64(time.Second))
+	default:
+		iv, err := parseInterval(arg[0].Text())
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("date_bin: %w", err))
+			return
+		}
+		if iv.months != 0 {
+			ctx.ResultError(errutil.ErrorString("date_bin: stride cannot contain months or years"))
+			return
+		}
+		stride = time.Duration(iv.days)*24*time.Hour + iv.dur
+	}
+	if stride <= 0 {
+		ctx.ResultError(errutil.ErrorString("date_bin: stride must be positive"))
+		return
+	}
+
+	t, err := parseTime(arg[1], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_bin: %w", err))
+		return
+	}
+	origin := binOrigin
+	if len(arg) > 2 {
+		origin, err = parseTime(arg[2], time.UTC)
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("date_bin: %w", err))
+			return
+		}
+	}
+
+	d := t.Sub(origin)
+	n := d / stride
+	if d%stride < 0 {
+		n--
+	}
+	resultTime(ctx, origin.Add(n*stride).UTC())
+}
+
+func add(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+	loc, err := zoneArg(arg, 2)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_add: %w", err))
+		return
+	}
+	t, err := parseTime(arg[0], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_add: %w", err))
+		return
+	}
+
+	var iv interval
+	switch arg[1].Type() {
+	case sqlite3.INTEGER, sqlite3.FLOAT:
+		iv.dur = time.Duration(arg[1].Float() * float64(time.Second))
+	default:
+		iv, err = parseInterval(arg[1].Text())
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("date_add: %w", err))
+			return
+		}
+	}
+	resultTime(ctx, iv.add(t.In(loc)))
+}
+
+func difference(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if anyNull(arg) {
+		return
+	}
+	u, err := parseUnit(arg[0].Text())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_diff: %w", err))
+		return
+	}
+	loc, err := zoneArg(arg, 3)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_diff: %w", err))
+		return
+	}
+	start, err := parseTime(arg[1], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_diff: %w", err))
+		return
+	}
+	end, err := parseTime(arg[2], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("date_diff: %w", err))
+		return
+	}
+	ctx.ResultInt64(diff(start.In(loc), end, u))
+}
+
+func isoYear(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if t, ok := isoTime(ctx, "iso_year", arg); ok {
+		year, _ := t.ISOWeek()
+		ctx.ResultInt(year)
+	}
+}
+
+func isoWeek(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if t, ok := isoTime(ctx, "iso_week", arg); ok {
+		_, week := t.ISOWeek()
+		ctx.ResultInt(week)
+	}
+}
+
+func isoWeekday(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if t, ok := isoTime(ctx, "iso_weekday", arg); ok {
+		ctx.ResultInt((int(t.Weekday())+6)%7 + 1)
+	}
+}
+
+func isoTime(ctx sqlite3.Context, name string, arg []sqlite3.Value) (time.Time, bool) {
+	if anyNull(arg) {
+		return time.Time{}, false
+	}
+	loc, err := zoneArg(arg, 1)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("%s: %w", name, err))
+		return time.Time{}, false
+	}
+	t, err := parseTime(arg[0], time.UTC)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("%s: %w", name, err))
+		return time.Time{}, false
+	}
+	return t.In(loc), true
+}
@@ -0,0 +1,94 @@
+package datetime_test
+
+import (
+	"testing"
+	_ "time/tzdata"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/datetime"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = datetime.Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`tz_convert(NULL, 'UTC', 'UTC')`, ""},
+		{`tz_convert('2024-03-10 12:30:00', 'UTC', 'America/New_York')`, "2024-03-10 08:30:00"},
+		{`tz_convert('2024-03-10 08:30:00', 'America/New_York', 'Asia/Kolkata')`, "2024-03-10 18:00:00"},
+		{`tz_convert('2024-03-10T08:30:00.5-04:00', 'Asia/Tokyo', 'UTC')`, "2024-03-10 12:30:00.5"},
+		{`tz_convert(1710073800, 'Asia/Tokyo', '+05:30')`, "2024-03-10 18:00:00"},
+		{`tz_offset('2024-01-15', 'America/New_York')`, "-18000"},
+		{`tz_offset('2024-07-15', 'America/New_York')`, "-14400"},
+		{`tz_offset('2024-07-15', '-0930')`, "-34200"},
+		{`date_trunc('day', '2024-03-10 03:00:00', 'America/New_York')`, "2024-03-09 00:00:00"},
+		{`date_trunc('week', '2024-03-10 12:30:00')`, "2024-03-04 00:00:00"},
+		{`date_trunc('quarter', '2024-03-10 12:30:00')`, "2024-01-01 00:00:00"},
+		{`date_trunc('hours', '2024-03-10 12:30:00')`, "2024-03-10 12:00:00"},
+		{`date_bin('15 minutes', '2024-03-10 12:38:20')`, "2024-03-10 12:30:00"},
+		{`date_bin(3600, '2024-03-10 12:38:20', '2024-01-01 00:20')`, "2024-03-10 12:20:00"},
+		{`date_bin('7 days', '1999-12-31')`, "1999-12-27 00:00:00"},
+		{`date_add('2024-03-09 12:00:00', '1 day', 'America/New_York')`, "2024-03-10 07:00:00"},
+		{`date_add('2024-03-09 17:00:00', '1 day', 'America/New_York')`, "2024-03-10 12:00:00"},
+		{`date_add('2024-03-09 17:00:00', '24 hours', 'America/New_York')`, "2024-03-10 13:00:00"},
+		{`date_add('2001-01-31', '1 month')`, "2001-03-03 00:00:00"},
+		{`date_add('2024-01-01', '-1 year 01:30')`, "2023-01-01 01:30:00"},
+		{`date_add('2024-01-01', 90)`, "2024-01-01 00:01:30"},
+		{`date_diff('month', '2001-01-31', '2001-02-28')`, "0"},
+		{`date_diff('months', '2001-01-31', '2001-03-31')`, "2"},
+		{`date_diff('year', '2001-01-31', '2000-01-30')`, "-1"},
+		{`date_diff('day', '2024-03-09 17:00:00', '2024-03-10 16:00:00', 'America/New_York')`, "1"},
+		{`date_diff('hour', '2024-03-09 17:00:00', '2024-03-10 16:00:00', 'America/New_York')`, "23"},
+		{`iso_year('2021-01-03')`, "2020"},
+		{`iso_week('2021-01-03')`, "53"},
+		{`iso_weekday('2021-01-03')`, "7"},
+		{`iso_weekday('2021-01-04 02:00:00', 'America/New_York')`, "7"},
+	}
+
+	for _, tt := range tests {
+		stmt, _, err := db.Prepare(`SELECT ` + tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stmt.Step() {
+			t.Fatal(tt.sql, stmt.Err())
+		}
+		if got := stmt.ColumnText(0); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.sql, got, tt.want)
+		}
+		stmt.Close()
+	}
+
+	for _, sql := range []string{
+		`tz_convert('2024-03-10', 'UTC', 'Mars/Olympus_Mons')`,
+		`tz_offset('yesterday', 'UTC')`,
+		`date_trunc('fortnight', '2024-03-10')`,
+		`date_bin('1 month', '2024-03-10')`,
+		`date_bin('-1 hour', '2024-03-10')`,
+		`date_add('2024-03-10', '1.5 days')`,
+		`date_add('2024-03-10', 'soon')`,
+	} {
+		stmt, _, err := db.Prepare(`SELECT ` + sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt.Step() {
+			t.Errorf("%s: want error", sql)
+		}
+		stmt.Close()
+	}
+}
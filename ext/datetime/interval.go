@@ -0,0 +1,255 @@
+package datetime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type unit int
+
+const (
+	unitMicrosecond unit = iota
+	unitMillisecond
+	unitSecond
+	unitMinute
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitQuarter
+	unitYear
+)
+
+func parseUnit(s string) (unit, error) {
+	l := strings.ToLower(s)
+	switch l {
+	case "us":
+		return unitMicrosecond, nil
+	case "ms":
+		return unitMillisecond, nil
+	}
+	switch strings.TrimSuffix(l, "s") {
+	case "microsecond":
+		return unitMicrosecond, nil
+	case "millisecond":
+		return unitMillisecond, nil
+	case "second":
+		return unitSecond, nil
+	case "minute":
+		return unitMinute, nil
+	case "hour":
+		return unitHour, nil
+	case "day":
+		return unitDay, nil
+	case "week":
+		return unitWeek, nil
+	case "month":
+		return unitMonth, nil
+	case "quarter":
+		return unitQuarter, nil
+	case "year":
+		return unitYear, nil
+	}
+	return 0, fmt.Errorf("unknown unit: %q", s)
+}
+
+// duration returns the fixed duration of a unit,
+// or 0 for calendar units.
+func (u unit) duration() time.Duration {
+	switch u {
+	case unitMicrosecond:
+		return time.Microsecond
+	case unitMillisecond:
+		return time.Millisecond
+	case unitSecond:
+		return time.Second
+	case unitMinute:
+		return time.Minute
+	case unitHour:
+		return time.Hour
+	}
+	return 0
+}
+
+// An interval is a number of months and days,
+// and a fixed duration.
+// Months and days are calendar units:
+// their duration depends on the time and time zone
+// to which they are added.
+type interval struct {
+	months int
+	days   int
+	dur    time.Duration
+}
+
+// parseInterval parses an interval
+// made of one or more components like "1 day", "-2 hours", or "01:30:00".
+func parseInterval(s string) (iv interval, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return iv, fmt.Errorf("invalid interval: %q", s)
+	}
+	for len(fields) > 0 {
+		f := fields[0]
+		fields = fields[1:]
+
+		if strings.Contains(f, ":") {
+			d, err := parseClock(f)
+			if err != nil {
+				return iv, fmt.Errorf("invalid interval: %q", s)
+			}
+			iv.dur += d
+			continue
+		}
+
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil || len(fields) == 0 {
+			return iv, fmt.Errorf("invalid interval: %q", s)
+		}
+		u, err := parseUnit(fields[0])
+		if err != nil {
+			return iv, err
+		}
+		fields = fields[1:]
+
+		if d := u.duration(); d != 0 {
+			iv.dur += time.Duration(n * float64(d))
+			continue
+		}
+		if n != float64(int(n)) {
+			return iv, fmt.Errorf("invalid interval: %q", s)
+		}
+		switch u {
+		case unitDay:
+			iv.days += int(n)
+		case unitWeek:
+			iv.days += 7 * int(n)
+		case unitMonth:
+			iv.months += int(n)
+		case unitQuarter:
+			iv.months += 3 * int(n)
+		case unitYear:
+			iv.months += 12 * int(n)
+		}
+	}
+	return iv, nil
+}
+
+// parseClock parses [+-]HH:MM[:SS[.SSS]].
+func parseClock(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, strconv.ErrSyntax
+	}
+	var d time.Duration
+	for i, p := range parts {
+		if i < 2 {
+			n, err := strconv.ParseUint(p, 10, 32)
+			if err != nil {
+				return 0, err
+			}
+			d = 60*d + time.Duration(n)*time.Minute
+		} else {
+			n, err := strconv.ParseFloat(p, 64)
+			if err != nil || n < 0 {
+				return 0, strconv.ErrSyntax
+			}
+			d += time.Duration(n * float64(time.Second))
+		}
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// add adds the interval to t, in the time zone of t.
+func (iv interval) add(t time.Time) time.Time {
+	if iv.months != 0 || iv.days != 0 {
+		t = t.AddDate(0, iv.months, iv.days)
+	}
+	return t.Add(iv.dur)
+}
+
+// trunc truncates t to the start of unit u, in the time zone of t.
+func trunc(t time.Time, u unit) time.Time {
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	nsec := t.Nanosecond()
+	switch u {
+	case unitYear:
+		month = 1
+		fallthrough
+	case unitQuarter:
+		month -= (month - 1) % 3
+		fallthrough
+	case unitMonth:
+		day = 1
+		fallthrough
+	case unitDay:
+		hour = 0
+		fallthrough
+	case unitHour:
+		min = 0
+		fallthrough
+	case unitMinute:
+		sec = 0
+		fallthrough
+	case unitSecond:
+		nsec = 0
+	case unitMillisecond:
+		nsec -= nsec % 1e6
+	case unitMicrosecond:
+		nsec -= nsec % 1e3
+	case unitWeek:
+		// ISO weeks start on Monday.
+		day -= (int(t.Weekday()) + 6) % 7
+		hour, min, sec, nsec = 0, 0, 0, 0
+	}
+	return time.Date(year, month, day, hour, min, sec, nsec, t.Location())
+}
+
+// diff returns the number of whole units from a to b,
+// in the time zone of a.
+func diff(a, b time.Time, u unit) int64 {
+	if d := u.duration(); d != 0 {
+		return int64(b.Sub(a) / d)
+	}
+
+	b = b.In(a.Location())
+	var n int
+	var add func(n int) time.Time
+	switch u {
+	case unitDay, unitWeek:
+		ya, ma, da := a.Date()
+		yb, mb, db := b.Date()
+		n = int(time.Date(yb, mb, db, 0, 0, 0, 0, time.UTC).
+			Sub(time.Date(ya, ma, da, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+		add = func(n int) time.Time { return a.AddDate(0, 0, n) }
+	default:
+		n = 12*(b.Year()-a.Year()) + int(b.Month()-a.Month())
+		add = func(n int) time.Time { return a.AddDate(0, n, 0) }
+	}
+
+	for n > 0 && add(n).After(b) {
+		n--
+	}
+	for n < 0 && add(n).Before(b) {
+		n++
+	}
+
+	switch u {
+	case unitWeek:
+		n /= 7
+	case unitQuarter:
+		n /= 3
+	case unitYear:
+		n /= 12
+	}
+	return int64(n)
+}
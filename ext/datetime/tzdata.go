@@ -0,0 +1,5 @@
+//go:build sqlite3_tzdata
+
+package datetime
+
+import _ "time/tzdata"
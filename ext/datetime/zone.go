@@ -0,0 +1,109 @@
+package datetime
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// The format of returned date-times.
+// Fractional seconds are omitted when zero.
+const layout = "2006-01-02 15:04:05.999999999"
+
+var locations sync.Map // map[string]*time.Location
+
+// loadLocation loads a time zone by name.
+// Besides IANA names, it accepts localtime,
+// and fixed offsets like +05:30.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	var loc *time.Location
+	switch {
+	case strings.EqualFold(name, "localtime"):
+		return time.Local, nil
+	case strings.EqualFold(name, "utc"), name == "Z":
+		return time.UTC, nil
+	case strings.HasPrefix(name, "+"), strings.HasPrefix(name, "-"):
+		t, err := time.Parse("-07:00", name)
+		if err != nil {
+			t, err = time.Parse("-0700", name)
+		}
+		if err != nil {
+			return nil, errutil.ErrorString("invalid time zone offset: " + name)
+		}
+		_, off := t.Zone()
+		loc = time.FixedZone(name, off)
+	default:
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// zoneArg loads the optional time zone argument at index i,
+// defaulting to UTC.
+func zoneArg(arg []sqlite3.Value, i int) (*time.Location, error) {
+	if len(arg) <= i {
+		return time.UTC, nil
+	}
+	return loadLocation(arg[i].Text())
+}
+
+var layouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a time value.
+//
+// Numbers are interpreted as by [sqlite3.TimeFormatAuto].
+// Text may carry a time zone offset;
+// otherwise, it is a wall clock time in loc.
+func parseTime(v sqlite3.Value, loc *time.Location) (time.Time, error) {
+	switch v.Type() {
+	case sqlite3.INTEGER:
+		return sqlite3.TimeFormatAuto.Decode(v.Int64())
+	case sqlite3.FLOAT:
+		return sqlite3.TimeFormatAuto.Decode(v.Float())
+	case sqlite3.NULL:
+		return time.Time{}, errutil.TimeErr
+	}
+
+	s := strings.TrimSpace(v.Text())
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return sqlite3.TimeFormatAuto.Decode(i)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return sqlite3.TimeFormatAuto.Decode(f)
+	}
+	for _, l := range layouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, l := range layouts[:4] {
+		if t, err := time.Parse(l+"Z07:00", s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errutil.TimeErr
+}
+
+func resultTime(ctx sqlite3.Context, t time.Time) {
+	ctx.ResultRawText(t.AppendFormat(nil, layout))
+}
@@ -1,6 +1,11 @@
-// Package fileio provides SQL functions to read, write and list files.
+// Package fileio provides SQL functions to read, write and list files,
+// and to read and write ZIP and SQLite archives.
 //
 // https://sqlite.org/src/doc/tip/ext/misc/fileio.c
+//
+// https://sqlite.org/zipfile.html
+//
+// https://sqlite.org/sqlar.html
 package fileio
 
 import (
@@ -13,14 +18,17 @@ import (
 )
 
 // Register registers SQL functions readfile, writefile, lsmode,
-// and the table-valued function fsdir.
+// sqlar_compress, sqlar_uncompress,
+// and the table-valued functions fsdir and zipfile.
+// Inserting into zipfile creates or appends to archives.
 func Register(db *sqlite3.Conn) error {
 	return RegisterFS(db, nil)
 }
 
 // RegisterFS registers SQL functions readfile, lsmode,
-// and the table-valued function fsdir;
-// fsys will be used to read files and list directories.
+// sqlar_compress, sqlar_uncompress,
+// and the table-valued functions fsdir and zipfile;
+// fsys will be used to read files, archives, and list directories.
 func RegisterFS(db *sqlite3.Conn, fsys fs.FS) error {
 	var err error
 	if fsys == nil {
@@ -29,6 +37,15 @@ func RegisterFS(db *sqlite3.Conn, fsys fs.FS) error {
 	return errors.Join(err,
 		db.CreateFunction("readfile", 1, sqlite3.DIRECTONLY, readfile(fsys)),
 		db.CreateFunction("lsmode", 1, sqlite3.DETERMINISTIC, lsmode),
+		db.CreateFunction("sqlar_compress", 1, sqlite3.DETERMINISTIC|sqlite3.INNOCUOUS, sqlarCompress),
+		db.CreateFunction("sqlar_uncompress", 2, sqlite3.DETERMINISTIC|sqlite3.INNOCUOUS, sqlarUncompress),
+		sqlite3.CreateModule(db, "zipfile", nil, func(db *sqlite3.Conn, _, _, _ string, _ ...string) (*zipfile, error) {
+			err := db.DeclareVTab(`CREATE TABLE x(name TEXT,mode INT,mtime INT,sz INT,rawdata BLOB,data BLOB,method INT,z HIDDEN)`)
+			if err == nil {
+				err = db.VTabConfig(sqlite3.VTAB_DIRECTONLY)
+			}
+			return &zipfile{fsys: fsys}, err
+		}),
 		sqlite3.CreateModule(db, "fsdir", nil, func(db *sqlite3.Conn, _, _, _ string, _ ...string) (fsdir, error) {
 			err := db.DeclareVTab(`CREATE TABLE x(name TEXT,mode INT,mtime TIMESTAMP,data BLOB,level INT,path HIDDEN,dir HIDDEN)`)
 			if err == nil {
@@ -0,0 +1,62 @@
+package fileio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// sqlar_compress(X) compresses X with zlib,
+// unless that does not make it smaller.
+//
+// https://sqlite.org/sqlar.html
+func sqlarCompress(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() != sqlite3.BLOB {
+		ctx.ResultValue(arg[0])
+		return
+	}
+
+	data := arg[0].RawBlob()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+
+	if buf.Len() < len(data) {
+		ctx.ResultBlob(buf.Bytes())
+	} else {
+		ctx.ResultBlob(data)
+	}
+}
+
+// sqlar_uncompress(X, SZ) uncompresses X,
+// unless its size is already SZ.
+//
+// https://sqlite.org/sqlar.html
+func sqlarUncompress(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() != sqlite3.BLOB {
+		ctx.ResultValue(arg[0])
+		return
+	}
+
+	data := arg[0].RawBlob()
+	size := arg[1].Int64()
+	if int64(len(data)) == size {
+		ctx.ResultBlob(data)
+		return
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err == nil {
+		var buf bytes.Buffer
+		_, err = io.Copy(&buf, io.LimitReader(r, size))
+		if err == nil && int64(buf.Len()) == size {
+			ctx.ResultBlob(buf.Bytes())
+			return
+		}
+	}
+	ctx.ResultError(errutil.ErrorString("sqlar_uncompress: invalid data"))
+}
@@ -0,0 +1,366 @@
+package fileio
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+	"github.com/ncruces/go-sqlite3/util/fsutil"
+)
+
+const (
+	_ZIP_NAME = iota
+	_ZIP_MODE
+	_ZIP_MTIME
+	_ZIP_SZ
+	_ZIP_RAWDATA
+	_ZIP_DATA
+	_ZIP_METHOD
+	_ZIP_Z
+)
+
+// zipfile implements the zipfile table-valued function.
+//
+// https://sqlite.org/zipfile.html
+type zipfile struct {
+	fsys fs.FS
+	// Entries inserted by the current transaction, by archive.
+	pending map[string][]zipEntry
+}
+
+type zipEntry struct {
+	hdr  zip.FileHeader
+	data []byte
+}
+
+func (z *zipfile) BestIndex(idx *sqlite3.IndexInfo) error {
+	for i, cst := range idx.Constraint {
+		if cst.Column == _ZIP_Z {
+			if !cst.Usable || cst.Op != sqlite3.INDEX_CONSTRAINT_EQ {
+				return sqlite3.CONSTRAINT
+			}
+			idx.ConstraintUsage[i] = sqlite3.IndexConstraintUsage{
+				Omit:      true,
+				ArgvIndex: 1,
+			}
+			idx.EstimatedCost = 1e6
+			return nil
+		}
+	}
+	return sqlite3.CONSTRAINT
+}
+
+func (z *zipfile) Open() (sqlite3.VTabCursor, error) {
+	return &zipCursor{fsys: z.fsys}, nil
+}
+
+func (z *zipfile) Update(arg ...sqlite3.Value) (rowid int64, err error) {
+	if len(arg) == 1 || arg[0].Type() != sqlite3.NULL {
+		return 0, errutil.ErrorString("zipfile: archives are append-only")
+	}
+	if z.fsys != nil {
+		return 0, errutil.ErrorString("zipfile: read-only file system")
+	}
+	arg = arg[2:]
+
+	archive := arg[_ZIP_Z].Text()
+	if archive == "" {
+		return 0, errutil.ErrorString("zipfile: missing archive name")
+	}
+	if arg[_ZIP_RAWDATA].Type() != sqlite3.NULL {
+		return 0, errutil.ErrorString("zipfile: rawdata must be NULL")
+	}
+
+	var e zipEntry
+	e.hdr.Name = arg[_ZIP_NAME].Text()
+	if e.hdr.Name == "" {
+		return 0, errutil.ErrorString("zipfile: missing name")
+	}
+
+	var mode fs.FileMode
+	if arg[_ZIP_MODE].Type() != sqlite3.NULL {
+		mode = fsutil.FileModeFromValue(arg[_ZIP_MODE])
+	}
+	if mode.IsRegular() && strings.HasSuffix(e.hdr.Name, "/") {
+		mode |= fs.ModeDir
+	}
+	switch {
+	case mode.IsDir():
+		mode = fs.ModeDir | fixPerm(mode, 0755)
+		if !strings.HasSuffix(e.hdr.Name, "/") {
+			e.hdr.Name += "/"
+		}
+	case mode.IsRegular():
+		mode = fixPerm(mode, 0644)
+		e.data = bytes.Clone(arg[_ZIP_DATA].RawBlob())
+	case mode&fs.ModeSymlink != 0:
+		mode = fs.ModeSymlink | fixPerm(mode, 0777)
+		e.data = []byte(arg[_ZIP_DATA].Text())
+	default:
+		return 0, fmt.Errorf("zipfile: invalid mode: %v", mode)
+	}
+	e.hdr.SetMode(mode)
+
+	e.hdr.Modified = time.Now()
+	if arg[_ZIP_MTIME].Type() != sqlite3.NULL {
+		e.hdr.Modified = arg[_ZIP_MTIME].Time(sqlite3.TimeFormatUnixFrac)
+	}
+
+	e.hdr.Method = zip.Deflate
+	if arg[_ZIP_METHOD].Type() != sqlite3.NULL {
+		switch m := arg[_ZIP_METHOD].Int(); m {
+		case 0:
+			e.hdr.Method = zip.Store
+		case 8:
+		default:
+			return 0, fmt.Errorf("zipfile: unsupported compression method: %d", m)
+		}
+	}
+	if mode.IsDir() {
+		e.hdr.Method = zip.Store
+	}
+
+	if z.pending == nil {
+		z.pending = map[string][]zipEntry{}
+	}
+	z.pending[archive] = append(z.pending[archive], e)
+	return 0, nil
+}
+
+func (z *zipfile) Begin() error {
+	return nil
+}
+
+func (z *zipfile) Sync() error {
+	for archive, entries := range z.pending {
+		if err := writeArchive(archive, entries); err != nil {
+			return fmt.Errorf("zipfile: %w", err)
+		}
+		delete(z.pending, archive)
+	}
+	return nil
+}
+
+func (z *zipfile) Commit() error {
+	clear(z.pending)
+	return nil
+}
+
+func (z *zipfile) Rollback() error {
+	clear(z.pending)
+	return nil
+}
+
+// writeArchive appends entries to an archive, creating it if needed.
+// The archive is replaced atomically.
+func writeArchive(archive string, entries []zipEntry) (err error) {
+	names := map[string]struct{}{}
+	for _, e := range entries {
+		if _, ok := names[e.hdr.Name]; ok {
+			return fmt.Errorf("duplicate name: %q", e.hdr.Name)
+		}
+		names[e.hdr.Name] = struct{}{}
+	}
+
+	old, err := zip.OpenReader(archive)
+	if err == nil {
+		defer old.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	dir, base := filepath.Split(archive)
+	f, err := os.CreateTemp(dir, base+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := zip.NewWriter(f)
+	if old != nil {
+		for _, o := range old.File {
+			if _, ok := names[o.Name]; ok {
+				return fmt.Errorf("duplicate name: %q", o.Name)
+			}
+			if err := w.Copy(o); err != nil {
+				return err
+			}
+		}
+	}
+	for _, e := range entries {
+		fw, err := w.CreateHeader(&e.hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(e.data); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if old != nil {
+		old.Close()
+	}
+	return os.Rename(f.Name(), archive)
+}
+
+type zipCursor struct {
+	fsys   fs.FS
+	reader *zip.Reader
+	closer io.Closer
+	rowID  int64
+}
+
+func (c *zipCursor) Close() (err error) {
+	if c.closer != nil {
+		err = c.closer.Close()
+		c.closer = nil
+	}
+	return err
+}
+
+func (c *zipCursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) error {
+	if err := c.Close(); err != nil {
+		return err
+	}
+
+	var err error
+	switch typ := arg[0].Type(); typ {
+	case sqlite3.BLOB:
+		data := bytes.Clone(arg[0].RawBlob())
+		c.reader, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	case sqlite3.TEXT:
+		if c.fsys != nil {
+			c.reader, c.closer, err = openZipFS(c.fsys, arg[0].Text())
+		} else {
+			var r *zip.ReadCloser
+			r, err = zip.OpenReader(arg[0].Text())
+			if err == nil {
+				c.reader, c.closer = &r.Reader, r
+			}
+		}
+	default:
+		return fmt.Errorf("zipfile: unsupported argument:%.0w %v", sqlite3.MISMATCH, typ)
+	}
+	if err != nil {
+		return fmt.Errorf("zipfile: %w", err)
+	}
+
+	c.rowID = 0
+	return nil
+}
+
+func openZipFS(fsys fs.FS, name string) (*zip.Reader, io.Closer, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r, ok := f.(io.ReaderAt); ok {
+		if s, err := f.Stat(); err == nil {
+			z, err := zip.NewReader(r, s.Size())
+			if err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+			return z, f, nil
+		}
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	return z, nil, err
+}
+
+func (c *zipCursor) Next() error {
+	c.rowID++
+	return nil
+}
+
+func (c *zipCursor) EOF() bool {
+	return c.rowID >= int64(len(c.reader.File))
+}
+
+func (c *zipCursor) RowID() (int64, error) {
+	return c.rowID, nil
+}
+
+func (c *zipCursor) Column(ctx sqlite3.Context, n int) error {
+	f := c.reader.File[c.rowID]
+	mode := f.Mode()
+
+	switch n {
+	case _ZIP_NAME:
+		ctx.ResultText(f.Name)
+
+	case _ZIP_MODE:
+		ctx.ResultInt64(int64(mode))
+
+	case _ZIP_MTIME:
+		ctx.ResultTime(f.Modified, sqlite3.TimeFormatUnix)
+
+	case _ZIP_SZ:
+		if !mode.IsDir() {
+			ctx.ResultInt64(int64(f.UncompressedSize64))
+		}
+
+	case _ZIP_RAWDATA:
+		if !mode.IsDir() {
+			r, err := f.OpenRaw()
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			ctx.ResultBlob(data)
+		}
+
+	case _ZIP_DATA:
+		if !mode.IsDir() {
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if mode&fs.ModeSymlink != 0 {
+				ctx.ResultText(string(data))
+			} else {
+				ctx.ResultBlob(data)
+			}
+		}
+
+	case _ZIP_METHOD:
+		ctx.ResultInt(int(f.Method))
+	}
+	return nil
+}
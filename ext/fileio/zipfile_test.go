@@ -0,0 +1,164 @@
+package fileio_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/fileio"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func Test_zipfile(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = fileio.Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "test.zip")
+	data := bytes.Repeat([]byte("hello "), 100)
+
+	stmt, _, err := db.Prepare(`INSERT INTO zipfile(name, mode, mtime, data, z) VALUES (?, ?, 0, ?, ?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	insert := func(name string, mode int, data []byte) error {
+		stmt.BindText(1, name)
+		stmt.BindInt(2, mode)
+		stmt.BindBlob(3, data)
+		stmt.BindText(4, archive)
+		return stmt.Exec()
+	}
+
+	if err := insert("dir/", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert("dir/hello.txt", 0644, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert("dir/hello.txt", 0644, data); err == nil {
+		t.Error("want error")
+	}
+
+	// Check with archive/zip.
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != 2 {
+		t.Fatalf("got %d files", len(r.File))
+	}
+	f, err := r.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %q", got)
+	}
+	r.Close()
+
+	// Read it back.
+	for _, arg := range []any{archive, readFile(t, archive)} {
+		stmt, _, err := db.Prepare(`SELECT name, sz, method, data, mtime FROM zipfile(?) WHERE data IS NOT NULL`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		switch arg := arg.(type) {
+		case string:
+			stmt.BindText(1, arg)
+		case []byte:
+			stmt.BindBlob(1, arg)
+		}
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		if got := stmt.ColumnText(0); got != "dir/hello.txt" {
+			t.Errorf("got %q", got)
+		}
+		if got := stmt.ColumnInt(1); got != len(data) {
+			t.Errorf("got %d", got)
+		}
+		if got := stmt.ColumnInt(2); got != 8 {
+			t.Errorf("got %d", got)
+		}
+		if got := stmt.ColumnRawBlob(3); !bytes.Equal(got, data) {
+			t.Errorf("got %q", got)
+		}
+		if got := stmt.ColumnInt(4); got != 0 {
+			t.Errorf("got %d", got)
+		}
+		if stmt.Step() {
+			t.Error("more rows")
+		}
+	}
+
+	err = db.Exec(`DELETE FROM zipfile WHERE z = 'test.zip'`)
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func Test_sqlar(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = fileio.Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT length(sqlar_compress(x)), sqlar_uncompress(sqlar_compress(x), length(x)) = x,
+		       sqlar_compress(y) = y
+		FROM (SELECT zeroblob(1000) AS x, randomblob(100) AS y)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got >= 1000 {
+		t.Errorf("got %d", got)
+	}
+	if !stmt.ColumnBool(1) {
+		t.Error("want true")
+	}
+	if !stmt.ColumnBool(2) {
+		t.Error("want true")
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,127 @@
+package geo
+
+import "math"
+
+// Mean Earth radius, and the WGS84 ellipsoid, in meters.
+const (
+	earthRadius = 6371008.8
+	wgs84A      = 6378137
+	wgs84F      = 1 / 298.257223563
+	wgs84B      = wgs84A * (1 - wgs84F)
+)
+
+const deg = math.Pi / 180
+
+// haversine returns the great-circle distance, in meters,
+// between two points given in degrees of longitude and latitude.
+func haversine(lon1, lat1, lon2, lat2 float64) float64 {
+	phi1, phi2 := lat1*deg, lat2*deg
+	sinDphi := math.Sin((phi2 - phi1) / 2)
+	sinDlambda := math.Sin((lon2 - lon1) * deg / 2)
+	h := sinDphi*sinDphi + math.Cos(phi1)*math.Cos(phi2)*sinDlambda*sinDlambda
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// geodesic returns the distance, in meters, along the WGS84 ellipsoid
+// between two points given in degrees of longitude and latitude,
+// using Vincenty's inverse formula.
+// For nearly antipodal points, where the formula fails to converge,
+// it falls back to the great-circle distance.
+//
+// https://en.wikipedia.org/wiki/Vincenty%27s_formulae
+func geodesic(lon1, lat1, lon2, lat2 float64) float64 {
+	U1 := math.Atan((1 - wgs84F) * math.Tan(lat1*deg))
+	U2 := math.Atan((1 - wgs84F) * math.Tan(lat2*deg))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	L := (lon2 - lon1) * deg
+	lambda := L
+	for range 200 {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0 // coincident points
+		}
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2alpha := 1 - sinAlpha*sinAlpha
+		cos2sigmaM := 0.0 // equatorial line
+		if cos2alpha != 0 {
+			cos2sigmaM = cosSigma - 2*sinU1*sinU2/cos2alpha
+		}
+		C := wgs84F / 16 * cos2alpha * (4 + wgs84F*(4-3*cos2alpha))
+		prev := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*
+			(sigma+C*sinSigma*(cos2sigmaM+C*cosSigma*(-1+2*cos2sigmaM*cos2sigmaM)))
+
+		if math.Abs(lambda-prev) < 1e-12 {
+			u2 := cos2alpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+			A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+			B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+			dsigma := B * sinSigma * (cos2sigmaM + B/4*(cosSigma*(-1+2*cos2sigmaM*cos2sigmaM)-
+				B/6*cos2sigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2sigmaM*cos2sigmaM)))
+			return wgs84B * A * (sigma - dsigma)
+		}
+	}
+	return haversine(lon1, lat1, lon2, lat2)
+}
+
+// destination returns the point reached by traveling a distance, in meters,
+// along a great circle from a point, with an initial bearing, in degrees.
+func destination(lon, lat, bearing, meters float64) point {
+	delta := meters / earthRadius
+	theta := bearing * deg
+	phi1, lambda1 := lat*deg, lon*deg
+	sinPhi1, cosPhi1 := math.Sincos(phi1)
+	sinDelta, cosDelta := math.Sincos(delta)
+	sinPhi2 := sinPhi1*cosDelta + cosPhi1*sinDelta*math.Cos(theta)
+	phi2 := math.Asin(sinPhi2)
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*sinDelta*cosPhi1, cosDelta-sinPhi1*sinPhi2)
+	return point{normalizeLon(lambda2 / deg), phi2 / deg}
+}
+
+func normalizeLon(lon float64) float64 {
+	lon = math.Mod(lon+540, 360) - 180
+	if lon == -180 {
+		return 180
+	}
+	return lon
+}
+
+// buffer returns a polygon approximating the circle
+// with a radius, in meters, around a point.
+func buffer(center point, meters float64, segments int) *geometry {
+	ring := make([]point, segments+1)
+	for i := range segments {
+		ring[i] = destination(center.x, center.y, 360*float64(i)/float64(segments), meters)
+	}
+	ring[segments] = ring[0]
+	return &geometry{kind: kindPolygon, rings: [][]point{ring}}
+}
+
+// RadiusBounds returns a bounding box, in degrees of longitude and latitude,
+// that contains every point within a distance, in meters, of a point.
+// If the box would cross the antimeridian, or contain a pole,
+// it spans all longitudes.
+//
+// Use it to query an rtree index for points near a location,
+// then filter the candidates by their exact distance.
+func RadiusBounds(lon, lat, meters float64) (minLon, minLat, maxLon, maxLat float64) {
+	dphi := meters / earthRadius / deg
+	minLat = lat - dphi
+	maxLat = lat + dphi
+	if minLat <= -90 || maxLat >= 90 {
+		return -180, max(minLat, -90), 180, min(maxLat, 90)
+	}
+
+	// https://janmatuschek.de/LatitudeLongitudeBoundingCoordinates
+	dlambda := math.Asin(math.Sin(meters/earthRadius)/math.Cos(lat*deg)) / deg
+	minLon = lon - dlambda
+	maxLon = lon + dlambda
+	if minLon < -180 || maxLon > 180 || math.IsNaN(dlambda) {
+		return -180, minLat, 180, maxLat
+	}
+	return minLon, minLat, maxLon, maxLat
+}
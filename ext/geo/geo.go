@@ -0,0 +1,273 @@
+// Package geo provides geospatial functions.
+//
+// Geometries are 2D simple features: points, line strings, polygons,
+// their multi variants, and geometry collections.
+// Functions accept geometries as WKB (BLOBs), GeoJSON
+// (TEXT starting with '{'), or WKT (other TEXT),
+// and return them as WKB, unless otherwise noted.
+// Coordinates are in x, y order: longitude before latitude.
+//
+// It provides the following functions:
+//   - geo_point(x, y): a point
+//   - geo_wkb(g), geo_wkt(g), geo_geojson(g): g as WKB, WKT or GeoJSON
+//   - geo_type(g): the geometry type of g (e.g. 'Polygon')
+//   - geo_x(p), geo_y(p): the coordinates of point p
+//   - geo_minx(g), geo_miny(g), geo_maxx(g), geo_maxy(g): the bounds of g
+//   - geo_bbox(g): the bounds of g as a JSON array [minx, miny, maxx, maxy]
+//   - geo_contains_point(g, p), geo_contains_point(g, x, y):
+//     whether point p (or x, y) is inside polygonal geometry g
+//   - geo_haversine(p1, p2), geo_haversine(x1, y1, x2, y2):
+//     the great-circle distance in meters
+//   - geo_distance(p1, p2), geo_distance(x1, y1, x2, y2):
+//     the geodesic distance in meters, on the WGS84 ellipsoid
+//   - geo_buffer(p, meters [, segments]):
+//     a polygon approximating a circle around point p
+//
+// It also provides the geo_nearby table-valued function,
+// that searches a 2D rtree table (with longitude as x, and latitude as y)
+// for the entries within a radius, in meters, of a point:
+//
+//	SELECT id, distance FROM geo_nearby('places', -9.14, 38.71, 5000);
+//
+// SQLite's rtree query callbacks can't be implemented in Go,
+// so geo_nearby queries the rtree with a bounding box (see [RadiusBounds]),
+// and filters, and orders, the results by distance.
+//
+// https://sqlite.org/rtree.html
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Register registers the geospatial functions,
+// and the geo_nearby table-valued function.
+func Register(db *sqlite3.Conn) error {
+	const flags = sqlite3.DETERMINISTIC | sqlite3.INNOCUOUS
+	const json = sqlite3.RESULT_SUBTYPE | flags
+	return errors.Join(
+		db.CreateFunction("geo_point", 2, flags, pointFunc),
+		db.CreateFunction("geo_wkb", 1, flags, wkbFunc),
+		db.CreateFunction("geo_wkt", 1, flags, wktFunc),
+		db.CreateFunction("geo_geojson", 1, json, geojsonFunc),
+		db.CreateFunction("geo_type", 1, flags, typeFunc),
+		db.CreateFunction("geo_x", 1, flags, coordFunc(false)),
+		db.CreateFunction("geo_y", 1, flags, coordFunc(true)),
+		db.CreateFunction("geo_minx", 1, flags, boundsFunc(minX)),
+		db.CreateFunction("geo_miny", 1, flags, boundsFunc(minY)),
+		db.CreateFunction("geo_maxx", 1, flags, boundsFunc(maxX)),
+		db.CreateFunction("geo_maxy", 1, flags, boundsFunc(maxY)),
+		db.CreateFunction("geo_bbox", 1, json, bboxFunc),
+		db.CreateFunction("geo_contains_point", 2, flags, containsFunc),
+		db.CreateFunction("geo_contains_point", 3, flags, containsFunc),
+		db.CreateFunction("geo_haversine", 2, flags, distanceFunc(haversine)),
+		db.CreateFunction("geo_haversine", 4, flags, distanceFunc(haversine)),
+		db.CreateFunction("geo_distance", 2, flags, distanceFunc(geodesic)),
+		db.CreateFunction("geo_distance", 4, flags, distanceFunc(geodesic)),
+		db.CreateFunction("geo_buffer", 2, flags, bufferFunc),
+		db.CreateFunction("geo_buffer", 3, flags, bufferFunc),
+		registerNearby(db))
+}
+
+// geometryArg parses a geometry argument,
+// returning nil for NULL.
+func geometryArg(arg sqlite3.Value) (*geometry, error) {
+	switch arg.Type() {
+	case sqlite3.NULL:
+		return nil, nil
+	case sqlite3.BLOB:
+		return parseWKB(arg.RawBlob())
+	case sqlite3.TEXT:
+		txt := arg.Text()
+		if strings.HasPrefix(strings.TrimSpace(txt), "{") {
+			return parseGeoJSON([]byte(txt))
+		}
+		return parseWKT(txt)
+	}
+	return nil, errGeometry
+}
+
+// pointArgs parses either a point, or a pair of numeric coordinates,
+// returning false for NULL.
+func pointArgs(arg ...sqlite3.Value) (p point, ok bool, err error) {
+	if len(arg) == 2 {
+		if arg[0].NumericType() == sqlite3.NULL || arg[1].NumericType() == sqlite3.NULL {
+			return point{}, false, nil
+		}
+		return point{arg[0].Float(), arg[1].Float()}, true, nil
+	}
+
+	g, err := geometryArg(arg[0])
+	if g == nil || err != nil {
+		return point{}, false, err
+	}
+	if g.kind != kindPoint {
+		return point{}, false, errNotPoint
+	}
+	if len(g.coords) == 0 {
+		return point{}, false, nil
+	}
+	return g.coords[0], true, nil
+}
+
+func pointFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	p, ok, _ := pointArgs(arg...)
+	if ok {
+		g := geometry{kind: kindPoint, coords: []point{p}}
+		ctx.ResultBlob(appendWKB(nil, &g))
+	}
+}
+
+func wkbFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_wkb: %w", err))
+		return
+	}
+	if g != nil {
+		ctx.ResultBlob(appendWKB(nil, g))
+	}
+}
+
+func wktFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_wkt: %w", err))
+		return
+	}
+	if g != nil {
+		ctx.ResultRawText(appendWKT(nil, g))
+	}
+}
+
+func geojsonFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_geojson: %w", err))
+		return
+	}
+	if g != nil {
+		ctx.ResultJSON(toGeoJSON(g))
+		ctx.ResultSubtype('J')
+	}
+}
+
+func typeFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_type: %w", err))
+		return
+	}
+	if g != nil {
+		ctx.ResultText(g.kind.String())
+	}
+}
+
+func coordFunc(y bool) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		p, ok, err := pointArgs(arg[0])
+		if err != nil {
+			ctx.ResultError(err)
+			return
+		}
+		switch {
+		case !ok:
+		case y:
+			ctx.ResultFloat(p.y)
+		default:
+			ctx.ResultFloat(p.x)
+		}
+	}
+}
+
+const (
+	minX = iota
+	minY
+	maxX
+	maxY
+)
+
+func boundsFunc(which int) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		g, err := geometryArg(arg[0])
+		if err != nil {
+			ctx.ResultError(err)
+			return
+		}
+		if g == nil {
+			return
+		}
+		if min, max, ok := g.bounds(); ok {
+			ctx.ResultFloat([...]float64{min.x, min.y, max.x, max.y}[which])
+		}
+	}
+}
+
+func bboxFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_bbox: %w", err))
+		return
+	}
+	if g == nil {
+		return
+	}
+	if min, max, ok := g.bounds(); ok {
+		ctx.ResultJSON([...]float64{min.x, min.y, max.x, max.y})
+		ctx.ResultSubtype('J')
+	}
+}
+
+func containsFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	g, err := geometryArg(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_contains_point: %w", err))
+		return
+	}
+	p, ok, err := pointArgs(arg[1:]...)
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_contains_point: %w", err))
+		return
+	}
+	if g != nil && ok {
+		ctx.ResultBool(g.containsPoint(p))
+	}
+}
+
+func distanceFunc(fn func(lon1, lat1, lon2, lat2 float64) float64) sqlite3.ScalarFunction {
+	return func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+		n := len(arg) / 2
+		p1, ok1, err1 := pointArgs(arg[:n]...)
+		p2, ok2, err2 := pointArgs(arg[n:]...)
+		if err := errors.Join(err1, err2); err != nil {
+			ctx.ResultError(err)
+			return
+		}
+		if ok1 && ok2 {
+			ctx.ResultFloat(fn(p1.x, p1.y, p2.x, p2.y))
+		}
+	}
+}
+
+func bufferFunc(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	p, ok, err := pointArgs(arg[0])
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("geo_buffer: %w", err))
+		return
+	}
+	segments := 32
+	if len(arg) > 2 {
+		segments = arg[2].Int()
+		if segments < 3 || segments > 1024 {
+			ctx.ResultError(fmt.Errorf("geo_buffer: invalid segments: %d", segments))
+			return
+		}
+	}
+	if ok && arg[1].NumericType() != sqlite3.NULL {
+		ctx.ResultBlob(appendWKB(nil, buffer(p, arg[1].Float(), segments)))
+	}
+}
@@ -0,0 +1,193 @@
+package geo_test
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/geo"
+	"github.com/ncruces/go-sqlite3/ext/rtree"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestMain(m *testing.M) {
+	sqlite3.AutoExtension(geo.Register)
+	sqlite3.AutoExtension(rtree.Register)
+	os.Exit(m.Run())
+}
+
+func Example() {
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE places USING rtree(id, minX, maxX, minY, maxY);
+		INSERT INTO places VALUES
+			(1, -9.1393, -9.1393, 38.7223, 38.7223),
+			(2, -9.1427, -9.1427, 38.7077, 38.7077),
+			(3, -8.6291, -8.6291, 41.1579, 41.1579);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT id, round(distance) FROM geo_nearby('places', -9.1427, 38.7077, 5000)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		fmt.Println(stmt.ColumnInt(0), stmt.ColumnFloat(1))
+	}
+	if err := stmt.Err(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 2 0
+	// 1 1650
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`geo_wkt(geo_point(1, 2))`, "POINT (1 2)"},
+		{`hex(geo_point(1, 2))`, "0101000000000000000000F03F0000000000000040"},
+		{`geo_wkt(x'00000000013FF00000000000004000000000000000')`, "POINT (1 2)"},
+		{`geo_wkt('multipoint (1 2, 3 4)')`, "MULTIPOINT ((1 2), (3 4))"},
+		{`geo_wkt(geo_wkb('POLYGON ((0 0, 1 0, 1 1, 0 0))'))`, "POLYGON ((0 0, 1 0, 1 1, 0 0))"},
+		{`geo_wkt('{"type":"LineString","coordinates":[[0,0],[1,2,3]]}')`, "LINESTRING (0 0, 1 2)"},
+		{`geo_wkt('{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]}}')`, "POINT (1 2)"},
+		{`geo_geojson('POINT (1 2)')`, `{"type":"Point","coordinates":[1,2]}`},
+		{`geo_geojson('GEOMETRYCOLLECTION (POINT (1 2))')`,
+			`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]}]}`},
+		{`json_valid(geo_geojson('MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)))'))`, "1"},
+		{`geo_type('MULTILINESTRING ((0 0, 1 1))')`, "MultiLineString"},
+		{`geo_x('POINT (1 2)') || ' ' || geo_y('POINT (1 2)')`, "1.0 2.0"},
+		{`geo_x('POINT EMPTY')`, ""},
+		{`geo_bbox('LINESTRING (3 -1, 0 4, 1 1)')`, "[0,-1,3,4]"},
+		{`geo_minx('LINESTRING (3 -1, 0 4)') || ' ' || geo_maxy('LINESTRING (3 -1, 0 4)')`, "0.0 4.0"},
+		{`geo_bbox('POINT EMPTY')`, ""},
+		{`geo_contains_point('POLYGON ((0 0, 4 0, 4 4, 0 4, 0 0), (1 1, 2 1, 2 2, 1 2, 1 1))', 3, 3)`, "1"},
+		{`geo_contains_point('POLYGON ((0 0, 4 0, 4 4, 0 4, 0 0), (1 1, 2 1, 2 2, 1 2, 1 1))', 1.5, 1.5)`, "0"},
+		{`geo_contains_point('POLYGON ((0 0, 4 0, 4 4, 0 4, 0 0))', geo_point(5, 5))`, "0"},
+		{`geo_contains_point('LINESTRING (0 0, 4 4)', 2, 2)`, "0"},
+		{`round(geo_haversine(-0.1278, 51.5074, 2.3522, 48.8566))`, "343557.0"},
+		{`round(geo_haversine(geo_point(0, 0), geo_point(0, 0)))`, "0.0"},
+		// Flinders Peak to Buninyong
+		{`round(geo_distance(144.42486788888888, -37.95103341666667, 143.92649552777777, -37.65282113888889), 3)`, "54972.271"},
+		{`geo_type(geo_buffer(geo_point(0, 0), 1000))`, "Polygon"},
+		{`json_array_length(geo_geojson(geo_buffer('POINT (0 0)', 1000, 8)), '$.coordinates[0]')`, "9"},
+		{`geo_wkt(NULL)`, ""},
+	}
+
+	for _, tt := range tests {
+		stmt, _, err := db.Prepare(`SELECT ` + tt.sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt.Step() {
+			if got := stmt.ColumnText(0); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+			}
+		}
+		if err := stmt.Close(); err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+		}
+	}
+
+	for _, sql := range []string{
+		`geo_wkt('POINT (1 2 3)')`,
+		`geo_wkt('POLYGON ((0 0, 1 1, 1 0))')`,
+		`geo_wkt('CIRCLE (0 0)')`,
+		`geo_wkt('{"type":"Circle"}')`,
+		`geo_wkt(x'0101000000')`,
+		`geo_x('LINESTRING (0 0, 1 1)')`,
+		`geo_buffer(geo_point(0, 0), 1000, 2)`,
+	} {
+		err := db.Exec(`SELECT ` + sql)
+		if err == nil {
+			t.Errorf("%s: want error", sql)
+		}
+	}
+}
+
+func TestNearby(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE pts USING rtree(id, x0, x1, y0, y1);
+		INSERT INTO pts VALUES
+			(1, 179.99, 179.99, 0, 0),
+			(2, -179.99, -179.99, 0, 0),
+			(3, 0, 0, 0, 0),
+			(4, 10, 20, -5, 5);
+		CREATE VIRTUAL TABLE temp.near USING geo_nearby(tablename=pts);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT id, distance FROM near WHERE x = 180 AND y = 0 AND radius = 5000 ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for stmt.Step() {
+		ids = append(ids, stmt.ColumnInt(0))
+		if d := stmt.ColumnFloat(1); math.Abs(d-1112) > 5 {
+			t.Errorf("distance = %g", d)
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("got %v", ids)
+	}
+
+	// Distance to the closest point of a box.
+	stmt, _, err = db.Prepare(`SELECT id, round(distance) FROM geo_nearby('pts', 10, 0, 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.Step() || stmt.ColumnInt(0) != 4 || stmt.ColumnFloat(1) != 0 {
+		t.Error("want box 4")
+	}
+	if err := stmt.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`SELECT * FROM geo_nearby('missing', 0, 0, 1)`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`SELECT * FROM geo_nearby('pts', 0, 0)`)
+	if err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,205 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// https://www.rfc-editor.org/rfc/rfc7946
+
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometries  []geoJSON       `json:"geometries,omitempty"`
+	Geometry    *geoJSON        `json:"geometry,omitempty"` // Feature
+}
+
+// parseGeoJSON parses a GeoJSON geometry, or the geometry of a Feature.
+// Only 2D positions are used; other dimensions are ignored.
+func parseGeoJSON(data []byte) (*geometry, error) {
+	var j geoJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	g, err := j.geometry(0)
+	if err != nil {
+		return nil, err
+	}
+	return g, g.validate()
+}
+
+func (j *geoJSON) geometry(depth int) (*geometry, error) {
+	if depth > 32 {
+		return nil, fmt.Errorf("invalid GeoJSON: too deeply nested")
+	}
+	if j.Type == "Feature" {
+		if j.Geometry == nil {
+			return nil, fmt.Errorf("invalid GeoJSON: feature without geometry")
+		}
+		return j.Geometry.geometry(depth + 1)
+	}
+
+	var g geometry
+	for k := kindPoint; k <= kindCollection; k++ {
+		if j.Type == k.String() {
+			g.kind = k
+		}
+	}
+	if g.kind == 0 {
+		return nil, fmt.Errorf("invalid GeoJSON: unknown type %q", j.Type)
+	}
+
+	if g.kind == kindCollection {
+		for i := range j.Geometries {
+			part, err := j.Geometries[i].geometry(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			g.parts = append(g.parts, *part)
+		}
+		return &g, nil
+	}
+
+	var err error
+	coords := []byte(j.Coordinates)
+	switch g.kind {
+	case kindPoint:
+		var p []float64
+		if err = json.Unmarshal(coords, &p); err == nil && len(p) > 0 {
+			g.coords, err = positions([][]float64{p})
+		}
+	case kindLineString:
+		var ps [][]float64
+		if err = json.Unmarshal(coords, &ps); err == nil {
+			g.coords, err = positions(ps)
+		}
+	case kindPolygon:
+		var rs [][][]float64
+		if err = json.Unmarshal(coords, &rs); err == nil {
+			g.rings, err = rings(rs)
+		}
+	case kindMultiPoint:
+		var ps [][]float64
+		if err = json.Unmarshal(coords, &ps); err == nil {
+			for _, p := range ps {
+				part := geometry{kind: kindPoint}
+				part.coords, err = positions([][]float64{p})
+				g.parts = append(g.parts, part)
+			}
+		}
+	case kindMultiLineString:
+		var ls [][][]float64
+		if err = json.Unmarshal(coords, &ls); err == nil {
+			for _, l := range ls {
+				part := geometry{kind: kindLineString}
+				part.coords, err = positions(l)
+				g.parts = append(g.parts, part)
+			}
+		}
+	case kindMultiPolygon:
+		var ps [][][][]float64
+		if err = json.Unmarshal(coords, &ps); err == nil {
+			for _, p := range ps {
+				part := geometry{kind: kindPolygon}
+				part.rings, err = rings(p)
+				g.parts = append(g.parts, part)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	return &g, nil
+}
+
+func positions(ps [][]float64) ([]point, error) {
+	res := make([]point, len(ps))
+	for i, p := range ps {
+		if len(p) < 2 {
+			return nil, fmt.Errorf("position must have at least 2 elements")
+		}
+		res[i] = point{p[0], p[1]}
+	}
+	return res, nil
+}
+
+func rings(rs [][][]float64) ([][]point, error) {
+	res := make([][]point, len(rs))
+	for i, r := range rs {
+		var err error
+		if res[i], err = positions(r); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type geoJSONCollection struct {
+	Type       string `json:"type"`
+	Geometries []any  `json:"geometries"`
+}
+
+// toGeoJSON converts a geometry into a JSON encodable value.
+func toGeoJSON(g *geometry) any {
+	position := func(p point) []float64 { return []float64{p.x, p.y} }
+	positions := func(ps []point) [][]float64 {
+		res := make([][]float64, len(ps))
+		for i, p := range ps {
+			res[i] = position(p)
+		}
+		return res
+	}
+	rings := func(rs [][]point) [][][]float64 {
+		res := make([][][]float64, len(rs))
+		for i, r := range rs {
+			res[i] = positions(r)
+		}
+		return res
+	}
+
+	var coords any
+	switch g.kind {
+	case kindPoint:
+		if len(g.coords) == 0 {
+			coords = []float64{}
+		} else {
+			coords = position(g.coords[0])
+		}
+	case kindLineString:
+		coords = positions(g.coords)
+	case kindPolygon:
+		coords = rings(g.rings)
+	case kindMultiPoint:
+		res := make([][]float64, 0, len(g.parts))
+		for _, p := range g.parts {
+			if len(p.coords) > 0 {
+				res = append(res, position(p.coords[0]))
+			}
+		}
+		coords = res
+	case kindMultiLineString:
+		res := make([][][]float64, len(g.parts))
+		for i, p := range g.parts {
+			res[i] = positions(p.coords)
+		}
+		coords = res
+	case kindMultiPolygon:
+		res := make([][][][]float64, len(g.parts))
+		for i, p := range g.parts {
+			res[i] = rings(p.rings)
+		}
+		coords = res
+	case kindCollection:
+		res := make([]any, len(g.parts))
+		for i := range g.parts {
+			res[i] = toGeoJSON(&g.parts[i])
+		}
+		return geoJSONCollection{g.kind.String(), res}
+	}
+	return geoJSONGeometry{g.kind.String(), coords}
+}
@@ -0,0 +1,171 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+type kind uint32
+
+// WKB geometry types.
+const (
+	kindPoint kind = 1 + iota
+	kindLineString
+	kindPolygon
+	kindMultiPoint
+	kindMultiLineString
+	kindMultiPolygon
+	kindCollection
+)
+
+var kindNames = [...]string{
+	kindPoint:           "Point",
+	kindLineString:      "LineString",
+	kindPolygon:         "Polygon",
+	kindMultiPoint:      "MultiPoint",
+	kindMultiLineString: "MultiLineString",
+	kindMultiPolygon:    "MultiPolygon",
+	kindCollection:      "GeometryCollection",
+}
+
+func (k kind) String() string {
+	return kindNames[k]
+}
+
+// part returns the kind of the parts of a multi geometry.
+func (k kind) part() kind {
+	switch k {
+	case kindMultiPoint:
+		return kindPoint
+	case kindMultiLineString:
+		return kindLineString
+	case kindMultiPolygon:
+		return kindPolygon
+	}
+	return 0
+}
+
+type point struct{ x, y float64 }
+
+// A geometry is a 2D simple feature geometry:
+//   - a Point has zero (empty) or one coords;
+//   - a LineString has coords;
+//   - a Polygon has rings, the first being the exterior ring;
+//   - multi geometries, and collections, have parts.
+type geometry struct {
+	kind   kind
+	coords []point
+	rings  [][]point
+	parts  []geometry
+}
+
+const (
+	errGeometry = errutil.ErrorString("invalid geometry")
+	errNotPoint = errutil.ErrorString("geometry is not a point")
+)
+
+func (g *geometry) empty() bool {
+	return len(g.coords) == 0 && len(g.rings) == 0 && len(g.parts) == 0
+}
+
+// bounds returns the bounding box of a geometry,
+// and false if the geometry is empty.
+func (g *geometry) bounds() (min, max point, ok bool) {
+	min = point{math.Inf(+1), math.Inf(+1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	g.walk(func(p point) {
+		min.x = math.Min(min.x, p.x)
+		min.y = math.Min(min.y, p.y)
+		max.x = math.Max(max.x, p.x)
+		max.y = math.Max(max.y, p.y)
+		ok = true
+	})
+	return min, max, ok
+}
+
+// walk calls fn for every point of a geometry.
+func (g *geometry) walk(fn func(point)) {
+	for _, p := range g.coords {
+		fn(p)
+	}
+	for _, r := range g.rings {
+		for _, p := range r {
+			fn(p)
+		}
+	}
+	for i := range g.parts {
+		g.parts[i].walk(fn)
+	}
+}
+
+// containsPoint reports whether p is inside a polygonal geometry,
+// using the even-odd rule: holes are excluded.
+// Points on the boundary may be considered either inside or outside.
+func (g *geometry) containsPoint(p point) bool {
+	switch g.kind {
+	case kindPolygon:
+		in := false
+		for _, r := range g.rings {
+			if ringContains(r, p) {
+				in = !in
+			}
+		}
+		return in
+	case kindMultiPolygon, kindCollection:
+		for i := range g.parts {
+			if g.parts[i].containsPoint(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ringContains(ring []point, p point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.y > p.y) != (b.y > p.y) &&
+			p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// validate checks the structure of a geometry.
+func (g *geometry) validate() error {
+	switch g.kind {
+	case kindPoint:
+		if len(g.coords) > 1 {
+			return errGeometry
+		}
+	case kindLineString:
+		if len(g.coords) == 1 {
+			return errGeometry
+		}
+	case kindPolygon:
+		for _, r := range g.rings {
+			if len(r) < 4 || r[0] != r[len(r)-1] {
+				return errutil.ErrorString("invalid geometry: polygon rings must be closed")
+			}
+		}
+	case kindMultiPoint, kindMultiLineString, kindMultiPolygon:
+		for i := range g.parts {
+			if g.parts[i].kind != g.kind.part() {
+				return errGeometry
+			}
+		}
+		fallthrough
+	case kindCollection:
+		for i := range g.parts {
+			if err := g.parts[i].validate(); err != nil {
+				return err
+			}
+		}
+	default:
+		return errGeometry
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package geo
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/sql3util"
+)
+
+// The geo_nearby virtual table columns.
+const (
+	nearbyID = iota
+	nearbyDistance
+	nearbyTable
+	nearbyX
+	nearbyY
+	nearbyRadius
+)
+
+func registerNearby(db *sqlite3.Conn) error {
+	return sqlite3.CreateModule(db, "geo_nearby", nil,
+		func(db *sqlite3.Conn, _, _, _ string, arg ...string) (*nearby, error) {
+			var table string
+			for _, arg := range arg {
+				key, val := sql3util.NamedArg(arg)
+				if key != "tablename" {
+					return nil, fmt.Errorf("geo_nearby: unknown %q parameter", key)
+				}
+				if table != "" {
+					return nil, fmt.Errorf("geo_nearby: more than one %q parameter", key)
+				}
+				table = sql3util.Unquote(val)
+			}
+
+			err := db.DeclareVTab(`CREATE TABLE x(id INT, distance REAL,
+				tablename HIDDEN, x HIDDEN, y HIDDEN, radius HIDDEN)`)
+			if err != nil {
+				return nil, err
+			}
+			return &nearby{db: db, table: table}, nil
+		})
+}
+
+type nearby struct {
+	db    *sqlite3.Conn
+	table string
+}
+
+func (n *nearby) Destroy() error { return nil }
+
+func (n *nearby) BestIndex(idx *sqlite3.IndexInfo) error {
+	var usage [4]int
+	for i, cst := range idx.Constraint {
+		p := cst.Column - nearbyTable
+		if p >= 0 && cst.Usable && cst.Op == sqlite3.INDEX_CONSTRAINT_EQ && usage[p] == 0 {
+			usage[p] = i + 1
+		}
+	}
+
+	plan := 0
+	posi := 0
+	for p, i := range usage {
+		switch {
+		case i > 0:
+			posi++
+			plan |= 1 << p
+			idx.ConstraintUsage[i-1] = sqlite3.IndexConstraintUsage{
+				ArgvIndex: posi,
+				Omit:      true,
+			}
+		case p != 0 || n.table == "":
+			return sqlite3.CONSTRAINT
+		}
+	}
+
+	if len(idx.OrderBy) == 1 && !idx.OrderBy[0].Desc &&
+		idx.OrderBy[0].Column == nearbyDistance {
+		idx.OrderByConsumed = true
+	}
+	idx.EstimatedCost = 1000
+	idx.IdxNum = plan
+	return nil
+}
+
+func (n *nearby) Open() (sqlite3.VTabCursor, error) {
+	return &nearbyCursor{nearby: n}, nil
+}
+
+type nearbyCursor struct {
+	*nearby
+	args  [4]any
+	rows  []nearbyRow
+	rowID int64
+}
+
+type nearbyRow struct {
+	id       int64
+	distance float64
+}
+
+func (c *nearbyCursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) error {
+	c.args = [4]any{c.table}
+	for p := range c.args {
+		if idxNum&(1<<p) == 0 {
+			continue
+		}
+		switch a := arg[0]; a.Type() {
+		case sqlite3.INTEGER:
+			c.args[p] = a.Int64()
+		case sqlite3.FLOAT:
+			c.args[p] = a.Float()
+		case sqlite3.NULL:
+			c.args[p] = nil
+		default:
+			c.args[p] = a.Text()
+		}
+		arg = arg[1:]
+	}
+	c.rows = c.rows[:0]
+	c.rowID = 0
+
+	table, _ := c.args[0].(string)
+	x, ok1 := number(c.args[1])
+	y, ok2 := number(c.args[2])
+	radius, ok3 := number(c.args[3])
+	if !ok1 || !ok2 || !ok3 {
+		return nil
+	}
+	rows, err := nearbySearch(c.db, table, x, y, radius)
+	if err != nil {
+		return fmt.Errorf("geo_nearby: %w", err)
+	}
+	c.rows = rows
+	return nil
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func (c *nearbyCursor) Column(ctx sqlite3.Context, n int) error {
+	switch n {
+	case nearbyID:
+		ctx.ResultInt64(c.rows[c.rowID].id)
+	case nearbyDistance:
+		ctx.ResultFloat(c.rows[c.rowID].distance)
+	default:
+		switch v := c.args[n-nearbyTable].(type) {
+		case int64:
+			ctx.ResultInt64(v)
+		case float64:
+			ctx.ResultFloat(v)
+		case string:
+			ctx.ResultText(v)
+		}
+	}
+	return nil
+}
+
+func (c *nearbyCursor) Next() error {
+	c.rowID++
+	return nil
+}
+
+func (c *nearbyCursor) EOF() bool {
+	return c.rowID >= int64(len(c.rows))
+}
+
+func (c *nearbyCursor) RowID() (int64, error) {
+	return c.rowID, nil
+}
+
+// nearbySearch finds the entries of a 2D rtree table
+// within a radius, in meters, of a point,
+// ordered by distance.
+func nearbySearch(db *sqlite3.Conn, table string, lon, lat, radius float64) ([]nearbyRow, error) {
+	if math.IsNaN(radius) || radius < 0 {
+		return nil, nil
+	}
+
+	cols, err := rtreeColumns(db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find candidates that overlap the bounding box,
+	// then filter them by distance.
+	var sql strings.Builder
+	fmt.Fprintf(&sql, `SELECT %s, %s, %s, %s, %s FROM %s WHERE %[3]s>=? AND %[2]s<=? AND %[5]s>=? AND %[4]s<=?`,
+		cols[0], cols[1], cols[2], cols[3], cols[4], sqlite3.QuoteIdentifier(table))
+	stmt, _, err := db.PrepareFlags(sql.String(), sqlite3.PREPARE_DONT_LOG)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	minLon, minLat, maxLon, maxLat := RadiusBounds(lon, lat, radius)
+	stmt.BindFloat(1, minLon)
+	stmt.BindFloat(2, maxLon)
+	stmt.BindFloat(3, minLat)
+	stmt.BindFloat(4, maxLat)
+
+	var rows []nearbyRow
+	for stmt.Step() {
+		// Distance to the closest point of the box.
+		x := min(max(lon, stmt.ColumnFloat(1)), stmt.ColumnFloat(2))
+		y := min(max(lat, stmt.ColumnFloat(3)), stmt.ColumnFloat(4))
+		if d := haversine(lon, lat, x, y); d <= radius {
+			rows = append(rows, nearbyRow{stmt.ColumnInt64(0), d})
+		}
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(rows, func(a, b nearbyRow) int {
+		if c := cmp.Compare(a.distance, b.distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	return rows, nil
+}
+
+// rtreeColumns returns the quoted names of the id and
+// coordinate columns of a 2D rtree table.
+func rtreeColumns(db *sqlite3.Conn, table string) ([]string, error) {
+	stmt, _, err := db.Prepare(`SELECT name FROM pragma_table_info(?) ORDER BY cid`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	stmt.BindText(1, table)
+
+	var cols []string
+	for stmt.Step() {
+		cols = append(cols, sqlite3.QuoteIdentifier(stmt.ColumnText(0)))
+	}
+	if err := stmt.Err(); err != nil {
+		return nil, err
+	}
+	if len(cols) < 5 {
+		return nil, fmt.Errorf("%q is not a 2D rtree table", table)
+	}
+	return cols[:5], nil
+}
@@ -0,0 +1,172 @@
+package geo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// https://libgeos.org/specifications/wkb/
+
+// appendWKB appends the little-endian WKB encoding of g to buf.
+func appendWKB(buf []byte, g *geometry) []byte {
+	le := binary.LittleEndian
+	buf = append(buf, 1)
+	buf = le.AppendUint32(buf, uint32(g.kind))
+
+	points := func(ps []point) {
+		buf = le.AppendUint32(buf, uint32(len(ps)))
+		for _, p := range ps {
+			buf = le.AppendUint64(buf, math.Float64bits(p.x))
+			buf = le.AppendUint64(buf, math.Float64bits(p.y))
+		}
+	}
+
+	switch g.kind {
+	case kindPoint:
+		p := point{math.NaN(), math.NaN()}
+		if len(g.coords) > 0 {
+			p = g.coords[0]
+		}
+		buf = le.AppendUint64(buf, math.Float64bits(p.x))
+		buf = le.AppendUint64(buf, math.Float64bits(p.y))
+	case kindLineString:
+		points(g.coords)
+	case kindPolygon:
+		buf = le.AppendUint32(buf, uint32(len(g.rings)))
+		for _, r := range g.rings {
+			points(r)
+		}
+	default:
+		buf = le.AppendUint32(buf, uint32(len(g.parts)))
+		for i := range g.parts {
+			buf = appendWKB(buf, &g.parts[i])
+		}
+	}
+	return buf
+}
+
+type wkbReader struct {
+	buf []byte
+	ord binary.ByteOrder
+}
+
+// parseWKB parses a WKB geometry, in either byte order.
+// Only 2D geometries are supported.
+func parseWKB(buf []byte) (*geometry, error) {
+	r := wkbReader{buf: buf}
+	g, err := r.geometry(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.buf) != 0 {
+		return nil, errGeometry
+	}
+	return g, g.validate()
+}
+
+func (r *wkbReader) geometry(depth int) (*geometry, error) {
+	if depth > 32 || len(r.buf) < 5 {
+		return nil, errGeometry
+	}
+	switch r.buf[0] {
+	case 0:
+		r.ord = binary.BigEndian
+	case 1:
+		r.ord = binary.LittleEndian
+	default:
+		return nil, errGeometry
+	}
+	r.buf = r.buf[1:]
+
+	g := &geometry{kind: kind(r.uint32())}
+	if g.kind < kindPoint || g.kind > kindCollection {
+		return nil, fmt.Errorf("unsupported WKB geometry type: %d", g.kind)
+	}
+
+	var err error
+	switch g.kind {
+	case kindPoint:
+		p, ok := r.point()
+		if !ok {
+			return nil, errGeometry
+		}
+		if !math.IsNaN(p.x) || !math.IsNaN(p.y) {
+			g.coords = []point{p}
+		}
+	case kindLineString:
+		g.coords, err = r.points()
+	case kindPolygon:
+		n, err := r.count(4)
+		if err != nil {
+			return nil, err
+		}
+		for range n {
+			ring, err := r.points()
+			if err != nil {
+				return nil, err
+			}
+			g.rings = append(g.rings, ring)
+		}
+	default:
+		n, err := r.count(9)
+		if err != nil {
+			return nil, err
+		}
+		for range n {
+			part, err := r.geometry(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			g.parts = append(g.parts, *part)
+		}
+	}
+	return g, err
+}
+
+func (r *wkbReader) uint32() uint32 {
+	if len(r.buf) < 4 {
+		r.buf = nil
+		return 0
+	}
+	v := r.ord.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+// count reads a count of elements, each at least size bytes.
+func (r *wkbReader) count(size int) (int, error) {
+	if len(r.buf) < 4 {
+		return 0, errGeometry
+	}
+	n := r.uint32()
+	if uint64(n)*uint64(size) > uint64(len(r.buf)) {
+		return 0, errGeometry
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) point() (point, bool) {
+	if len(r.buf) < 16 {
+		r.buf = nil
+		return point{}, false
+	}
+	p := point{
+		math.Float64frombits(r.ord.Uint64(r.buf[0:])),
+		math.Float64frombits(r.ord.Uint64(r.buf[8:])),
+	}
+	r.buf = r.buf[16:]
+	return p, true
+}
+
+func (r *wkbReader) points() ([]point, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	ps := make([]point, n)
+	for i := range ps {
+		ps[i], _ = r.point()
+	}
+	return ps, nil
+}
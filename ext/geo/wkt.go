@@ -0,0 +1,235 @@
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// https://libgeos.org/specifications/wkt/
+
+func appendWKT(buf []byte, g *geometry) []byte {
+	buf = append(buf, strings.ToUpper(g.kind.String())...)
+	if g.empty() {
+		return append(buf, " EMPTY"...)
+	}
+	buf = append(buf, ' ')
+	return appendWKTBody(buf, g)
+}
+
+func appendWKTBody(buf []byte, g *geometry) []byte {
+	points := func(ps []point) {
+		buf = append(buf, '(')
+		for i, p := range ps {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = appendCoord(buf, p)
+		}
+		buf = append(buf, ')')
+	}
+
+	switch g.kind {
+	case kindPoint, kindLineString:
+		points(g.coords)
+	case kindPolygon:
+		buf = append(buf, '(')
+		for i, r := range g.rings {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			points(r)
+		}
+		buf = append(buf, ')')
+	default:
+		buf = append(buf, '(')
+		for i := range g.parts {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			p := &g.parts[i]
+			switch {
+			case g.kind == kindCollection:
+				buf = appendWKT(buf, p)
+			case p.empty():
+				buf = append(buf, "EMPTY"...)
+			default:
+				buf = appendWKTBody(buf, p)
+			}
+		}
+		buf = append(buf, ')')
+	}
+	return buf
+}
+
+func appendCoord(buf []byte, p point) []byte {
+	buf = strconv.AppendFloat(buf, p.x, 'f', -1, 64)
+	buf = append(buf, ' ')
+	return strconv.AppendFloat(buf, p.y, 'f', -1, 64)
+}
+
+type wktParser struct {
+	s string
+}
+
+// parseWKT parses a WKT geometry.
+// Only 2D geometries are supported.
+func parseWKT(s string) (*geometry, error) {
+	p := wktParser{s}
+	g, err := p.geometry(0)
+	if tok := p.next(); err == nil && tok != "" {
+		err = p.errorf("unexpected %q", tok)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, g.validate()
+}
+
+func (p *wktParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("invalid WKT: "+format, a...)
+}
+
+// peek returns the next token: a word, a number or punctuation.
+func (p *wktParser) peek() string {
+	p.s = strings.TrimLeft(p.s, " \t\n\r")
+	if p.s == "" {
+		return ""
+	}
+	switch p.s[0] {
+	case '(', ')', ',':
+		return p.s[:1]
+	}
+	i := strings.IndexAny(p.s, " \t\n\r(),")
+	if i < 0 {
+		i = len(p.s)
+	}
+	return p.s[:i]
+}
+
+func (p *wktParser) next() string {
+	tok := p.peek()
+	p.s = p.s[len(tok):]
+	return tok
+}
+
+func (p *wktParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return p.errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+func (p *wktParser) geometry(depth int) (*geometry, error) {
+	if depth > 32 {
+		return nil, p.errorf("too deeply nested")
+	}
+	tok := p.next()
+	var g geometry
+	for k := kindPoint; k <= kindCollection; k++ {
+		if strings.EqualFold(tok, k.String()) {
+			g.kind = k
+		}
+	}
+	if g.kind == 0 {
+		return nil, p.errorf("unknown geometry type %q", tok)
+	}
+	if strings.EqualFold(p.peek(), "EMPTY") {
+		p.next()
+		return &g, nil
+	}
+	return &g, p.body(&g, depth)
+}
+
+func (p *wktParser) body(g *geometry, depth int) (err error) {
+	switch g.kind {
+	case kindPoint:
+		g.coords, err = p.points()
+		if err == nil && len(g.coords) != 1 {
+			err = p.errorf("point must have one coordinate")
+		}
+		return err
+	case kindLineString:
+		g.coords, err = p.points()
+		return err
+	case kindPolygon:
+		return p.list(func() error {
+			ring, err := p.points()
+			g.rings = append(g.rings, ring)
+			return err
+		})
+	}
+
+	return p.list(func() error {
+		if g.kind == kindCollection {
+			part, err := p.geometry(depth + 1)
+			if err == nil {
+				g.parts = append(g.parts, *part)
+			}
+			return err
+		}
+
+		part := geometry{kind: g.kind.part()}
+		switch {
+		case strings.EqualFold(p.peek(), "EMPTY"):
+			p.next()
+		case part.kind == kindPoint && p.peek() != "(":
+			// MULTIPOINT (1 2, 3 4)
+			pt, err := p.coord()
+			if err != nil {
+				return err
+			}
+			part.coords = []point{pt}
+		default:
+			if err := p.body(&part, depth+1); err != nil {
+				return err
+			}
+		}
+		g.parts = append(g.parts, part)
+		return nil
+	})
+}
+
+// list parses a parenthesized, comma separated list,
+// calling fn for each element.
+func (p *wktParser) list(fn func() error) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		if err := fn(); err != nil {
+			return err
+		}
+		switch tok := p.next(); tok {
+		case ",":
+			continue
+		case ")":
+			return nil
+		default:
+			return p.errorf("expected \")\", got %q", tok)
+		}
+	}
+}
+
+func (p *wktParser) points() ([]point, error) {
+	var ps []point
+	err := p.list(func() error {
+		pt, err := p.coord()
+		ps = append(ps, pt)
+		return err
+	})
+	return ps, err
+}
+
+func (p *wktParser) coord() (point, error) {
+	x, err1 := strconv.ParseFloat(p.next(), 64)
+	y, err2 := strconv.ParseFloat(p.next(), 64)
+	if err1 != nil || err2 != nil {
+		return point{}, p.errorf("invalid coordinate")
+	}
+	switch p.peek() {
+	case ",", ")", "":
+		return point{x, y}, nil
+	}
+	return point{}, p.errorf("only 2D coordinates are supported")
+}
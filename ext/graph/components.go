@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// connectedComponents finds the weakly connected components of the graph,
+// returning a row for each node, with the smallest node id
+// in the component as the component id.
+var connectedComponents = &algorithm{
+	name:    "connected_components",
+	columns: []string{"node INT", "component INT"},
+	params:  []param{paramTable, paramFrom, paramTo},
+	run:     runConnectedComponents,
+}
+
+func runConnectedComponents(g *graph, arg args) ([]row, error) {
+	// Union-find, with path halving.
+	// The root of each set is its smallest node.
+	parent := map[int64]int64{}
+	find := func(n int64) int64 {
+		p, ok := parent[n]
+		if !ok {
+			parent[n] = n
+			return n
+		}
+		for p != n {
+			gp := parent[p]
+			parent[n] = gp
+			n, p = p, gp
+		}
+		return n
+	}
+
+	err := g.loadEdges(arg, func(from, to int64, _ float64) error {
+		a, b := find(from), find(to)
+		if a < b {
+			parent[b] = a
+		} else {
+			parent[a] = b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]row, 0, len(parent))
+	for n := range parent {
+		rows = append(rows, row{n, find(n)})
+	}
+	slices.SortFunc(rows, func(a, b row) int {
+		return cmp.Or(
+			cmp.Compare(a[1].(int64), b[1].(int64)),
+			cmp.Compare(a[0].(int64), b[0].(int64)))
+	})
+	return rows, nil
+}
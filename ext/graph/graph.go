@@ -0,0 +1,312 @@
+// Package graph provides graph algorithm virtual tables.
+//
+// Each virtual table runs a graph algorithm over the edges
+// of a real table, with a source and a target column
+// (and optionally a weight column) of INTEGER node ids:
+//   - shortest_path: weighted shortest path between two nodes (Dijkstra)
+//   - connected_components: (weakly) connected components
+//   - topological_sort: topological order, failing on cycles
+//   - pagerank: PageRank of each node
+//
+// Like transitive_closure, the edge table and its columns
+// can be given when the virtual table is created,
+// or as hidden columns (table-valued function arguments):
+//
+//	CREATE TABLE deps (pkg INTEGER, dep INTEGER);
+//	SELECT * FROM topological_sort('deps', 'pkg', 'dep');
+//
+//	CREATE VIRTUAL TABLE temp.build USING topological_sort(
+//		tablename = deps, fromcolumn = pkg, tocolumn = dep
+//	);
+//	SELECT * FROM build;
+//
+// Edges with non-INTEGER nodes, or (if there is a weight column)
+// non-numeric weights, are ignored.
+// Edges are directed, except for connected_components.
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/sql3util"
+)
+
+// Register registers the graph algorithm virtual tables.
+//
+// https://sqlite.org/vtab.html#tabfunc2
+func Register(db *sqlite3.Conn) error {
+	return errors.Join(
+		register(db, shortestPath),
+		register(db, connectedComponents),
+		register(db, topologicalSort),
+		register(db, pageRank))
+}
+
+// An algorithm is a graph algorithm virtual table.
+type algorithm struct {
+	name    string
+	columns []string // visible columns
+	params  []param  // hidden columns
+	run     func(g *graph, arg args) ([]row, error)
+}
+
+type param struct {
+	name     string
+	required bool
+	config   bool // can be set at CREATE VIRTUAL TABLE
+}
+
+// Parameters of all the algorithms.
+var (
+	paramTable  = param{name: "tablename", required: true, config: true}
+	paramFrom   = param{name: "fromcolumn", required: true, config: true}
+	paramTo     = param{name: "tocolumn", required: true, config: true}
+	paramWeight = param{name: "weightcolumn", config: true}
+)
+
+type row []any
+
+// args are the values of the hidden columns:
+// nil (if missing), int64, float64 or string.
+type args []any
+
+func (a args) text(i int) string {
+	if s, ok := a[i].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func (a args) float(i int, def float64) float64 {
+	switch v := a[i].(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return def
+}
+
+func register(db *sqlite3.Conn, alg *algorithm) error {
+	return sqlite3.CreateModule(db, alg.name, nil,
+		func(db *sqlite3.Conn, _, _, _ string, arg ...string) (*graph, error) {
+			g := &graph{db: db, alg: alg, config: make(args, len(alg.params))}
+
+			for _, arg := range arg {
+				key, val := sql3util.NamedArg(arg)
+				i := alg.param(key)
+				if i < 0 || !alg.params[i].config {
+					return nil, fmt.Errorf("%s: unknown %q parameter", alg.name, key)
+				}
+				if g.config[i] != nil {
+					return nil, fmt.Errorf("%s: more than one %q parameter", alg.name, key)
+				}
+				g.config[i] = sql3util.Unquote(val)
+			}
+
+			var sql strings.Builder
+			sql.WriteString(`CREATE TABLE x(`)
+			sql.WriteString(strings.Join(alg.columns, ","))
+			for _, p := range alg.params {
+				sql.WriteString(",")
+				sql.WriteString(p.name)
+				sql.WriteString(" HIDDEN")
+			}
+			sql.WriteString(`)`)
+			err := db.DeclareVTab(sql.String())
+			if err != nil {
+				return nil, err
+			}
+			return g, nil
+		})
+}
+
+func (alg *algorithm) param(name string) int {
+	for i, p := range alg.params {
+		if strings.EqualFold(p.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+type graph struct {
+	db     *sqlite3.Conn
+	alg    *algorithm
+	config args
+}
+
+func (g *graph) Destroy() error { return nil }
+
+func (g *graph) BestIndex(idx *sqlite3.IndexInfo) error {
+	nout := len(g.alg.columns)
+	usage := make([]int, len(g.alg.params))
+	for i := range usage {
+		usage[i] = -1
+	}
+
+	plan := 0
+	for i, cst := range idx.Constraint {
+		p := cst.Column - nout
+		if p >= 0 && cst.Usable && cst.Op == sqlite3.INDEX_CONSTRAINT_EQ && usage[p] < 0 {
+			usage[p] = i
+			plan |= 1 << p
+		}
+	}
+
+	// Arguments are passed to Filter in parameter order.
+	posi := 0
+	cost := 1e7
+	for p, i := range usage {
+		if i >= 0 {
+			posi++
+			cost /= 10
+			idx.ConstraintUsage[i] = sqlite3.IndexConstraintUsage{
+				ArgvIndex: posi,
+				Omit:      true,
+			}
+		} else if g.alg.params[p].required && g.config[p] == nil {
+			return sqlite3.CONSTRAINT
+		}
+	}
+
+	idx.EstimatedCost = cost
+	idx.IdxNum = plan
+	return nil
+}
+
+func (g *graph) Open() (sqlite3.VTabCursor, error) {
+	return &cursor{graph: g}, nil
+}
+
+type cursor struct {
+	*graph
+	args  args
+	rows  []row
+	rowID int64
+}
+
+func (c *cursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) error {
+	c.args = append(c.args[:0], c.config...)
+	for plan := uint(idxNum); plan != 0; plan &= plan - 1 {
+		p := bits.TrailingZeros(plan)
+		switch a := arg[0]; a.Type() {
+		case sqlite3.INTEGER:
+			c.args[p] = a.Int64()
+		case sqlite3.FLOAT:
+			c.args[p] = a.Float()
+		case sqlite3.NULL:
+			c.args[p] = nil
+		default:
+			c.args[p] = a.Text()
+		}
+		arg = arg[1:]
+	}
+
+	rows, err := c.alg.run(c.graph, c.args)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.alg.name, err)
+	}
+	c.rows = rows
+	c.rowID = 0
+	return nil
+}
+
+func (c *cursor) Column(ctx sqlite3.Context, n int) error {
+	var val any
+	if nout := len(c.alg.columns); n < nout {
+		val = c.rows[c.rowID][n]
+	} else {
+		val = c.args[n-nout]
+	}
+	switch v := val.(type) {
+	case int64:
+		ctx.ResultInt64(v)
+	case float64:
+		ctx.ResultFloat(v)
+	case string:
+		ctx.ResultText(v)
+	}
+	return nil
+}
+
+func (c *cursor) Next() error {
+	c.rowID++
+	return nil
+}
+
+func (c *cursor) EOF() bool {
+	return c.rowID >= int64(len(c.rows))
+}
+
+func (c *cursor) RowID() (int64, error) {
+	return c.rowID, nil
+}
+
+// edges returns a statement that selects edges, optionally starting from a node.
+func (g *graph) edges(arg args, from bool) (*sqlite3.Stmt, error) {
+	const (
+		table = iota
+		source
+		target
+		weight
+	)
+	names := make([]string, 4)
+	for i, p := range []param{paramTable, paramFrom, paramTo, paramWeight} {
+		if j := g.alg.param(p.name); j >= 0 {
+			names[i] = arg.text(j)
+		}
+		if names[i] == "" && p.required {
+			return nil, fmt.Errorf("missing %s", p.name)
+		}
+	}
+
+	var sql strings.Builder
+	fmt.Fprintf(&sql, `SELECT %s, %s`,
+		sqlite3.QuoteIdentifier(names[source]),
+		sqlite3.QuoteIdentifier(names[target]))
+	if names[weight] != "" {
+		fmt.Fprintf(&sql, `, %s`, sqlite3.QuoteIdentifier(names[weight]))
+	} else {
+		sql.WriteString(`, 1`)
+	}
+	fmt.Fprintf(&sql, ` FROM %s`, sqlite3.QuoteIdentifier(names[table]))
+	if from {
+		fmt.Fprintf(&sql, ` WHERE %s=?`, sqlite3.QuoteIdentifier(names[source]))
+	}
+
+	stmt, _, err := g.db.PrepareFlags(sql.String(), sqlite3.PREPARE_DONT_LOG)
+	return stmt, err
+}
+
+// edge reads an edge from a statement prepared by edges.
+func edge(stmt *sqlite3.Stmt) (from, to int64, weight float64, ok bool) {
+	if stmt.ColumnType(0) != sqlite3.INTEGER ||
+		stmt.ColumnType(1) != sqlite3.INTEGER ||
+		stmt.ColumnType(2) != sqlite3.INTEGER && stmt.ColumnType(2) != sqlite3.FLOAT {
+		return 0, 0, 0, false
+	}
+	return stmt.ColumnInt64(0), stmt.ColumnInt64(1), stmt.ColumnFloat(2), true
+}
+
+// loadEdges reads all edges.
+func (g *graph) loadEdges(arg args, fn func(from, to int64, weight float64) error) error {
+	stmt, err := g.edges(arg, false)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		if from, to, weight, ok := edge(stmt); ok {
+			if err := fn(from, to, weight); err != nil {
+				return err
+			}
+		}
+	}
+	return stmt.Err()
+}
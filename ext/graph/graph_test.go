@@ -0,0 +1,150 @@
+package graph_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/graph"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestMain(m *testing.M) {
+	sqlite3.AutoExtension(graph.Register)
+	os.Exit(m.Run())
+}
+
+func Example() {
+	db, err := sqlite3.Open(":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE roads (src INTEGER, dst INTEGER, km REAL);
+		CREATE INDEX roads_src_idx ON roads(src);
+		INSERT INTO roads VALUES
+			(1, 2, 7), (1, 3, 9), (1, 6, 14),
+			(2, 3, 10), (2, 4, 15),
+			(3, 4, 11), (3, 6, 2),
+			(4, 5, 6), (6, 5, 9);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT node, cost FROM shortest_path(1, 5, 'roads', 'src', 'dst', 'km')
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stmt.Close()
+
+	for stmt.Step() {
+		fmt.Println(stmt.ColumnInt(0), stmt.ColumnFloat(1))
+	}
+	if err := stmt.Err(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// 1 0
+	// 3 9
+	// 6 11
+	// 5 20
+}
+
+func query(t *testing.T, db *sqlite3.Conn, sql string) string {
+	t.Helper()
+
+	stmt, _, err := db.Prepare(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var rows []string
+	for stmt.Step() {
+		var cols []string
+		for i := range stmt.ColumnCount() {
+			cols = append(cols, stmt.ColumnText(i))
+		}
+		rows = append(rows, strings.Join(cols, ":"))
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(rows, " ")
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE deps (pkg, dep);
+		INSERT INTO deps VALUES
+			(1, 2), (1, 3), (2, 4), (3, 4), (4, 5),
+			(10, 11), (12, 11), (NULL, 1), ('x', 'y');
+		CREATE VIRTUAL TABLE temp.build USING topological_sort(
+			tablename = deps, fromcolumn = pkg, tocolumn = dep
+		);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`SELECT * FROM build`, "1:0 10:0 12:0 2:1 3:1 11:1 4:2 5:3"},
+		{`SELECT node, level FROM topological_sort('deps', 'pkg', 'dep') WHERE level > 1`, "4:2 5:3"},
+		{`SELECT * FROM connected_components('deps', 'pkg', 'dep')`, "1:1 2:1 3:1 4:1 5:1 10:10 11:10 12:10"},
+		{`SELECT * FROM shortest_path(1, 5, 'deps', 'pkg', 'dep')`, "0:1:0.0 1:2:1.0 2:4:2.0 3:5:3.0"},
+		{`SELECT * FROM shortest_path(5, 1, 'deps', 'pkg', 'dep')`, ""},
+		{`SELECT * FROM shortest_path(1, 1, 'deps', 'pkg', 'dep')`, "0:1:0.0"},
+		{`SELECT node FROM pagerank('deps', 'pkg', 'dep') LIMIT 2`, "5 4"},
+		{`SELECT round(sum(rank), 6) FROM pagerank('deps', 'pkg', 'dep')`, "1.0"},
+		{`SELECT node FROM pagerank WHERE tablename = 'deps' AND fromcolumn = 'pkg' AND tocolumn = 'dep' AND damping = 0 LIMIT 1`, "1"},
+	}
+	for _, tt := range tests {
+		if got := query(t, db, tt.sql); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	err = db.Exec(`INSERT INTO deps VALUES (5, 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sql := range []string{
+		`SELECT * FROM build`,
+		`SELECT * FROM topological_sort('deps', 'pkg')`,
+		`SELECT * FROM shortest_path(1, 5, 'deps', 'pkg', 'dep', 'nope')`,
+		`SELECT * FROM pagerank('deps', 'pkg', 'dep') WHERE damping = 1`,
+		`CREATE VIRTUAL TABLE temp.bad USING pagerank(start = 1)`,
+	} {
+		stmt, _, err := db.Prepare(sql)
+		if err == nil {
+			if stmt.Step() {
+				t.Errorf("%s: want error", sql)
+			}
+			err = stmt.Err()
+			stmt.Close()
+		}
+		if err == nil {
+			t.Errorf("%s: want error", sql)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// pageRank computes the PageRank of each node,
+// returning a row for each node, ordered by decreasing rank.
+// Ranks add up to 1.
+// With a weight column, rank flows in proportion to edge weights.
+//
+// The damping factor defaults to 0.85.
+var pageRank = &algorithm{
+	name:    "pagerank",
+	columns: []string{"node INT", "rank REAL"},
+	params: []param{
+		paramTable, paramFrom, paramTo, paramWeight,
+		{name: "damping"},
+	},
+	run: runPageRank,
+}
+
+const (
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-10
+)
+
+func runPageRank(g *graph, arg args) ([]row, error) {
+	damping := arg.float(4, 0.85)
+	if !(0 <= damping && damping < 1) {
+		return nil, fmt.Errorf("invalid damping: %g", damping)
+	}
+
+	type link struct {
+		from, to int
+		weight   float64
+	}
+	index := map[int64]int{}
+	var nodes []int64
+	node := func(n int64) int {
+		i, ok := index[n]
+		if !ok {
+			i = len(nodes)
+			index[n] = i
+			nodes = append(nodes, n)
+		}
+		return i
+	}
+
+	var links []link
+	err := g.loadEdges(arg, func(from, to int64, weight float64) error {
+		if weight < 0 || math.IsNaN(weight) {
+			return fmt.Errorf("negative weight: %g", weight)
+		}
+		links = append(links, link{node(from), node(to), weight})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	out := make([]float64, len(nodes))
+	for _, l := range links {
+		out[l.from] += l.weight
+	}
+
+	n := float64(len(nodes))
+	rank := make([]float64, len(nodes))
+	next := make([]float64, len(nodes))
+	for i := range rank {
+		rank[i] = 1 / n
+	}
+
+	for range pageRankIterations {
+		// Rank from dangling nodes is spread evenly.
+		var dangling float64
+		for i, r := range rank {
+			if out[i] == 0 {
+				dangling += r
+			}
+		}
+		base := (1-damping)/n + damping*dangling/n
+		for i := range next {
+			next[i] = base
+		}
+		for _, l := range links {
+			if w := out[l.from]; w != 0 {
+				next[l.to] += damping * rank[l.from] * l.weight / w
+			}
+		}
+
+		var delta float64
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+
+	rows := make([]row, len(nodes))
+	for i, n := range nodes {
+		rows[i] = row{n, rank[i]}
+	}
+	slices.SortFunc(rows, func(a, b row) int {
+		return cmp.Or(
+			cmp.Compare(b[1].(float64), a[1].(float64)),
+			cmp.Compare(a[0].(int64), b[0].(int64)))
+	})
+	return rows, nil
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// shortestPath finds a least-cost path from start to goal,
+// returning a row for each node in the path,
+// with its step number and the cumulative cost.
+// It returns no rows if goal is unreachable.
+// Without a weight column, each edge costs 1.
+//
+// Edges are followed lazily from the start node,
+// so an index on the source column is recommended.
+var shortestPath = &algorithm{
+	name:    "shortest_path",
+	columns: []string{"step INT", "node INT", "cost REAL"},
+	params: []param{
+		{name: "start", required: true},
+		{name: "goal", required: true},
+		paramTable, paramFrom, paramTo, paramWeight,
+	},
+	run: runShortestPath,
+}
+
+func runShortestPath(g *graph, arg args) ([]row, error) {
+	start, ok1 := arg[0].(int64)
+	goal, ok2 := arg[1].(int64)
+	if !ok1 || !ok2 {
+		return nil, nil
+	}
+
+	stmt, err := g.edges(arg, true)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	type visit struct {
+		prev int64
+		cost float64
+		done bool
+	}
+	nodes := map[int64]*visit{start: {prev: start}}
+	queue := &pathQueue{{start, 0}}
+
+	for queue.Len() > 0 {
+		curr := heap.Pop(queue).(pathItem)
+		v := nodes[curr.node]
+		if v.done {
+			continue
+		}
+		v.done = true
+		if curr.node == goal {
+			break
+		}
+
+		if err := stmt.BindInt64(1, curr.node); err != nil {
+			return nil, err
+		}
+		for stmt.Step() {
+			_, next, weight, ok := edge(stmt)
+			if !ok {
+				continue
+			}
+			if weight < 0 || math.IsNaN(weight) {
+				return nil, fmt.Errorf("negative weight: %g", weight)
+			}
+			cost := curr.cost + weight
+			if n, ok := nodes[next]; !ok {
+				nodes[next] = &visit{prev: curr.node, cost: cost}
+			} else if !n.done && cost < n.cost {
+				n.prev, n.cost = curr.node, cost
+			} else {
+				continue
+			}
+			heap.Push(queue, pathItem{next, cost})
+		}
+		if err := stmt.Reset(); err != nil {
+			return nil, err
+		}
+	}
+
+	v := nodes[goal]
+	if v == nil || !v.done {
+		return nil, nil
+	}
+	var rows []row
+	for node := goal; ; node = nodes[node].prev {
+		rows = append(rows, row{nil, node, nodes[node].cost})
+		if node == start {
+			break
+		}
+	}
+	slices.Reverse(rows)
+	for i := range rows {
+		rows[i][0] = int64(i)
+	}
+	return rows, nil
+}
+
+type pathItem struct {
+	node int64
+	cost float64
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	return q[i].node < q[j].node
+}
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any)   { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() any {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+)
+
+// topologicalSort orders the nodes of the graph,
+// such that every edge goes from an earlier node to a later one.
+// It returns a row for each node, with its level:
+// the length of the longest path that reaches the node.
+// Nodes are ordered by level, then by node id.
+// If the graph has a cycle, it fails.
+var topologicalSort = &algorithm{
+	name:    "topological_sort",
+	columns: []string{"node INT", "level INT"},
+	params:  []param{paramTable, paramFrom, paramTo},
+	run:     runTopologicalSort,
+}
+
+func runTopologicalSort(g *graph, arg args) ([]row, error) {
+	indegree := map[int64]int{}
+	edges := map[int64][]int64{}
+	err := g.loadEdges(arg, func(from, to int64, _ float64) error {
+		edges[from] = append(edges[from], to)
+		indegree[to]++
+		if _, ok := indegree[from]; !ok {
+			indegree[from] = 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Kahn's algorithm, one level at a time.
+	var level []int64
+	for n, d := range indegree {
+		if d == 0 {
+			level = append(level, n)
+		}
+	}
+
+	rows := make([]row, 0, len(indegree))
+	for depth := int64(0); len(level) > 0; depth++ {
+		slices.Sort(level)
+		var next []int64
+		for _, n := range level {
+			rows = append(rows, row{n, depth})
+			for _, m := range edges[n] {
+				indegree[m]--
+				if indegree[m] == 0 {
+					next = append(next, m)
+				}
+			}
+		}
+		level = next
+	}
+
+	if len(rows) < len(indegree) {
+		var cycle []int64
+		for n, d := range indegree {
+			if d > 0 {
+				cycle = append(cycle, n)
+			}
+		}
+		slices.Sort(cycle)
+		return nil, fmt.Errorf("graph has a cycle, involving node %d", cycle[0])
+	}
+	return rows, nil
+}
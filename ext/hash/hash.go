@@ -12,11 +12,20 @@
 //   - blake2s(data)
 //   - blake2b(data, size) (default size 512)
 //   - ripemd160(data)
+//   - hmac_md5(data, key)
+//   - hmac_sha1(data, key)
+//   - hmac_sha224(data, key)
+//   - hmac_sha256(data, key)
+//   - hmac_sha384(data, key)
+//   - hmac_sha512(data, key)
 //
 // Each SQL function will only be registered if the corresponding
 // [crypto.Hash] function is available.
 // To ensure a specific hash function is available,
 // import the implementing package.
+//
+// HMAC keys are passed as is, and can be blobs or text;
+// use bound parameters to keep keys out of SQL text.
 package hash
 
 import (
@@ -38,11 +47,13 @@ func Register(db *sqlite3.Conn) error {
 	}
 	if crypto.MD5.Available() {
 		errs.Join(
-			db.CreateFunction("md5", 1, flags, md5Func))
+			db.CreateFunction("md5", 1, flags, md5Func),
+			db.CreateFunction("hmac_md5", 2, flags, hmacMD5Func))
 	}
 	if crypto.SHA1.Available() {
 		errs.Join(
-			db.CreateFunction("sha1", 1, flags, sha1Func))
+			db.CreateFunction("sha1", 1, flags, sha1Func),
+			db.CreateFunction("hmac_sha1", 2, flags, hmacSHA1Func))
 	}
 	if crypto.SHA3_512.Available() {
 		errs.Join(
@@ -53,13 +64,17 @@ func Register(db *sqlite3.Conn) error {
 		errs.Join(
 			db.CreateFunction("sha224", 1, flags, sha224Func),
 			db.CreateFunction("sha256", 1, flags, sha256Func),
-			db.CreateFunction("sha256", 2, flags, sha256Func))
+			db.CreateFunction("sha256", 2, flags, sha256Func),
+			db.CreateFunction("hmac_sha224", 2, flags, hmacSHA224Func),
+			db.CreateFunction("hmac_sha256", 2, flags, hmacSHA256Func))
 	}
 	if crypto.SHA512.Available() {
 		errs.Join(
 			db.CreateFunction("sha384", 1, flags, sha384Func),
 			db.CreateFunction("sha512", 1, flags, sha512Func),
-			db.CreateFunction("sha512", 2, flags, sha512Func))
+			db.CreateFunction("sha512", 2, flags, sha512Func),
+			db.CreateFunction("hmac_sha384", 2, flags, hmacSHA384Func),
+			db.CreateFunction("hmac_sha512", 2, flags, hmacSHA512Func))
 	}
 	if crypto.BLAKE2s_256.Available() {
 		errs.Join(
@@ -52,6 +52,16 @@ func TestRegister(t *testing.T) {
 		{"blake2b('')", "786A02F742015903C6C6FD852552D272912F4740E15847618A86E217F71F5419D25E1031AFEE585313896444934EB04B903A685B1448B755D56F701AFE9BE2CE"},
 		{"blake2b('', 384)", "B32811423377F52D7862286EE1A72EE540524380FDA1724A6F25D7978C6FD3244A6CAF0498812673C5E05EF583825100"},
 		{"blake2b('', 256)", "0E5751C026E543B2E8AB2EB06099DAA1D1E5DF47778F7787FAAB45CDF12FE3A8"},
+
+		{"hmac_sha256(NULL, 'Jefe')", ""},
+		{"hmac_sha256('what do ya want for nothing?', NULL)", ""},
+		{"hmac_md5('what do ya want for nothing?', 'Jefe')", "750C783E6AB0B503EAA86E310A5DB738"},
+		{"hmac_sha1('what do ya want for nothing?', 'Jefe')", "EFFCDF6AE5EB2FA2D27416D5F184DF9C259A7C79"},
+		{"hmac_sha224('what do ya want for nothing?', 'Jefe')", "A30E01098BC6DBBF45690F3A7E9E6D0F8BBEA2A39E6148008FD05E44"},
+		{"hmac_sha256('what do ya want for nothing?', 'Jefe')", "5BDCC146BF60754E6A042426089575C75A003F089D2739839DEC58B964EC3843"},
+		{"hmac_sha256('what do ya want for nothing?', CAST('Jefe' AS BLOB))", "5BDCC146BF60754E6A042426089575C75A003F089D2739839DEC58B964EC3843"},
+		{"hmac_sha384('what do ya want for nothing?', 'Jefe')", "AF45D2E376484031617F78D2B58A6B1B9C7EF464F5A01B47E42EC3736322445E8E2240CA5E69E2C78B3239ECFAB21649"},
+		{"hmac_sha512('what do ya want for nothing?', 'Jefe')", "164B7A7BFCF819E2E395FBE73B56E0A387BD64222E831FD610270CD7EA2505549758BF75C05A994A6D034F65F8F0E6FDCAEAB1A34D4A6B4B636E070A38BCE737"},
 	}
 
 	ctx := testcfg.Context(t)
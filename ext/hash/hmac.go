@@ -0,0 +1,49 @@
+package hash
+
+import (
+	"crypto"
+	"crypto/hmac"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+func hmacMD5Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.MD5)
+}
+
+func hmacSHA1Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.SHA1)
+}
+
+func hmacSHA224Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.SHA224)
+}
+
+func hmacSHA256Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.SHA256)
+}
+
+func hmacSHA384Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.SHA384)
+}
+
+func hmacSHA512Func(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	hmacFunc(ctx, arg[0], arg[1], crypto.SHA512)
+}
+
+func hmacFunc(ctx sqlite3.Context, arg, key sqlite3.Value, fn crypto.Hash) {
+	if arg.Type() == sqlite3.NULL || key.Type() == sqlite3.NULL {
+		return
+	}
+
+	h := hmac.New(fn.New, valueBytes(key))
+	h.Write(valueBytes(arg))
+	ctx.ResultBlob(h.Sum(nil))
+}
+
+func valueBytes(arg sqlite3.Value) []byte {
+	if arg.Type() == sqlite3.BLOB {
+		return arg.RawBlob()
+	}
+	return arg.RawText()
+}
@@ -0,0 +1,285 @@
+// Package ndjson provides a virtual table to read newline-delimited JSON.
+//
+// The ndjson virtual table reads [ndjson] or [JSON Lines] formatted data,
+// one JSON document per line, possibly gzip-compressed,
+// and maps values selected by JSON paths to typed columns.
+// The raw document is available in the hidden column _json.
+//
+// Columns are configured with column arguments,
+// each specifying a column name, an optional type, and an optional path
+// (which defaults to the top-level key with the column name):
+//
+//	CREATE VIRTUAL TABLE logs USING ndjson(
+//		filename = 'app.ndjson.gz',
+//		column   = 'time INTEGER $.ts',
+//		column   = 'level TEXT $.log.level',
+//		column   = 'msg',
+//	);
+//
+// If no columns are given, the schema is inferred
+// from the top-level keys of the first records (100, or sample).
+//
+// [ndjson]: https://ndjson.org/
+// [JSON Lines]: https://jsonlines.org/
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+	"github.com/ncruces/go-sqlite3/util/osutil"
+	"github.com/ncruces/go-sqlite3/util/sql3util"
+)
+
+// Register registers the ndjson virtual table.
+// If a filename is specified, [os.Open] is used to open the file.
+func Register(db *sqlite3.Conn) error {
+	return RegisterFS(db, osutil.FS{})
+}
+
+// RegisterFS registers the ndjson virtual table.
+// If a filename is specified, fsys is used to open the file.
+func RegisterFS(db *sqlite3.Conn, fsys fs.FS) error {
+	declare := func(db *sqlite3.Conn, _, _, _ string, arg ...string) (_ *table, err error) {
+		var (
+			filename string
+			data     string
+			sample   int = 100
+			columns  []column
+
+			done = map[string]struct{}{}
+		)
+
+		for _, arg := range arg {
+			key, val := sql3util.NamedArg(arg)
+			if _, ok := done[key]; ok && key != "column" {
+				return nil, fmt.Errorf("ndjson: more than one %q parameter", key)
+			}
+			switch key {
+			case "filename":
+				filename = sql3util.Unquote(val)
+			case "data":
+				data = sql3util.Unquote(val)
+			case "sample":
+				sample, err = uintArg(key, val)
+			case "column":
+				var col column
+				col, err = parseColumn(sql3util.Unquote(val))
+				columns = append(columns, col)
+			default:
+				return nil, fmt.Errorf("ndjson: unknown %q parameter", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+			done[key] = struct{}{}
+		}
+
+		if (filename == "") == (data == "") {
+			return nil, errutil.ErrorString(`ndjson: must specify either "filename" or "data" but not both`)
+		}
+
+		t := &table{
+			fsys: fsys,
+			name: filename,
+			data: data,
+		}
+
+		if columns == nil {
+			columns, err = t.inferColumns(sample)
+			if err != nil {
+				return nil, err
+			}
+		}
+		t.cols = columns
+
+		err = db.DeclareVTab(getSchema(columns))
+		if err == nil {
+			err = db.VTabConfig(sqlite3.VTAB_DIRECTONLY)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	return sqlite3.CreateModule(db, "ndjson", declare, declare)
+}
+
+type table struct {
+	fsys fs.FS
+	name string
+	data string
+	cols []column
+}
+
+func (t *table) BestIndex(idx *sqlite3.IndexInfo) error {
+	idx.EstimatedCost = 1e6
+	return nil
+}
+
+func (t *table) Open() (sqlite3.VTabCursor, error) {
+	return &cursor{table: t}, nil
+}
+
+func (t *table) Rename(new string) error {
+	return nil
+}
+
+func (t *table) newReader() (*reader, error) {
+	if t.name == "" {
+		return &reader{r: bufio.NewReader(strings.NewReader(t.data))}, nil
+	}
+
+	f, err := t.fsys.Open(t.name)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{r: bufio.NewReader(f), closer: f}
+
+	// Detect gzip-compressed input.
+	magic, err := r.r.Peek(2)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if string(magic) == "\x1f\x8b" {
+		z, err := gzip.NewReader(r.r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r.r = bufio.NewReader(z)
+	}
+	return r, nil
+}
+
+type reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	line   []byte
+	num    int64
+}
+
+func (r *reader) Close() (err error) {
+	if r.closer != nil {
+		err = r.closer.Close()
+		r.closer = nil
+	}
+	return err
+}
+
+// next reads the next non-blank line,
+// returning io.EOF at the end of the input.
+func (r *reader) next() error {
+	for {
+		r.line = r.line[:0]
+		for {
+			line, more, err := r.r.ReadLine()
+			if err != nil {
+				if err == io.EOF && len(r.line) > 0 {
+					break
+				}
+				return err
+			}
+			r.line = append(r.line, line...)
+			if !more {
+				break
+			}
+		}
+		r.num++
+
+		if len(bytes.TrimSpace(r.line)) != 0 {
+			return nil
+		}
+	}
+}
+
+// decode decodes the current line.
+func (r *reader) decode() (v any, err error) {
+	dec := json.NewDecoder(bytes.NewReader(r.line))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("ndjson: line %d: %w", r.num, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("ndjson: line %d: invalid character after top-level value", r.num)
+	}
+	return v, nil
+}
+
+type cursor struct {
+	table  *table
+	reader *reader
+	doc    any
+	parsed bool
+	eof    bool
+	rowID  int64
+}
+
+func (c *cursor) Close() (err error) {
+	if c.reader != nil {
+		err = c.reader.Close()
+		c.reader = nil
+	}
+	return err
+}
+
+func (c *cursor) Filter(idxNum int, idxStr string, arg ...sqlite3.Value) (err error) {
+	if err := c.Close(); err != nil {
+		return err
+	}
+
+	c.reader, err = c.table.newReader()
+	if err != nil {
+		return err
+	}
+	c.rowID = 0
+	return c.Next()
+}
+
+func (c *cursor) Next() error {
+	c.rowID++
+	c.doc = nil
+	c.parsed = false
+	err := c.reader.next()
+	c.eof = err == io.EOF
+	if c.eof {
+		return nil
+	}
+	return err
+}
+
+func (c *cursor) EOF() bool {
+	return c.eof
+}
+
+func (c *cursor) RowID() (int64, error) {
+	return c.rowID, nil
+}
+
+func (c *cursor) Column(ctx sqlite3.Context, n int) error {
+	if n >= len(c.table.cols) {
+		ctx.ResultRawText(c.reader.line)
+		return nil
+	}
+	if !c.parsed {
+		doc, err := c.reader.decode()
+		if err != nil {
+			return err
+		}
+		c.doc = doc
+		c.parsed = true
+	}
+	col := c.table.cols[n]
+	col.result(ctx, col.path.eval(c.doc))
+	return nil
+}
@@ -0,0 +1,162 @@
+package ndjson_test
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/ext/ndjson"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestMain(m *testing.M) {
+	sqlite3.AutoExtension(ndjson.Register)
+	os.Exit(m.Run())
+}
+
+const data = `{"ts": 1, "level": "info", "msg": "started", "ctx": {"user": "rob"}}
+
+{"ts": 2, "level": "warn", "msg": "slow", "ctx": {"user": "ken"}, "ms": 1.5}
+{"ts": 3, "level": "info", "msg": "done", "ms": 2}
+`
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE VIRTUAL TABLE temp.logs USING ndjson(
+			data   = ` + sqlite3.Quote(data) + `,
+			column = 'time INTEGER $.ts',
+			column = 'user TEXT $.ctx.user',
+			column = 'level'
+		)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT time, user, level, _json ->> 'msg' FROM temp.logs WHERE level = 'info'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 1 {
+		t.Errorf("got %d want 1", got)
+	}
+	if got := stmt.ColumnText(1); got != "rob" {
+		t.Errorf("got %q want rob", got)
+	}
+	if got := stmt.ColumnText(3); got != "started" {
+		t.Errorf("got %q want started", got)
+	}
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnType(1); got != sqlite3.NULL {
+		t.Errorf("got %v want NULL", got)
+	}
+	if stmt.Step() {
+		t.Fatal("more rows")
+	}
+}
+
+func TestRegister_infer(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "logs.ndjson.gz")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := gzip.NewWriter(f)
+	z.Write([]byte(data))
+	z.Close()
+	f.Close()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(filename = ` + sqlite3.Quote(file) + `)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`SELECT group_concat(name || ':' || type) FROM pragma_table_info('logs')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "ts:INTEGER,level:TEXT,msg:TEXT,ctx:TEXT,ms:REAL" {
+		t.Errorf("got %q", got)
+	}
+	stmt.Close()
+
+	stmt, _, err = db.Prepare(`SELECT sum(ms), ctx ->> 'user' FROM temp.logs WHERE ts = 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnFloat(0); got != 1.5 {
+		t.Errorf("got %v want 1.5", got)
+	}
+	if got := stmt.ColumnText(1); got != "ken" {
+		t.Errorf("got %q want ken", got)
+	}
+}
+
+func TestRegister_errors(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson()`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(data='{}', column='a $.[')`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(data='{}', column='a INT, b TEXT')`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(data='{}', column='a INT) --')`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(data='{}', column='a INT CHECK(0)')`)
+	if err == nil {
+		t.Error("want error")
+	}
+	err = db.Exec(`CREATE VIRTUAL TABLE temp.logs USING ndjson(data='{"a":1}x')`)
+	if err == nil {
+		t.Error("want error")
+	}
+}
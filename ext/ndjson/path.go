@@ -0,0 +1,108 @@
+package ndjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a parsed JSON path, a subset of
+// https://sqlite.org/json1.html#path_arguments
+type path []step
+
+type step struct {
+	key   string
+	index int // -1 for object keys
+}
+
+func parsePath(s string) (path, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+	}
+
+	var p path
+	for rest := s[1:]; rest != ""; {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, `"`) {
+				i := strings.IndexByte(rest[1:], '"')
+				if i < 0 {
+					return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+				}
+				p = append(p, step{key: rest[1 : i+1], index: -1})
+				rest = rest[i+2:]
+			} else {
+				i := strings.IndexAny(rest, ".[")
+				if i < 0 {
+					i = len(rest)
+				}
+				if i == 0 {
+					return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+				}
+				p = append(p, step{key: rest[:i], index: -1})
+				rest = rest[i:]
+			}
+
+		case '[':
+			i := strings.IndexByte(rest, ']')
+			if i < 0 {
+				return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+			}
+			n, err := strconv.ParseUint(rest[1:i], 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+			}
+			p = append(p, step{index: int(n)})
+			rest = rest[i+1:]
+
+		default:
+			return nil, fmt.Errorf("ndjson: invalid path: %s", s)
+		}
+	}
+	return p, nil
+}
+
+// keyPath returns the path of a top-level key.
+func keyPath(key string) path {
+	return path{{key: key, index: -1}}
+}
+
+func (p path) eval(v any) any {
+	for _, s := range p {
+		switch t := v.(type) {
+		case map[string]any:
+			if s.index >= 0 {
+				return nil
+			}
+			v = t[s.key]
+		case []any:
+			if s.index < 0 || s.index >= len(t) {
+				return nil
+			}
+			v = t[s.index]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// jsonType returns the SQL type that best describes a JSON value.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case bool:
+		return "INTEGER"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "INTEGER"
+		}
+		return "REAL"
+	case string:
+		return "TEXT"
+	case map[string]any, []any:
+		return "JSON"
+	}
+	return "" // null
+}
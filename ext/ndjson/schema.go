@@ -0,0 +1,229 @@
+package ndjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/sql3util"
+)
+
+type column struct {
+	name string
+	typ  string
+	aff  sql3util.Affinity
+	path path
+}
+
+var typeName = regexp.MustCompile(`^\w+(\s+\w+)*(\s*\(\s*[+-]?[\d.]+\s*(,\s*[+-]?[\d.]+\s*)?\))?$`)
+
+// validType reports whether typ is a type name:
+// one or more identifiers, optionally followed by
+// one or two signed numbers in parentheses.
+// Column constraints are not type names.
+//
+// https://sqlite.org/syntax/type-name.html
+func validType(typ string) bool {
+	if !typeName.MatchString(typ) {
+		return false
+	}
+	name, _, _ := strings.Cut(typ, "(")
+	for _, word := range strings.Fields(name) {
+		switch strings.ToUpper(word) {
+		case "CONSTRAINT", "PRIMARY", "NOT", "NULL", "UNIQUE", "CHECK",
+			"DEFAULT", "COLLATE", "REFERENCES", "GENERATED", "AS":
+			return false
+		}
+	}
+	return true
+}
+
+// parseColumn parses a column specification:
+// a column name, an optional type, and an optional path.
+func parseColumn(spec string) (col column, err error) {
+	spec = strings.TrimSpace(spec)
+	name, rest, _ := strings.Cut(spec, " ")
+	col.name = sql3util.Unquote(name)
+	if col.name == "" {
+		return col, fmt.Errorf("ndjson: invalid column: %s", spec)
+	}
+
+	typ, pth, ok := strings.Cut(rest, "$")
+	col.typ = strings.TrimSpace(typ)
+	if col.typ != "" && !validType(col.typ) {
+		return col, fmt.Errorf("ndjson: invalid column type: %s", col.typ)
+	}
+	if ok {
+		col.path, err = parsePath("$" + pth)
+	} else {
+		col.path = keyPath(col.name)
+	}
+	col.aff = sql3util.GetAffinity(col.typ)
+	return col, err
+}
+
+func getSchema(cols []column) string {
+	var buf strings.Builder
+	buf.WriteString("CREATE TABLE x(")
+	for _, col := range cols {
+		buf.WriteString(sqlite3.QuoteIdentifier(col.name))
+		if col.typ != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(col.typ)
+		}
+		buf.WriteByte(',')
+	}
+	buf.WriteString("_json HIDDEN)")
+	return buf.String()
+}
+
+// inferColumns infers columns from the top-level keys
+// of the first sample records.
+func (t *table) inferColumns(sample int) ([]column, error) {
+	r, err := t.newReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var keys []string
+	types := map[string]string{}
+	for range sample {
+		if err := r.next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		doc, err := r.decode()
+		if err != nil {
+			return nil, err
+		}
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range sortedKeys(r.line, obj) {
+			typ := jsonType(obj[key])
+			old, seen := types[key]
+			if !seen {
+				keys = append(keys, key)
+				types[key] = typ
+				continue
+			}
+			switch {
+			case typ == "" || old == typ:
+			case old == "":
+				types[key] = typ
+			case old == "INTEGER" && typ == "REAL" || old == "REAL" && typ == "INTEGER":
+				types[key] = "REAL"
+			default:
+				types[key] = "ANY"
+			}
+		}
+	}
+
+	cols := make([]column, 0, len(keys))
+	for _, key := range keys {
+		if key == "_json" {
+			continue
+		}
+		var typ string
+		switch types[key] {
+		case "INTEGER", "REAL", "TEXT":
+			typ = types[key]
+		case "JSON":
+			typ = "TEXT"
+		}
+		cols = append(cols, column{
+			name: key,
+			typ:  typ,
+			aff:  sql3util.GetAffinity(typ),
+			path: keyPath(key),
+		})
+	}
+	return cols, nil
+}
+
+// sortedKeys returns the keys of obj in the order they appear in line.
+func sortedKeys(line []byte, obj map[string]any) []string {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	keys := make([]string, 0, len(obj))
+	depth := 0
+	key := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			// Either the object started, or a nested value ended.
+			key = depth == 1
+			continue
+		}
+		if depth == 1 {
+			if key {
+				keys = append(keys, tok.(string))
+			}
+			key = !key
+		}
+	}
+}
+
+func (col *column) result(ctx sqlite3.Context, v any) {
+	switch v := v.(type) {
+	case nil:
+		ctx.ResultNull()
+	case bool:
+		if col.aff == sql3util.TEXT {
+			ctx.ResultText(strconv.FormatBool(v))
+		} else {
+			ctx.ResultBool(v)
+		}
+	case json.Number:
+		if col.aff == sql3util.TEXT {
+			ctx.ResultText(v.String())
+		} else if i, err := v.Int64(); err == nil && col.aff != sql3util.REAL {
+			ctx.ResultInt64(i)
+		} else if f, err := v.Float64(); err == nil {
+			ctx.ResultFloat(f)
+		} else {
+			ctx.ResultText(v.String())
+		}
+	case string:
+		switch col.aff {
+		case sql3util.INTEGER, sql3util.NUMERIC:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				ctx.ResultInt64(i)
+				return
+			}
+			fallthrough
+		case sql3util.REAL:
+			if f, ok := sql3util.ParseFloat(v); ok {
+				ctx.ResultFloat(f)
+				return
+			}
+		}
+		ctx.ResultText(v)
+	default:
+		ctx.ResultJSON(v)
+	}
+}
+
+func uintArg(key, val string) (int, error) {
+	i, err := strconv.ParseUint(val, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("ndjson: invalid %q parameter: %s", key, val)
+	}
+	return int(i), nil
+}
@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// A streaming histogram, with up to maxBins bins.
+//
+// https://jmlr.org/papers/volume11/ben-haim10a/ben-haim10a.pdf
+type histogram struct {
+	bins     []centroid
+	maxBins  int
+	min, max float64
+}
+
+const maxHistogramBins = 1000
+
+func newHistogram(arg ...sqlite3.Value) (sketch, error) {
+	n := arg[1].Int64()
+	if n < 1 || n > maxHistogramBins {
+		return nil, fmt.Errorf("buckets must be between 1 and %d", maxHistogramBins)
+	}
+	return &histogram{maxBins: int(n), min: math.Inf(+1), max: math.Inf(-1)}, nil
+}
+
+func (h *histogram) add(arg sqlite3.Value) {
+	if arg.NumericType() <= sqlite3.FLOAT {
+		f := arg.Float()
+		h.bins = append(h.bins, centroid{f, 1})
+		h.min = min(h.min, f)
+		h.max = max(h.max, f)
+		if len(h.bins) >= 2*h.maxBins {
+			h.shrink()
+		}
+	}
+}
+
+// shrink merges the closest bins, until at most maxBins remain.
+// Gaps between adjacent bins are kept in a heap,
+// and bins are linked to their neighbors, so each merge is logarithmic.
+func (h *histogram) shrink() {
+	slices.SortFunc(h.bins, func(a, b centroid) int {
+		return cmpFloat(a.mean, b.mean)
+	})
+	n := len(h.bins)
+	if n <= h.maxBins {
+		return
+	}
+
+	// Bins are updated in place; gen invalidates their gaps.
+	next := make([]int, n)
+	prev := make([]int, n)
+	gen := make([]int, n)
+	gaps := make(histogramGaps, 0, n-1)
+	for i := range n {
+		next[i] = i + 1
+		prev[i] = i - 1
+		if i > 0 {
+			gaps = append(gaps, h.gap(i-1, i, gen))
+		}
+	}
+	heap.Init(&gaps)
+
+	for merges := n - h.maxBins; merges > 0; {
+		g := heap.Pop(&gaps).(histogramGap)
+		i, j := g.left, g.right
+		if gen[i] != g.gens[0] || gen[j] != g.gens[1] {
+			continue
+		}
+
+		a, b := h.bins[i], h.bins[j]
+		c := a.count + b.count
+		h.bins[i] = centroid{a.mean + (b.mean-a.mean)*float64(b.count)/float64(c), c}
+		gen[i]++
+		gen[j] = -1
+		merges--
+
+		next[i] = next[j]
+		if k := next[j]; k < n {
+			prev[k] = i
+			heap.Push(&gaps, h.gap(i, k, gen))
+		}
+		if k := prev[i]; k >= 0 {
+			heap.Push(&gaps, h.gap(k, i, gen))
+		}
+	}
+
+	k := 0
+	for i, b := range h.bins {
+		if gen[i] >= 0 {
+			h.bins[k] = b
+			k++
+		}
+	}
+	h.bins = h.bins[:k]
+}
+
+func (h *histogram) gap(i, j int, gen []int) histogramGap {
+	return histogramGap{
+		width: h.bins[j].mean - h.bins[i].mean,
+		left:  i,
+		right: j,
+		gens:  [2]int{gen[i], gen[j]},
+	}
+}
+
+// histogramGap is the gap between two adjacent bins.
+// It is stale if either bin changed since it was computed.
+type histogramGap struct {
+	width       float64
+	left, right int
+	gens        [2]int
+}
+
+// histogramGaps is a min-heap of gaps, ordered by width and then position.
+type histogramGaps []histogramGap
+
+func (h histogramGaps) Len() int { return len(h) }
+
+func (h histogramGaps) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	return a.width < b.width || a.width == b.width && a.left < b.left
+}
+
+func (h histogramGaps) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *histogramGaps) Push(x any) { *h = append(*h, x.(histogramGap)) }
+
+func (h *histogramGaps) Pop() any {
+	old := *h
+	g := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return g
+}
+
+func (h *histogram) merge(other sketch) error {
+	o, ok := other.(*histogram)
+	if !ok {
+		return errutil.ErrorString("incompatible sketches")
+	}
+	h.bins = append(h.bins, o.bins...)
+	h.min = min(h.min, o.min)
+	h.max = max(h.max, o.max)
+	h.shrink()
+	return nil
+}
+
+// result returns the bins as a JSON array of objects,
+// with the lower and upper bounds, and the count of each bin.
+// Bounds are midway between adjacent bins.
+func (h *histogram) result(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	h.shrink()
+	if len(h.bins) == 0 {
+		return
+	}
+
+	type bin struct {
+		Lo    float64 `json:"lo"`
+		Hi    float64 `json:"hi"`
+		Count uint64  `json:"count"`
+	}
+	res := make([]bin, len(h.bins))
+	for i, b := range h.bins {
+		res[i].Count = b.count
+		if i == 0 {
+			res[i].Lo = h.min
+		} else {
+			res[i].Lo = res[i-1].Hi
+		}
+		if i == len(h.bins)-1 {
+			res[i].Hi = h.max
+		} else {
+			res[i].Hi = (b.mean + h.bins[i+1].mean) / 2
+		}
+	}
+	ctx.ResultJSON(res)
+	ctx.ResultSubtype('J')
+}
+
+func (h *histogram) appendBinary(buf []byte) []byte {
+	h.shrink()
+	buf = binary.AppendUvarint(append(buf, kindHistogram), uint64(h.maxBins))
+	return appendCentroids(buf, h.min, h.max, h.bins)
+}
+
+func (h *histogram) decode(buf []byte) (err error) {
+	n, i := binary.Uvarint(buf)
+	if i <= 0 || n < 1 || n > maxHistogramBins {
+		return errSketch
+	}
+	h.maxBins = int(n)
+	h.min, h.max, h.bins, err = decodeCentroids(buf[i:])
+	return err
+}
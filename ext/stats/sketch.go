@@ -0,0 +1,254 @@
+package stats
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// A sketch is a mergeable summary of a set of values,
+// that can be serialized to a BLOB.
+//
+// The first byte of a serialized sketch identifies its kind.
+type sketch interface {
+	add(arg sqlite3.Value)
+	merge(other sketch) error
+	appendBinary(buf []byte) []byte
+	// result returns the final value of the sketch;
+	// arg are the optional arguments of sketch_value.
+	result(ctx sqlite3.Context, arg ...sqlite3.Value)
+}
+
+const (
+	kindHLL       = 'H'
+	kindTDigest   = 'T'
+	kindHistogram = 'G'
+	kindTopK      = 'K'
+)
+
+const errSketch = errutil.ErrorString("invalid sketch")
+
+func decodeSketch(buf []byte) (sketch, error) {
+	if len(buf) == 0 {
+		return nil, errSketch
+	}
+	var s interface {
+		sketch
+		decode(buf []byte) error
+	}
+	switch buf[0] {
+	case kindHLL:
+		s = &hll{}
+	case kindTDigest:
+		s = &tdigest{}
+	case kindHistogram:
+		s = &histogram{}
+	case kindTopK:
+		s = &topK{}
+	default:
+		return nil, errSketch
+	}
+	if err := s.decode(buf[1:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSketch returns an aggregate that builds a sketch.
+// If final, the aggregate returns the final value of the sketch,
+// otherwise it returns the serialized sketch.
+func newSketch(name string, final bool, init func(arg ...sqlite3.Value) (sketch, error)) sqlite3.AggregateConstructor {
+	return func() sqlite3.AggregateFunction {
+		return &sketchAgg{name: name, final: final, init: init}
+	}
+}
+
+type sketchAgg struct {
+	init  func(arg ...sqlite3.Value) (sketch, error)
+	s     sketch
+	err   error
+	name  string
+	final bool
+}
+
+func (a *sketchAgg) Step(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if a.err != nil {
+		return
+	}
+	if a.s == nil {
+		a.s, a.err = a.init(arg...)
+		if a.err != nil {
+			a.err = fmt.Errorf("%s: %w", a.name, a.err)
+			return
+		}
+	}
+	a.s.add(arg[0])
+}
+
+func (a *sketchAgg) Value(ctx sqlite3.Context) {
+	switch {
+	case a.err != nil:
+		ctx.ResultError(a.err)
+	case a.s == nil:
+		return
+	case a.final:
+		a.s.result(ctx)
+	default:
+		ctx.ResultBlob(a.s.appendBinary(nil))
+	}
+}
+
+// newSketchMerge returns an aggregate that merges serialized sketches.
+func newSketchMerge() sqlite3.AggregateFunction {
+	return &sketchMerge{}
+}
+
+type sketchMerge struct {
+	s   sketch
+	err error
+}
+
+func (a *sketchMerge) Step(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if a.err != nil || arg[0].Type() == sqlite3.NULL {
+		return
+	}
+	s, err := decodeSketch(arg[0].RawBlob())
+	if err == nil {
+		if a.s == nil {
+			a.s = s
+			return
+		}
+		err = a.s.merge(s)
+	}
+	if err != nil {
+		a.err = fmt.Errorf("sketch_merge: %w", err)
+	}
+}
+
+func (a *sketchMerge) Value(ctx sqlite3.Context) {
+	switch {
+	case a.err != nil:
+		ctx.ResultError(a.err)
+	case a.s != nil:
+		ctx.ResultBlob(a.s.appendBinary(nil))
+	}
+}
+
+func sketchValue(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	if arg[0].Type() == sqlite3.NULL {
+		return
+	}
+	s, err := decodeSketch(arg[0].RawBlob())
+	if err != nil {
+		ctx.ResultError(fmt.Errorf("sketch_value: %w", err))
+		return
+	}
+	s.result(ctx, arg[1:]...)
+}
+
+// appendKey appends a representation of a value that identifies it,
+// such that values that compare equal in SQL have the same representation.
+// Integral floats are represented as integers.
+func appendKey(buf []byte, arg sqlite3.Value) []byte {
+	switch arg.Type() {
+	case sqlite3.INTEGER:
+		return binary.BigEndian.AppendUint64(append(buf, 'i'), uint64(arg.Int64()))
+	case sqlite3.FLOAT:
+		f := arg.Float()
+		if i := int64(f); float64(i) == f && -0x1p63 <= f && f < 0x1p63 {
+			return binary.BigEndian.AppendUint64(append(buf, 'i'), uint64(i))
+		}
+		return binary.BigEndian.AppendUint64(append(buf, 'f'), math.Float64bits(f))
+	case sqlite3.TEXT:
+		return append(append(buf, 't'), arg.RawText()...)
+	case sqlite3.BLOB:
+		return append(append(buf, 'b'), arg.RawBlob()...)
+	}
+	return nil
+}
+
+// keyValue converts a key back into a JSON encodable value.
+func keyValue(key string) any {
+	switch key[0] {
+	case 'i':
+		return int64(binary.BigEndian.Uint64([]byte(key[1:])))
+	case 'f':
+		return math.Float64frombits(binary.BigEndian.Uint64([]byte(key[1:])))
+	case 't':
+		return key[1:]
+	default:
+		return []byte(key[1:])
+	}
+}
+
+// quantiles parses a quantile, or a JSON array of quantiles.
+func quantiles(arg sqlite3.Value) (pct float64, pcts []float64, err error) {
+	if arg.NumericType() <= sqlite3.FLOAT {
+		pct = arg.Float()
+	} else if err := json.Unmarshal(arg.RawText(), &pcts); err != nil {
+		return 0, nil, fmt.Errorf("invalid quantile: %q", arg.Text())
+	}
+	if pct < 0 || pct > 1 {
+		return 0, nil, fmt.Errorf("invalid quantile: %f", pct)
+	}
+	for _, p := range pcts {
+		if p < 0 || p > 1 {
+			return 0, nil, fmt.Errorf("invalid quantile: %f", p)
+		}
+	}
+	return pct, pcts, nil
+}
+
+// sketchReader decodes the fields of a serialized sketch.
+type sketchReader struct {
+	buf []byte
+	err error
+}
+
+func (r *sketchReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errSketch
+		r.buf = nil
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *sketchReader) float() float64 {
+	if len(r.buf) < 8 {
+		r.err = errSketch
+		r.buf = nil
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf))
+	r.buf = r.buf[8:]
+	return v
+}
+
+func (r *sketchReader) bytes(n uint64) []byte {
+	if uint64(len(r.buf)) < n {
+		r.err = errSketch
+		r.buf = nil
+		return nil
+	}
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *sketchReader) done() error {
+	if r.err == nil && len(r.buf) != 0 {
+		return errSketch
+	}
+	return r.err
+}
+
+func appendFloat(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
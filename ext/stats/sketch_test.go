@@ -0,0 +1,129 @@
+package stats_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+func TestRegister_sketch(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE data AS
+		WITH RECURSIVE seq(x) AS (SELECT 0 UNION ALL SELECT x+1 FROM seq LIMIT 100000)
+		SELECT x, x % 10 AS bucket FROM seq`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		SELECT
+			approx_count_distinct(x),
+			approx_count_distinct(bucket),
+			approx_percentile(x, 0.5),
+			approx_percentile(x, '[0.01, 0.99]'),
+			histogram(bucket, 2),
+			json_array(top_k(bucket % 3, 1))
+		FROM data`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnFloat(0); math.Abs(got-100000) > 3000 {
+		t.Errorf("got %v, want 100000", got)
+	}
+	if got := stmt.ColumnInt(1); got != 10 {
+		t.Errorf("got %v, want 10", got)
+	}
+	if got := stmt.ColumnFloat(2); math.Abs(got-50000) > 500 {
+		t.Errorf("got %v, want 50000", got)
+	}
+	var pcts []float64
+	if err := stmt.ColumnJSON(3, &pcts); err != nil {
+		t.Fatal(err)
+	}
+	if len(pcts) != 2 || math.Abs(pcts[0]-1000) > 100 || math.Abs(pcts[1]-99000) > 100 {
+		t.Errorf("got %v", pcts)
+	}
+	var bins []struct{ Lo, Hi, Count float64 }
+	if err := stmt.ColumnJSON(4, &bins); err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 2 || bins[0].Lo != 0 || bins[1].Hi != 9 || bins[0].Count+bins[1].Count != 100000 {
+		t.Errorf("got %v", bins)
+	}
+	if got := stmt.ColumnText(5); got != `[[{"value":0,"count":40000}]]` {
+		t.Errorf("got %s", got)
+	}
+	stmt.Close()
+
+	// Sketch each bucket, then merge them.
+	err = db.Exec(`
+		CREATE TABLE rollup AS
+		SELECT
+			bucket,
+			hll_sketch(x) AS hll,
+			tdigest_sketch(x) AS tdigest,
+			histogram_sketch(x, 10) AS histogram,
+			top_k_sketch(x % 7, 2) AS top_k
+		FROM data GROUP BY bucket`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err = db.Prepare(`
+		SELECT
+			sketch_value(sketch_merge(hll)),
+			sketch_value(sketch_merge(tdigest), 0.5),
+			json_array_length(sketch_value(sketch_merge(histogram))),
+			json_array(sketch_value(sketch_merge(top_k), 1))
+		FROM rollup`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnFloat(0); math.Abs(got-100000) > 3000 {
+		t.Errorf("got %v, want 100000", got)
+	}
+	if got := stmt.ColumnFloat(1); math.Abs(got-50000) > 500 {
+		t.Errorf("got %v, want 50000", got)
+	}
+	if got := stmt.ColumnInt(2); got != 10 {
+		t.Errorf("got %v, want 10", got)
+	}
+	if got := stmt.ColumnText(3); got != `[[{"value":0,"count":14286}]]` {
+		t.Errorf("got %s", got)
+	}
+	stmt.Close()
+
+	for _, sql := range []string{
+		`SELECT sketch_merge(column1) FROM (SELECT hll_sketch(1) UNION ALL SELECT tdigest_sketch(1))`,
+		`SELECT sketch_value(tdigest_sketch(1))`,
+		`SELECT sketch_value(x'00')`,
+		`SELECT histogram(1, 0)`,
+		`SELECT top_k(1, 0)`,
+		`SELECT approx_percentile(1, 2)`,
+	} {
+		stmt, _, err := db.Prepare(sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stmt.Step() {
+			t.Errorf("%s: want error", sql)
+		}
+		stmt.Close()
+	}
+}
@@ -29,6 +29,14 @@
 //   - mode: most frequent value
 //   - every: boolean and
 //   - some: boolean or
+//   - approx_count_distinct: approximate count of distinct values (HyperLogLog)
+//   - approx_percentile: approximate continuous quantile (t-digest)
+//   - histogram: approximate histogram (streaming histogram)
+//   - top_k: approximate most frequent values (Space-Saving)
+//   - hll_sketch, tdigest_sketch, histogram_sketch, top_k_sketch:
+//     sketches serialized as BLOBs
+//   - sketch_merge: merges sketches of the same kind
+//   - sketch_value: the final value of a sketch
 //
 // These join the [Built-in Aggregate Functions]:
 //   - count: count rows/values
@@ -92,6 +100,17 @@ func Register(db *sqlite3.Conn) error {
 		db.CreateWindowFunction("every", 1, flags, newBoolean(every)),
 		db.CreateWindowFunction("some", 1, flags, newBoolean(some)),
 		db.CreateWindowFunction("mode", 1, order, newMode),
+		db.CreateWindowFunction("approx_count_distinct", 1, flags, newSketch("approx_count_distinct", true, newHLL)),
+		db.CreateWindowFunction("approx_percentile", 2, json, newSketch("approx_percentile", true, newApproxPercentile)),
+		db.CreateWindowFunction("histogram", 2, json, newSketch("histogram", true, newHistogram)),
+		db.CreateWindowFunction("top_k", 2, json, newSketch("top_k", true, newTopK)),
+		db.CreateWindowFunction("hll_sketch", 1, flags, newSketch("hll_sketch", false, newHLL)),
+		db.CreateWindowFunction("tdigest_sketch", 1, flags, newSketch("tdigest_sketch", false, newTDigest)),
+		db.CreateWindowFunction("histogram_sketch", 2, flags, newSketch("histogram_sketch", false, newHistogram)),
+		db.CreateWindowFunction("top_k_sketch", 2, flags, newSketch("top_k_sketch", false, newTopK)),
+		db.CreateWindowFunction("sketch_merge", 1, flags, newSketchMerge),
+		db.CreateFunction("sketch_value", 1, json, sketchValue),
+		db.CreateFunction("sketch_value", 2, json, sketchValue),
 		db.CreateFunction("cbrt", 1, flags, cbrt),
 		db.CreateFunction("cot", 1, flags, cot))
 }
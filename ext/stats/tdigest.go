@@ -0,0 +1,214 @@
+package stats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+	"github.com/ncruces/go-sqlite3/internal/util"
+)
+
+// A merging t-digest.
+//
+// https://arxiv.org/abs/1902.04023
+const tdigestCompression = 100
+
+type centroid struct {
+	mean  float64
+	count uint64
+}
+
+type tdigest struct {
+	centroids []centroid
+	buffer    []centroid
+	total     uint64
+	min, max  float64
+	// Quantiles to return, for approx_percentile.
+	pct  float64
+	pcts []float64
+}
+
+func newTDigest(arg ...sqlite3.Value) (sketch, error) {
+	return &tdigest{min: math.Inf(+1), max: math.Inf(-1), pct: -1}, nil
+}
+
+func newApproxPercentile(arg ...sqlite3.Value) (sketch, error) {
+	pct, pcts, err := quantiles(arg[1])
+	if err != nil {
+		return nil, err
+	}
+	return &tdigest{min: math.Inf(+1), max: math.Inf(-1), pct: pct, pcts: pcts}, nil
+}
+
+func (t *tdigest) add(arg sqlite3.Value) {
+	if arg.NumericType() <= sqlite3.FLOAT {
+		t.addCentroid(centroid{arg.Float(), 1})
+	}
+}
+
+func (t *tdigest) addCentroid(c centroid) {
+	t.buffer = append(t.buffer, c)
+	t.total += c.count
+	t.min = min(t.min, c.mean)
+	t.max = max(t.max, c.mean)
+	if len(t.buffer) >= 5*tdigestCompression {
+		t.compress()
+	}
+}
+
+func (t *tdigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	slices.SortFunc(all, func(a, b centroid) int {
+		return cmpFloat(a.mean, b.mean)
+	})
+	t.buffer = t.buffer[:0]
+
+	total := float64(t.total)
+	out := all[:0]
+	cur := all[0]
+	var sofar float64
+	for _, c := range all[1:] {
+		// The k1 scale function bounds the size of centroids
+		// by their quantile: tails get smaller centroids.
+		n := cur.count + c.count
+		q := (sofar + float64(n)/2) / total
+		if float64(n) <= 4*total*q*(1-q)/tdigestCompression {
+			cur.mean += (c.mean - cur.mean) * float64(c.count) / float64(n)
+			cur.count = n
+		} else {
+			sofar += float64(cur.count)
+			out = append(out, cur)
+			cur = c
+		}
+	}
+	t.centroids = append(out, cur)
+}
+
+func (t *tdigest) quantile(q float64) float64 {
+	t.compress()
+	cs := t.centroids
+	if len(cs) == 1 {
+		return cs[0].mean
+	}
+
+	// Centroids are centered on their cumulative count.
+	idx := q * float64(t.total)
+	first, last := cs[0], cs[len(cs)-1]
+	if c := float64(first.count) / 2; idx < c {
+		return util.Lerp(t.min, first.mean, idx/c)
+	}
+	if c := float64(last.count) / 2; idx > float64(t.total)-c {
+		return util.Lerp(last.mean, t.max, (idx-float64(t.total)+c)/c)
+	}
+
+	pos := float64(first.count) / 2
+	for i := 1; i < len(cs); i++ {
+		next := pos + float64(cs[i-1].count+cs[i].count)/2
+		if idx <= next {
+			return util.Lerp(cs[i-1].mean, cs[i].mean, (idx-pos)/(next-pos))
+		}
+		pos = next
+	}
+	return t.max
+}
+
+func (t *tdigest) merge(other sketch) error {
+	o, ok := other.(*tdigest)
+	if !ok {
+		return errutil.ErrorString("incompatible sketches")
+	}
+	o.compress()
+	for _, c := range o.centroids {
+		t.addCentroid(c)
+	}
+	t.min = min(t.min, o.min)
+	t.max = max(t.max, o.max)
+	return nil
+}
+
+func (t *tdigest) result(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	pct, pcts := t.pct, t.pcts
+	if len(arg) > 0 {
+		var err error
+		pct, pcts, err = quantiles(arg[0])
+		if err != nil {
+			ctx.ResultError(fmt.Errorf("sketch_value: %w", err))
+			return
+		}
+	}
+	if pct < 0 && pcts == nil {
+		ctx.ResultError(errutil.ErrorString("sketch_value: missing quantile"))
+		return
+	}
+	if t.total == 0 {
+		return
+	}
+
+	if pcts != nil {
+		many := make([]float64, len(pcts))
+		for i, pct := range pcts {
+			many[i] = t.quantile(pct)
+		}
+		ctx.ResultJSON(many)
+		ctx.ResultSubtype('J')
+	} else {
+		ctx.ResultFloat(t.quantile(pct))
+	}
+}
+
+func (t *tdigest) appendBinary(buf []byte) []byte {
+	t.compress()
+	return appendCentroids(append(buf, kindTDigest), t.min, t.max, t.centroids)
+}
+
+func (t *tdigest) decode(buf []byte) (err error) {
+	t.pct = -1
+	t.min, t.max, t.centroids, err = decodeCentroids(buf)
+	for _, c := range t.centroids {
+		t.total += c.count
+	}
+	return err
+}
+
+func appendCentroids(buf []byte, min, max float64, cs []centroid) []byte {
+	buf = appendFloat(buf, min)
+	buf = appendFloat(buf, max)
+	buf = binary.AppendUvarint(buf, uint64(len(cs)))
+	for _, c := range cs {
+		buf = appendFloat(buf, c.mean)
+		buf = binary.AppendUvarint(buf, c.count)
+	}
+	return buf
+}
+
+func decodeCentroids(buf []byte) (min, max float64, cs []centroid, err error) {
+	r := sketchReader{buf: buf}
+	min = r.float()
+	max = r.float()
+	n := r.uvarint()
+	if n > uint64(len(r.buf)) {
+		return 0, 0, nil, errSketch
+	}
+	cs = make([]centroid, n)
+	for i := range cs {
+		cs[i].mean = r.float()
+		cs[i].count = r.uvarint()
+	}
+	return min, max, cs, r.done()
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return +1
+	}
+	return 0
+}
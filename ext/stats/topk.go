@@ -0,0 +1,213 @@
+package stats
+
+import (
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/errutil"
+)
+
+// Space-Saving, tracking 10 counters for each of the k most frequent values.
+//
+// https://www.cs.ucsb.edu/sites/default/files/documents/2005-23.pdf
+const topKFactor = 10
+
+const maxTopK = 1000
+
+type topK struct {
+	counts topKHeap
+	key    []byte
+	k      int
+}
+
+func newTopK(arg ...sqlite3.Value) (sketch, error) {
+	k := arg[1].Int64()
+	if k < 1 || k > maxTopK {
+		return nil, fmt.Errorf("k must be between 1 and %d", maxTopK)
+	}
+	return &topK{k: int(k), counts: newTopKHeap(0)}, nil
+}
+
+func (t *topK) capacity() int {
+	return topKFactor * t.k
+}
+
+func (t *topK) add(arg sqlite3.Value) {
+	t.key = appendKey(t.key[:0], arg)
+	if t.key == nil {
+		return
+	}
+	if i, ok := t.counts.index[string(t.key)]; ok {
+		t.counts.entries[i].count++
+		heap.Fix(&t.counts, i)
+		return
+	}
+	if t.counts.Len() < t.capacity() {
+		heap.Push(&t.counts, topKEntry{string(t.key), 1})
+		return
+	}
+	// Replace the least frequent value,
+	// inheriting its count as the error.
+	min := &t.counts.entries[0]
+	delete(t.counts.index, min.key)
+	min.key = string(t.key)
+	min.count++
+	t.counts.index[min.key] = 0
+	heap.Fix(&t.counts, 0)
+}
+
+type topKEntry struct {
+	key   string
+	count uint64
+}
+
+// topKHeap is a min-heap of entries, ordered by count and then key,
+// indexed by key.
+type topKHeap struct {
+	entries []topKEntry
+	index   map[string]int
+}
+
+func newTopKHeap(n int) topKHeap {
+	return topKHeap{
+		entries: make([]topKEntry, 0, n),
+		index:   make(map[string]int, n),
+	}
+}
+
+func (h *topKHeap) Len() int { return len(h.entries) }
+
+func (h *topKHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	return a.count < b.count || a.count == b.count && a.key < b.key
+}
+
+func (h *topKHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].key] = i
+	h.index[h.entries[j].key] = j
+}
+
+func (h *topKHeap) Push(x any) {
+	e := x.(topKEntry)
+	h.index[e.key] = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *topKHeap) Pop() any {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	delete(h.index, e.key)
+	return e
+}
+
+// sorted returns entries by decreasing count.
+func (t *topK) sorted() []topKEntry {
+	res := slices.Clone(t.counts.entries)
+	slices.SortFunc(res, func(a, b topKEntry) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.key, b.key))
+	})
+	return res
+}
+
+func (t *topK) merge(other sketch) error {
+	o, ok := other.(*topK)
+	if !ok || o.k != t.k {
+		return errutil.ErrorString("incompatible sketches")
+	}
+	for _, e := range o.counts.entries {
+		if i, ok := t.counts.index[e.key]; ok {
+			t.counts.entries[i].count += e.count
+		} else {
+			t.counts.Push(e)
+		}
+	}
+	entries := t.sorted()
+	entries = entries[:min(len(entries), t.capacity())]
+	t.counts = newTopKHeap(len(entries))
+	for _, e := range entries {
+		t.counts.Push(e)
+	}
+	heap.Init(&t.counts)
+	return nil
+}
+
+// result returns the k most frequent values as a JSON array of objects,
+// with each value and its estimated count.
+func (t *topK) result(ctx sqlite3.Context, arg ...sqlite3.Value) {
+	k := t.k
+	if len(arg) > 0 {
+		k = arg[0].Int()
+		if k < 1 {
+			ctx.ResultError(errutil.ErrorString("sketch_value: k must be positive"))
+			return
+		}
+	}
+	if t.counts.Len() == 0 {
+		return
+	}
+
+	type item struct {
+		Value any    `json:"value"`
+		Count uint64 `json:"count"`
+	}
+	entries := t.sorted()
+	res := make([]item, 0, min(k, len(entries)))
+	for _, e := range entries[:cap(res)] {
+		res = append(res, item{keyValue(e.key), e.count})
+	}
+	ctx.ResultJSON(res)
+	ctx.ResultSubtype('J')
+}
+
+func (t *topK) appendBinary(buf []byte) []byte {
+	buf = binary.AppendUvarint(append(buf, kindTopK), uint64(t.k))
+	buf = binary.AppendUvarint(buf, uint64(t.counts.Len()))
+	for _, e := range t.sorted() {
+		buf = binary.AppendUvarint(buf, uint64(len(e.key)))
+		buf = append(buf, e.key...)
+		buf = binary.AppendUvarint(buf, e.count)
+	}
+	return buf
+}
+
+func (t *topK) decode(buf []byte) error {
+	r := sketchReader{buf: buf}
+	k := r.uvarint()
+	n := r.uvarint()
+	if k < 1 || k > maxTopK || n > topKFactor*k {
+		return errSketch
+	}
+	t.k = int(k)
+	t.counts = newTopKHeap(int(n))
+	for range n {
+		key := r.bytes(r.uvarint())
+		count := r.uvarint()
+		if !validKey(key) {
+			return errSketch
+		}
+		if _, ok := t.counts.index[string(key)]; ok {
+			return errSketch
+		}
+		t.counts.Push(topKEntry{string(key), count})
+	}
+	heap.Init(&t.counts)
+	return r.done()
+}
+
+func validKey(key []byte) bool {
+	if len(key) == 0 {
+		return false
+	}
+	switch key[0] {
+	case 'i', 'f':
+		return len(key) == 9
+	case 't', 'b':
+		return true
+	}
+	return false
+}
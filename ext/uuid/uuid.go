@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,21 +30,34 @@ import (
 //     to extract the timestamp of a version 1/2/6/7 UUID
 //   - gen_random_uuid(u):
 //     to generate a version 4 (random) UUID
+//
+// Version 4 and 7 UUIDs use the clock and source of randomness
+// of the connection (see [sqlite3.WithClock] and [sqlite3.WithRandomness]).
+// Version 7 UUIDs generated by a connection are monotonic.
+// Version 1, 2 and 6 UUIDs always use the system clock and node ID,
+// so they aren't reproducible.
 func Register(db *sqlite3.Conn) error {
 	const flags = sqlite3.DETERMINISTIC | sqlite3.INNOCUOUS
+	g := &generator{}
 	return errors.Join(
-		db.CreateFunction("uuid", 0, sqlite3.INNOCUOUS, generate),
-		db.CreateFunction("uuid", 1, sqlite3.INNOCUOUS, generate),
-		db.CreateFunction("uuid", 2, sqlite3.INNOCUOUS, generate),
-		db.CreateFunction("uuid", 3, sqlite3.INNOCUOUS, generate),
+		db.CreateFunction("uuid", 0, sqlite3.INNOCUOUS, g.generate),
+		db.CreateFunction("uuid", 1, sqlite3.INNOCUOUS, g.generate),
+		db.CreateFunction("uuid", 2, sqlite3.INNOCUOUS, g.generate),
+		db.CreateFunction("uuid", 3, sqlite3.INNOCUOUS, g.generate),
 		db.CreateFunction("uuid_str", 1, flags, toString),
 		db.CreateFunction("uuid_blob", 1, flags, toBlob),
 		db.CreateFunction("uuid_extract_version", 1, flags, version),
 		db.CreateFunction("uuid_extract_timestamp", 1, flags, timestamp),
-		db.CreateFunction("gen_random_uuid", 0, sqlite3.INNOCUOUS, generate))
+		db.CreateFunction("gen_random_uuid", 0, sqlite3.INNOCUOUS, g.generate))
+}
+
+// A generator holds the per-connection state
+// needed to generate monotonic UUIDs.
+type generator struct {
+	lastV7 int64 // in units of 1/4096 ms
 }
 
-func generate(ctx sqlite3.Context, arg ...sqlite3.Value) {
+func (g *generator) generate(ctx sqlite3.Context, arg ...sqlite3.Value) {
 	var (
 		ver int
 		err error
@@ -60,11 +74,11 @@ func generate(ctx sqlite3.Context, arg ...sqlite3.Value) {
 	case 1:
 		u, err = uuid.NewUUID()
 	case 4:
-		u, err = uuid.NewRandom()
+		u, err = uuid.NewRandomFromReader(ctx.Conn().Randomness())
 	case 6:
 		u, err = uuid.NewV6()
 	case 7:
-		u, err = uuid.NewV7()
+		u, err = g.newV7(ctx.Conn())
 
 	case 2:
 		var domain uuid.Domain
@@ -133,6 +147,39 @@ func generate(ctx sqlite3.Context, arg ...sqlite3.Value) {
 	}
 }
 
+// newV7 generates a version 7 UUID,
+// using the 12 bits of rand_a for sub-millisecond precision.
+// If the clock hasn't advanced, the previous timestamp is incremented,
+// so UUIDs are monotonic.
+//
+// https://www.rfc-editor.org/rfc/rfc9562#section-6.2-5.6.1
+func (g *generator) newV7(db *sqlite3.Conn) (u uuid.UUID, err error) {
+	_, err = io.ReadFull(db.Randomness(), u[8:])
+	if err != nil {
+		return u, err
+	}
+
+	nano := db.Clock().Now().UnixNano()
+	milli := nano / 1_000_000
+	now := milli<<12 | (nano-milli*1_000_000)*4096/1_000_000
+	if now <= g.lastV7 {
+		now = g.lastV7 + 1
+	}
+	g.lastV7 = now
+	milli, frac := now>>12, now&0xfff
+
+	u[0] = byte(milli >> 40)
+	u[1] = byte(milli >> 32)
+	u[2] = byte(milli >> 24)
+	u[3] = byte(milli >> 16)
+	u[4] = byte(milli >> 8)
+	u[5] = byte(milli)
+	u[6] = 0x70 | byte(frac>>8)
+	u[7] = byte(frac)
+	u[8] = 0x80 | u[8]&0x3f
+	return u, nil
+}
+
 func fromValue(arg sqlite3.Value) (u uuid.UUID, err error) {
 	switch t := arg.Type(); t {
 	case sqlite3.TEXT:
@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/ncruces/go-sqlite3"
 	"github.com/ncruces/go-sqlite3/driver"
 	"github.com/ncruces/go-sqlite3/internal/testcfg"
 	"github.com/ncruces/go-sqlite3/vfs/memdb"
@@ -210,3 +211,45 @@ func Test_convert(t *testing.T) {
 		t.Fatal("want error")
 	}
 }
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time        { return time.Time(c) }
+func (c fixedClock) Sleep(d time.Duration) {}
+
+func Test_newV7(t *testing.T) {
+	t.Parallel()
+
+	ctx := testcfg.Context(t)
+	ctx = sqlite3.WithClock(ctx, fixedClock(time.Unix(1e9, 0)))
+	db, err := sqlite3.OpenContext(ctx, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = Register(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, _, err := db.Prepare(`
+		WITH RECURSIVE c(x) AS (VALUES(1) UNION ALL SELECT x+1 FROM c WHERE x<5000)
+		SELECT uuid(7) FROM c`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var last string
+	for stmt.Step() {
+		u := stmt.ColumnText(0)
+		if u <= last {
+			t.Fatalf("got %s after %s", u, last)
+		}
+		last = u
+	}
+	if err := stmt.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -14,7 +14,7 @@ require (
 	github.com/dchest/siphash v1.2.3 // ext/bloom
 	github.com/google/uuid v1.6.0 // ext/uuid
 	github.com/psanford/httpreadat v0.1.0 // example
-	golang.org/x/crypto v0.55.0 // vfs/adiantum vfs/xts
+	golang.org/x/crypto v0.55.0 // ext/aead vfs/adiantum vfs/xts
 	golang.org/x/sync v0.22.0 // test
 	golang.org/x/text v0.41.0 // ext/unicode
 	lukechampine.com/adiantum v1.1.1 // vfs/adiantum
@@ -3,6 +3,7 @@ package tests
 import (
 	"context"
 	"errors"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -517,3 +518,47 @@ func TestConn_TableColumnMetadata(t *testing.T) {
 		t.Error("want false")
 	}
 }
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time        { return time.Time(c) }
+func (c fixedClock) Sleep(d time.Duration) {}
+
+func TestConn_entropy(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2000, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	query := func() (res [3]string) {
+		ctx := testcfg.Context(t)
+		ctx = sqlite3.WithClock(ctx, fixedClock(now))
+		ctx = sqlite3.WithRandomness(ctx, rand.NewChaCha8([32]byte{}))
+
+		db, err := sqlite3.OpenContext(ctx, ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		stmt, _, err := db.Prepare(`SELECT datetime('now'), random(), hex(randomblob(16))`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		for i := range res {
+			res[i] = stmt.ColumnText(i)
+		}
+		return res
+	}
+
+	got := query()
+	if got[0] != "2000-01-01 12:30:00" {
+		t.Errorf("got %q", got[0])
+	}
+	if again := query(); got != again {
+		t.Errorf("got %q, want %q", again, got)
+	}
+}
@@ -3,6 +3,7 @@ package vfs
 
 import (
 	"io"
+	"time"
 
 	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
 )
@@ -192,7 +193,7 @@ type FileSharedMemory interface {
 }
 
 // SharedMemory is a shared-memory WAL-index implementation.
-// Use [NewSharedMemory] to create a shared-memory.
+// Use [NewSharedMemory] or [WALIndex] to create a shared-memory.
 type SharedMemory interface {
 	shmMap(*sqlite3_wrap.Wrapper, int32, int32, bool) (ptr_t, error)
 	shmLock(int32, int32, _ShmFlag) error
@@ -203,7 +204,7 @@ type SharedMemory interface {
 
 type blockingSharedMemory interface {
 	SharedMemory
-	shmEnableBlocking(block bool)
+	shmEnableBlocking(timeout time.Duration)
 }
 
 // FileControl makes it easy to forward all fileControl methods,
@@ -0,0 +1,36 @@
+// Package appendvfs implements the "apndvfs" SQLite VFS.
+//
+// The "apndvfs" [vfs.VFS] wraps the default VFS,
+// and allows an SQLite database to be appended
+// to the end of some other file, such as an executable or an archive.
+//
+// Importing package appendvfs registers the VFS:
+//
+//	import _ "github.com/ncruces/go-sqlite3/vfs/appendvfs"
+//
+// When opening a file:
+//   - an ordinary SQLite database is opened as usual;
+//   - a file with an appended database opens that database;
+//   - with [sqlite3.OPEN_CREATE], a new database is appended
+//     to any other file (which may be empty).
+//
+// The appended database starts at a multiple of 4096 bytes
+// past the end of the original file,
+// and is followed by a 25-byte trailer with its starting offset.
+// Appended databases are limited to 1GiB.
+//
+// This is compatible with SQLite's [appendvfs.c].
+//
+// [appendvfs.c]: https://sqlite.org/src/file/ext/misc/appendvfs.c
+package appendvfs
+
+import "github.com/ncruces/go-sqlite3/vfs"
+
+func init() {
+	vfs.Register("apndvfs", Wrap(vfs.Find("")))
+}
+
+// Wrap wraps a base VFS to create an append VFS.
+func Wrap(base vfs.VFS) vfs.VFS {
+	return &appendVFS{base}
+}
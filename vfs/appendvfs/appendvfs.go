@@ -0,0 +1,216 @@
+package appendvfs
+
+import (
+	"encoding/binary"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+const (
+	markPrefix = "Start-Of-SQLite3-"
+	markSize   = len(markPrefix) + 8
+	maxSize    = 0x40000000 // 1GiB
+	roundUp    = 4096
+	header     = "SQLite format 3\x00"
+)
+
+type appendVFS struct {
+	vfs.VFS
+}
+
+func (a *appendVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpen(a.VFS, name, flags)
+	return wrapFile(file, flags, err)
+}
+
+func (a *appendVFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpenFilename(a.VFS, name, flags)
+	return wrapFile(file, flags, err)
+}
+
+func wrapFile(file vfs.File, flags vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	// Only main databases are appended.
+	if err != nil || flags&vfs.OPEN_MAIN_DB == 0 {
+		return file, flags, err
+	}
+
+	size, err := file.Size()
+	if err != nil {
+		file.Close()
+		return nil, flags, err
+	}
+
+	// Ordinary databases are opened as usual.
+	if isOrdinary(file, size) {
+		return file, flags, nil
+	}
+
+	if start := readMark(file, size); start >= 0 {
+		return &appendFile{
+			File:  file,
+			start: start,
+			mark:  size - int64(markSize),
+		}, flags, nil
+	}
+
+	if flags&vfs.OPEN_CREATE == 0 {
+		file.Close()
+		return nil, flags, sqlite3.CANTOPEN
+	}
+	// The mark is written along with the first write.
+	return &appendFile{
+		File:  file,
+		start: (size + roundUp - 1) &^ (roundUp - 1),
+		mark:  -1,
+	}, flags, nil
+}
+
+// readMark returns the start of the appended database,
+// or -1 if the file doesn't end with a valid mark.
+func readMark(file vfs.File, size int64) int64 {
+	if size < int64(markSize) {
+		return -1
+	}
+	var buf [markSize]byte
+	if n, _ := file.ReadAt(buf[:], size-int64(markSize)); n != markSize {
+		return -1
+	}
+	if string(buf[:len(markPrefix)]) != markPrefix {
+		return -1
+	}
+	start := int64(binary.BigEndian.Uint64(buf[len(markPrefix):]) &^ (1 << 63))
+	if start > size-int64(markSize)-512 {
+		return -1
+	}
+	return start
+}
+
+// isAppended reports whether the file has an appended database.
+func isAppended(file vfs.File, size int64) bool {
+	if size <= int64(markSize)+512 {
+		return false
+	}
+	start := readMark(file, size)
+	return start >= 0 && hasHeader(file, start)
+}
+
+// isOrdinary reports whether the file is an ordinary database.
+func isOrdinary(file vfs.File, size int64) bool {
+	return size&0x1ff == 0 && size > 0 &&
+		!isAppended(file, size) && hasHeader(file, 0)
+}
+
+func hasHeader(file vfs.File, off int64) bool {
+	var buf [len(header)]byte
+	n, _ := file.ReadAt(buf[:], off)
+	return n == len(buf) && string(buf[:]) == header
+}
+
+type appendFile struct {
+	vfs.File
+	start int64 // the start of the database
+	mark  int64 // the offset of the mark, or -1
+}
+
+// writeMark writes the mark after a database of the given size.
+func (a *appendFile) writeMark(size int64) error {
+	mark := a.start + size
+	var buf [markSize]byte
+	copy(buf[:], markPrefix)
+	binary.BigEndian.PutUint64(buf[len(markPrefix):], uint64(a.start))
+	if _, err := a.File.WriteAt(buf[:], mark); err != nil {
+		return err
+	}
+	a.mark = mark
+	return nil
+}
+
+func (a *appendFile) ReadAt(p []byte, off int64) (int, error) {
+	return a.File.ReadAt(p, a.start+off)
+}
+
+func (a *appendFile) WriteAt(p []byte, off int64) (int, error) {
+	end := off + int64(len(p))
+	if end >= maxSize {
+		return 0, sqlite3.FULL
+	}
+	// Move the mark past the end of the database.
+	if a.mark < a.start+end {
+		if err := a.writeMark(end); err != nil {
+			return 0, err
+		}
+	}
+	return a.File.WriteAt(p, a.start+off)
+}
+
+func (a *appendFile) Truncate(size int64) error {
+	if err := a.writeMark(size); err != nil {
+		return sqlite3.IOERR_TRUNCATE
+	}
+	return a.File.Truncate(a.mark + int64(markSize))
+}
+
+func (a *appendFile) Size() (int64, error) {
+	if a.mark < 0 {
+		return 0, nil
+	}
+	return a.mark - a.start, nil
+}
+
+func (a *appendFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return a.File.DeviceCharacteristics() & (0 |
+		// These flags are safe:
+		vfs.IOCAP_IMMUTABLE |
+		vfs.IOCAP_SEQUENTIAL |
+		vfs.IOCAP_SUBPAGE_READ |
+		vfs.IOCAP_POWERSAFE_OVERWRITE |
+		vfs.IOCAP_UNDELETABLE_WHEN_OPEN)
+}
+
+func (a *appendFile) SizeHint(size int64) error {
+	return nil // notest
+}
+
+// Wrap optional methods.
+
+func (a *appendFile) Unwrap() vfs.File {
+	return a.File // notest
+}
+
+func (a *appendFile) SharedMemory() vfs.SharedMemory {
+	return vfsutil.WrapSharedMemory(a.File)
+}
+
+func (a *appendFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(a.File) // notest
+}
+
+func (a *appendFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(a.File) // notest
+}
+
+func (a *appendFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(a.File, keepWAL) // notest
+}
+
+func (a *appendFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(a.File) // notest
+}
+
+func (a *appendFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(a.File) // notest
+}
+
+func (a *appendFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(a.File) // notest
+}
+
+func (a *appendFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(a.File, handler) // notest
+}
+
+func (a *appendFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(a.File, name, value) // notest
+}
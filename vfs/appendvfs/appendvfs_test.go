@@ -0,0 +1,73 @@
+package appendvfs_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	_ "github.com/ncruces/go-sqlite3/vfs/appendvfs"
+)
+
+func Test_append(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+
+	prefix := bytes.Repeat([]byte("executable"), 1000)
+	name := filepath.Join(t.TempDir(), "tool")
+	err := os.WriteFile(name, prefix, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := "file:" + filepath.ToSlash(name) + "?vfs=apndvfs"
+
+	// Without OPEN_CREATE, the file has no database.
+	_, err = sqlite3.OpenContext(ctx, dsn+"&mode=ro")
+	if err == nil {
+		t.Error("want error")
+	}
+
+	db, err := sqlite3.OpenContext(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TABLE test (col); INSERT INTO test VALUES ('data')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, prefix) {
+		t.Error("prefix overwritten")
+	}
+	if !bytes.HasPrefix(data[12288:], []byte("SQLite format 3\x00")) {
+		t.Error("database not found at the expected offset")
+	}
+	if !bytes.Contains(data[len(data)-25:], []byte("Start-Of-SQLite3-")) {
+		t.Error("mark not found")
+	}
+
+	db, err = sqlite3.OpenContext(ctx, dsn+"&mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT col FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "data" {
+		t.Errorf("got %q", got)
+	}
+}
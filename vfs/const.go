@@ -254,4 +254,7 @@ const (
 	_SHM_NLOCK = 8
 	_SHM_BASE  = (22 + _SHM_NLOCK) * 4
 	_SHM_DMS   = _SHM_BASE + _SHM_NLOCK
+
+	_WALINDEX_HDR_SIZE = 136
+	_WALINDEX_PGSZ     = 32768
 )
@@ -0,0 +1,182 @@
+// Package faultvfs wraps an SQLite VFS to inject faults for testing.
+//
+// The [VFS] returned by [Wrap] can fail the Nth read, write, truncate,
+// sync or lock operation with a given error, and can simulate a crash
+// (e.g. a power loss), where data not yet synced is lost, or torn.
+//
+// To achieve this, data written to a file is buffered in memory
+// until the file is synced.
+// Reads see buffered data, and all connections share buffered data,
+// as they'd share the OS page cache.
+// Buffered data outlives the handles that wrote it,
+// and is lost in a crash, unless a later handle syncs the file.
+//
+// The wrapped VFS must be registered to be used:
+//
+//	fault := faultvfs.Wrap(vfs.Find("memdb"))
+//	vfs.Register("faultmemdb", fault)
+//	dsn := memdb.TestDB(t, url.Values{"vfs": {"faultmemdb"}})
+package faultvfs
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// Op is a set of file operations.
+type Op uint32
+
+const (
+	OpRead Op = 1 << iota
+	OpWrite
+	OpTruncate
+	OpSync
+	OpLock
+)
+
+// Fault describes a fault to inject.
+type Fault struct {
+	// Op are the operations that trigger the fault.
+	Op Op
+	// Count is the number of matching operations
+	// that succeed before the fault triggers.
+	Count int
+	// Persist keeps the fault active after it first triggers,
+	// failing all subsequent matching operations.
+	Persist bool
+	// Crash simulates a crash, as if calling [VFS.Crash],
+	// when the fault triggers.
+	Crash bool
+	// Err is returned by failing operations.
+	// Use sqlite3.FULL to simulate ENOSPC.
+	// If nil, an appropriate sqlite3.IOERR is returned.
+	Err error
+}
+
+// VFS is a fault injecting VFS.
+type VFS struct {
+	vfs.VFS
+
+	mtx sync.Mutex
+	// +checklocks:mtx
+	files map[string]*fileState
+	// +checklocks:mtx
+	faults []*Fault
+	// +checklocks:mtx
+	epoch int
+}
+
+// Wrap wraps a base VFS to create a fault injecting VFS.
+func Wrap(base vfs.VFS) *VFS {
+	return &VFS{
+		VFS:   base,
+		files: map[string]*fileState{},
+	}
+}
+
+// Inject adds a fault to inject.
+// Counting matching operations starts with this call.
+func (v *VFS) Inject(fault Fault) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.faults = append(v.faults, &fault)
+}
+
+// Clear removes all faults.
+func (v *VFS) Clear() {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.faults = nil
+}
+
+// Crash simulates a crash.
+//
+// Data written to files, but not synced, is lost.
+// If torn is not nil, it is used as a source of randomness
+// to decide which sectors of unsynced data persist instead,
+// simulating torn writes.
+//
+// After a crash, all operations on files that were open fail,
+// except Close. Close all connections, then reopen databases
+// to recover from the crash.
+func (v *VFS) Crash(torn *rand.Rand) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.crash(torn)
+}
+
+// +checklocks:v.mtx
+func (v *VFS) crash(torn *rand.Rand) {
+	for _, st := range v.files {
+		if torn != nil && st.image != nil {
+			st.tear(v.VFS, torn)
+		}
+		st.discard()
+	}
+	clear(v.files)
+	v.epoch++
+}
+
+// Snapshot returns the durable contents of all currently open files,
+// indexed by name.
+// Durable contents exclude data that was written, but not synced.
+func (v *VFS) Snapshot() (map[string][]byte, error) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	res := make(map[string][]byte, len(v.files))
+	for name, st := range v.files {
+		if len(st.handles) == 0 {
+			continue
+		}
+		data, err := readAll(st.handles[0].File)
+		if err != nil {
+			return nil, err
+		}
+		res[name] = data
+	}
+	return res, nil
+}
+
+// +checklocks:v.mtx
+func (v *VFS) fault(op Op) error {
+	for i, f := range v.faults {
+		if f.Op&op == 0 {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			continue
+		}
+		if !f.Persist {
+			v.faults = append(v.faults[:i], v.faults[i+1:]...)
+		}
+		if f.Crash {
+			v.crash(nil)
+		}
+		if f.Err != nil {
+			return f.Err
+		}
+		return opError(op)
+	}
+	return nil
+}
+
+func opError(op Op) error {
+	switch op {
+	case OpRead:
+		return sqlite3.IOERR_READ
+	case OpWrite:
+		return sqlite3.IOERR_WRITE
+	case OpTruncate:
+		return sqlite3.IOERR_TRUNCATE
+	case OpSync:
+		return sqlite3.IOERR_FSYNC
+	case OpLock:
+		return sqlite3.IOERR_LOCK
+	}
+	return sqlite3.IOERR
+}
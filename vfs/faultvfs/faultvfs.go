@@ -0,0 +1,354 @@
+package faultvfs
+
+import (
+	"io"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func (v *VFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, out, err := vfsutil.WrapOpen(v.VFS, name, flags)
+	return v.wrapFile(name, flags, file, out, err)
+}
+
+func (v *VFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, out, err := vfsutil.WrapOpenFilename(v.VFS, name, flags)
+	return v.wrapFile(name.String(), flags, file, out, err)
+}
+
+func (v *VFS) Delete(name string, syncDir bool) error {
+	// Buffered data of a deleted file is lost.
+	v.mtx.Lock()
+	if st := v.files[name]; st != nil && len(st.handles) == 0 {
+		st.discard()
+		delete(v.files, name)
+	}
+	v.mtx.Unlock()
+	return v.VFS.Delete(name, syncDir)
+}
+
+func (v *VFS) wrapFile(name string, flags vfs.OpenFlag, file vfs.File, out vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	if err != nil {
+		return file, out, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	// Temporary files are never shared.
+	var st *fileState
+	if name != "" {
+		st = v.files[name]
+	}
+	if st == nil {
+		st = &fileState{name: name, flags: flags}
+		if name != "" {
+			v.files[name] = st
+		}
+	}
+
+	f := &faultFile{File: file, vfs: v, state: st, epoch: v.epoch}
+	st.handles = append(st.handles, f)
+	return f, out, nil
+}
+
+// fileState is shared by all handles for the same file.
+// It holds data that has been written, but not synced,
+// and outlives the handles, until the file is synced or deleted.
+type fileState struct {
+	name    string
+	flags   vfs.OpenFlag
+	handles []*faultFile
+	image   []byte
+	dirty   map[int64]struct{}
+	sector  int64
+}
+
+// load makes a copy of the durable data, so it can be modified.
+func (st *fileState) load(base vfs.File) error {
+	if st.image != nil {
+		return nil
+	}
+	data, err := readAll(base)
+	if err != nil {
+		return err
+	}
+	st.image = data
+	st.dirty = map[int64]struct{}{}
+	st.sector = int64(max(512, base.SectorSize()))
+	return nil
+}
+
+// write modifies the buffered data.
+func (st *fileState) write(b []byte, off int64) {
+	if end := off + int64(len(b)); end > int64(len(st.image)) {
+		st.image = append(st.image, make([]byte, end-int64(len(st.image)))...)
+	}
+	copy(st.image[off:], b)
+	for i := off / st.sector; i*st.sector < off+int64(len(b)); i++ {
+		st.dirty[i] = struct{}{}
+	}
+}
+
+// truncate modifies the size of the buffered data.
+func (st *fileState) truncate(size int64) {
+	if size > int64(len(st.image)) {
+		st.image = append(st.image, make([]byte, size-int64(len(st.image)))...)
+	} else {
+		st.image = st.image[:size]
+	}
+}
+
+// flush writes the buffered data that passes filter to base.
+func (st *fileState) flush(base vfs.File, filter func(sector int64) bool) error {
+	if st.image == nil {
+		return nil
+	}
+
+	sectors := make([]int64, 0, len(st.dirty))
+	for i := range st.dirty {
+		sectors = append(sectors, i)
+	}
+	slices.Sort(sectors)
+
+	size := int64(len(st.image))
+	for _, i := range sectors {
+		if filter != nil && !filter(i) {
+			continue
+		}
+		start := i * st.sector
+		if start >= size {
+			continue
+		}
+		end := min(start+st.sector, size)
+		if _, err := base.WriteAt(st.image[start:end], start); err != nil {
+			return err
+		}
+	}
+	if filter == nil || filter(-1) {
+		return base.Truncate(size)
+	}
+	return nil
+}
+
+// tear persists a random subset of the buffered data,
+// through an open handle, or by reopening the file.
+func (st *fileState) tear(base vfs.VFS, torn *rand.Rand) {
+	filter := func(int64) bool { return torn.IntN(2) == 0 }
+	if len(st.handles) > 0 {
+		st.flush(st.handles[0].File, filter)
+		return
+	}
+	flags := st.flags &^ (vfs.OPEN_CREATE | vfs.OPEN_EXCLUSIVE)
+	if f, _, err := vfsutil.WrapOpen(base, st.name, flags); err == nil {
+		st.flush(f, filter)
+		f.Close()
+	}
+}
+
+// discard drops buffered data.
+func (st *fileState) discard() {
+	st.image = nil
+	st.dirty = nil
+}
+
+func readAll(f vfs.File) ([]byte, error) {
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	n, err := f.ReadAt(buf, 0)
+	if err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+type faultFile struct {
+	vfs.File
+	vfs   *VFS
+	state *fileState
+	epoch int
+}
+
+// +checklocks:f.vfs.mtx
+func (f *faultFile) check(op Op) error {
+	if f.epoch != f.vfs.epoch {
+		return opError(op)
+	}
+	if err := f.vfs.fault(op); err != nil {
+		return err
+	}
+	if f.epoch != f.vfs.epoch {
+		return opError(op)
+	}
+	return nil
+}
+
+func (f *faultFile) Close() error {
+	f.vfs.mtx.Lock()
+	st := f.state
+	if i := slices.Index(st.handles, f); i >= 0 {
+		st.handles = slices.Delete(st.handles, i, i+1)
+	}
+	// When the last handle to a file is closed,
+	// buffered data remains in the OS page cache,
+	// and is still lost in a crash, unless the file is reopened and synced.
+	// Keep it, unless there is none, or the file is deleted on close.
+	if len(st.handles) == 0 && (st.image == nil || st.name == "" ||
+		st.flags&vfs.OPEN_DELETEONCLOSE != 0) {
+		st.discard()
+		if f.vfs.files[st.name] == st {
+			delete(f.vfs.files, st.name)
+		}
+	}
+	f.vfs.mtx.Unlock()
+
+	return f.File.Close()
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (n int, err error) {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	if err := f.check(OpRead); err != nil {
+		return 0, err
+	}
+	if img := f.state.image; img != nil {
+		if off < int64(len(img)) {
+			n = copy(p, img[off:])
+		}
+		if n < len(p) {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return f.File.ReadAt(p, off)
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (n int, err error) {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	if err := f.check(OpWrite); err != nil {
+		return 0, err
+	}
+	if err := f.state.load(f.File); err != nil {
+		return 0, err
+	}
+	f.state.write(p, off)
+	return len(p), nil
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	if err := f.check(OpTruncate); err != nil {
+		return err
+	}
+	if err := f.state.load(f.File); err != nil {
+		return err
+	}
+	f.state.truncate(size)
+	return nil
+}
+
+func (f *faultFile) Size() (int64, error) {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	if f.epoch != f.vfs.epoch {
+		return 0, sqlite3.IOERR_FSTAT
+	}
+	if img := f.state.image; img != nil {
+		return int64(len(img)), nil
+	}
+	return f.File.Size()
+}
+
+func (f *faultFile) Sync(flags vfs.SyncFlag) error {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	if err := f.check(OpSync); err != nil {
+		return err
+	}
+	if err := f.state.flush(f.File, nil); err != nil {
+		return err
+	}
+	f.state.discard()
+	return f.File.Sync(flags)
+}
+
+func (f *faultFile) Lock(lock vfs.LockLevel) error {
+	f.vfs.mtx.Lock()
+	err := f.check(OpLock)
+	f.vfs.mtx.Unlock()
+
+	if err != nil {
+		return err
+	}
+	// Don't hold the mutex while (possibly) blocking.
+	return f.File.Lock(lock)
+}
+
+func (f *faultFile) Unlock(lock vfs.LockLevel) error {
+	return f.File.Unlock(lock)
+}
+
+func (f *faultFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	// Unsynced data may be lost, or torn at sector boundaries.
+	return f.File.DeviceCharacteristics() & (0 |
+		vfs.IOCAP_IMMUTABLE |
+		vfs.IOCAP_SUBPAGE_READ |
+		vfs.IOCAP_POWERSAFE_OVERWRITE |
+		vfs.IOCAP_UNDELETABLE_WHEN_OPEN)
+}
+
+func (f *faultFile) SectorSize() int {
+	return max(512, f.File.SectorSize())
+}
+
+// Wrap optional methods.
+
+func (f *faultFile) Unwrap() vfs.File {
+	return f.File // notest
+}
+
+func (f *faultFile) SharedMemory() vfs.SharedMemory {
+	return vfsutil.WrapSharedMemory(f.File)
+}
+
+func (f *faultFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(f.File)
+}
+
+func (f *faultFile) ChunkSize(size int) {
+	vfsutil.WrapChunkSize(f.File, size) // notest
+}
+
+func (f *faultFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(f.File) // notest
+}
+
+func (f *faultFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(f.File) // notest
+}
+
+func (f *faultFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(f.File) // notest
+}
+
+func (f *faultFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(f.File, handler) // notest
+}
+
+func (f *faultFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(f.File, name, value) // notest
+}
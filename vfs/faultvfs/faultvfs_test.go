@@ -0,0 +1,166 @@
+package faultvfs_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+	"github.com/ncruces/go-sqlite3/vfs/faultvfs"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+)
+
+func Test_full(t *testing.T) {
+	t.Parallel()
+
+	fault := faultvfs.Wrap(vfs.Find("memdb"))
+	vfs.Register("faultmemdb", fault)
+	dsn := memdb.TestDB(t, url.Values{"vfs": {"faultmemdb"}})
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fault.Inject(faultvfs.Fault{Op: faultvfs.OpWrite, Err: sqlite3.FULL, Persist: true})
+
+	err = db.Exec(`INSERT INTO test VALUES (randomblob(65536))`)
+	if !errors.Is(err, sqlite3.FULL) {
+		t.Errorf("got %v", err)
+	}
+
+	fault.Clear()
+
+	err = db.Exec(`INSERT INTO test VALUES (randomblob(65536))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_crash(t *testing.T) {
+	t.Parallel()
+
+	fault := faultvfs.Wrap(vfs.Find(""))
+	vfs.Register("faultcrash", fault)
+
+	for _, journal := range []string{"delete", "wal"} {
+		for count := range 6 {
+			for _, torn := range []*rand.Rand{nil, rand.New(rand.NewPCG(1, uint64(count)))} {
+				testCrash(t, fault, journal, count, torn)
+			}
+		}
+	}
+}
+
+func testCrash(t *testing.T, fault *faultvfs.VFS, journal string, count int, torn *rand.Rand) {
+	dsn := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.db")) +
+		"?vfs=faultcrash"
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		PRAGMA journal_mode=` + journal + `;
+		CREATE TABLE test (col);
+		INSERT INTO test VALUES (randomblob(10000));
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fault.Inject(faultvfs.Fault{Op: faultvfs.OpSync, Count: count, Crash: true})
+	db.Exec(`INSERT INTO test SELECT randomblob(10000) FROM test`)
+	fault.Clear()
+	fault.Crash(torn)
+	db.Close()
+
+	db, err = sqlite3.OpenContext(testcfg.Context(t), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT (SELECT count(*) FROM test), (SELECT * FROM pragma_integrity_check)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if n := stmt.ColumnInt(0); n != 1 && n != 2 {
+		t.Errorf("got %d rows", n)
+	}
+	if s := stmt.ColumnText(1); s != "ok" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func Test_close(t *testing.T) {
+	t.Parallel()
+
+	fault := faultvfs.Wrap(vfs.Find(""))
+	vfs.Register("faultclose", fault)
+
+	dsn := "file:" + filepath.ToSlash(filepath.Join(t.TempDir(), "test.db")) +
+		"?vfs=faultclose"
+
+	exec := func(sql string) {
+		t.Helper()
+		db, err := sqlite3.OpenContext(testcfg.Context(t), dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.Exec(sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		t.Helper()
+		db, err := sqlite3.OpenContext(testcfg.Context(t), dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		stmt, _, err := db.Prepare(`SELECT count(*) FROM sqlite_schema`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		return stmt.ColumnInt(0)
+	}
+
+	// Closing a file doesn't make unsynced data durable.
+	exec(`PRAGMA synchronous=off; CREATE TABLE t1 (col);`)
+	fault.Crash(nil)
+	if n := count(); n != 0 {
+		t.Errorf("got %d tables", n)
+	}
+
+	// Syncing a reopened file does.
+	exec(`PRAGMA synchronous=off; CREATE TABLE t1 (col);`)
+	exec(`PRAGMA synchronous=full; CREATE TABLE t2 (col);`)
+	fault.Crash(nil)
+	if n := count(); n != 2 {
+		t.Errorf("got %d tables", n)
+	}
+}
@@ -74,14 +74,14 @@ func (vfsOS) Access(name string, flags AccessFlag) (bool, error) {
 }
 
 func (vfsOS) Open(name string, flags OpenFlag) (File, OpenFlag, error) {
-	// notest // OpenFilename is called instead
-	if name == "" {
-		return vfsOS{}.OpenFilename(nil, flags)
-	}
-	return nil, 0, _CANTOPEN
+	return vfsOS{}.open(name, "", flags)
 }
 
 func (vfsOS) OpenFilename(name *Filename, flags OpenFlag) (File, OpenFlag, error) {
+	return vfsOS{}.open(name.String(), name.URIParameter("modeof"), flags)
+}
+
+func (vfsOS) open(name, modeof string, flags OpenFlag) (File, OpenFlag, error) {
 	oflags := _O_NOFOLLOW
 	if flags&OPEN_EXCLUSIVE != 0 {
 		oflags |= os.O_EXCL
@@ -101,15 +101,15 @@ func (vfsOS) OpenFilename(name *Filename, flags OpenFlag) (File, OpenFlag, error
 
 	var err error
 	var f *os.File
-	if name == nil {
+	if name == "" {
 		f, err = osCreateTemp(flags)
 	} else {
-		f, err = os.OpenFile(name.String(), oflags, 0666)
+		f, err = os.OpenFile(name, oflags, 0666)
 		if errors.Is(err, syscall.EISDIR) {
 			return nil, flags, sysError{err, _CANTOPEN_ISDIR}
 		}
 		if isCreate && isJournl && errors.Is(err, fs.ErrPermission) &&
-			osAccess(name.String(), ACCESS_EXISTS) != nil {
+			osAccess(name, ACCESS_EXISTS) != nil {
 			return nil, flags, sysError{err, _READONLY_DIRECTORY}
 		}
 	}
@@ -117,7 +117,7 @@ func (vfsOS) OpenFilename(name *Filename, flags OpenFlag) (File, OpenFlag, error
 		return nil, flags, err
 	}
 
-	if modeof := name.URIParameter("modeof"); modeof != "" {
+	if modeof != "" {
 		if err = osSetMode(f, modeof); err != nil {
 			f.Close()
 			return nil, flags, sysError{err, _IOERR_FSTAT}
@@ -134,8 +134,8 @@ func (vfsOS) OpenFilename(name *Filename, flags OpenFlag) (File, OpenFlag, error
 	if isUnix && isCreate && isJournl {
 		file.flags |= _FLAG_SYNC_DIR
 	}
-	if name.String() != "" {
-		file.shm = NewSharedMemory(name.String()+"-shm", flags)
+	if name != "" {
+		file.shm = NewSharedMemory(name+"-shm", flags)
 	}
 	return &file, flags, nil
 }
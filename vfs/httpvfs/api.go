@@ -0,0 +1,53 @@
+// Package httpvfs implements an SQLite VFS for remote, immutable databases.
+//
+// The "http" [vfs.VFS] permits querying a database hosted on an HTTP server
+// (e.g. a CDN or object store) that supports range requests.
+// Only the pages needed to answer a query are downloaded,
+// and downloaded pages are cached.
+//
+// Importing package httpvfs registers the VFS:
+//
+//	import _ "github.com/ncruces/go-sqlite3/vfs/httpvfs"
+//
+// A [Reader] can also be used with the "reader" VFS,
+// through [readervfs.Create].
+//
+// [readervfs.Create]: https://pkg.go.dev/github.com/ncruces/go-sqlite3/vfs/readervfs#Create
+package httpvfs
+
+import (
+	"sync"
+
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func init() {
+	vfs.Register("http", httpVFS{})
+}
+
+var (
+	httpMtx sync.RWMutex
+	// +checklocks:httpMtx
+	httpDBs = map[string]*Reader{}
+)
+
+// Create creates an immutable database from the resource at url.
+// The resource should not change while the database is in use:
+// if a change is detected, reads fail with [ErrModified].
+func Create(name, url string, opts *Options) {
+	CreateReader(name, NewReader(url, opts))
+}
+
+// CreateReader creates an immutable database from reader.
+func CreateReader(name string, reader *Reader) {
+	httpMtx.Lock()
+	httpDBs[name] = reader
+	httpMtx.Unlock()
+}
+
+// Delete deletes a remote database.
+func Delete(name string) {
+	httpMtx.Lock()
+	delete(httpDBs, name)
+	httpMtx.Unlock()
+}
@@ -0,0 +1,54 @@
+package httpvfs_test
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	"github.com/ncruces/go-sqlite3/vfs/httpvfs"
+)
+
+//go:embed testdata/test.db
+var testDB string
+
+func Example() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "test.db", time.Time{}, strings.NewReader(testDB))
+	}))
+	defer server.Close()
+
+	httpvfs.Create("test.db", server.URL+"/test.db", nil)
+	defer httpvfs.Delete("test.db")
+
+	db, err := sql.Open("sqlite3", "file:test.db?vfs=http")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, name FROM users`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s %s\n", id, name)
+	}
+	// Output:
+	// 0 go
+	// 1 zig
+	// 2 whatever
+}
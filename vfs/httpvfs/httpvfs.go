@@ -0,0 +1,84 @@
+package httpvfs
+
+import (
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+type httpVFS struct{}
+
+func (httpVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	// Temporary files use the default VFS.
+	if name == "" || flags&vfs.OPEN_DELETEONCLOSE != 0 {
+		return vfs.Find("").Open(name, flags)
+	}
+	// Refuse to open all other file types.
+	if flags&vfs.OPEN_MAIN_DB == 0 {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+	httpMtx.RLock()
+	defer httpMtx.RUnlock()
+	if r, ok := httpDBs[name]; ok {
+		return httpFile{r}, flags | vfs.OPEN_READONLY, nil
+	}
+	return nil, flags, sqlite3.CANTOPEN
+}
+
+func (httpVFS) Delete(name string, dirSync bool) error {
+	// notest // IOCAP_IMMUTABLE
+	return sqlite3.IOERR_DELETE
+}
+
+func (httpVFS) Access(name string, flag vfs.AccessFlag) (bool, error) {
+	// notest // IOCAP_IMMUTABLE
+	return false, sqlite3.IOERR_ACCESS
+}
+
+func (httpVFS) FullPathname(name string) (string, error) {
+	return name, nil
+}
+
+type httpFile struct{ *Reader }
+
+func (httpFile) Close() error {
+	return nil
+}
+
+func (httpFile) WriteAt(b []byte, off int64) (n int, err error) {
+	// notest // IOCAP_IMMUTABLE
+	return 0, sqlite3.IOERR_WRITE
+}
+
+func (httpFile) Truncate(size int64) error {
+	// notest // IOCAP_IMMUTABLE
+	return sqlite3.IOERR_TRUNCATE
+}
+
+func (httpFile) Sync(flag vfs.SyncFlag) error {
+	// notest // IOCAP_IMMUTABLE
+	return sqlite3.IOERR_FSYNC
+}
+
+func (httpFile) Lock(lock vfs.LockLevel) error {
+	// notest // IOCAP_IMMUTABLE
+	return sqlite3.IOERR_LOCK
+}
+
+func (httpFile) Unlock(lock vfs.LockLevel) error {
+	// notest // IOCAP_IMMUTABLE
+	return sqlite3.IOERR_UNLOCK
+}
+
+func (httpFile) CheckReservedLock() (bool, error) {
+	// notest // IOCAP_IMMUTABLE
+	return false, sqlite3.IOERR_CHECKRESERVEDLOCK
+}
+
+func (httpFile) SectorSize() int {
+	// notest // IOCAP_IMMUTABLE
+	return 0
+}
+
+func (httpFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return vfs.IOCAP_IMMUTABLE | vfs.IOCAP_SUBPAGE_READ
+}
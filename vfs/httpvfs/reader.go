@@ -0,0 +1,379 @@
+package httpvfs
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// ErrModified is returned (wrapped in an [sqlite3.IOERR_DATA])
+// when the remote resource changes while it is being read.
+var ErrModified = errors.New("httpvfs: remote database was modified")
+
+// Options configure a [Reader].
+type Options struct {
+	// Client issues HTTP requests.
+	// If nil, [http.DefaultClient] is used.
+	Client *http.Client
+
+	// BlockSize is the unit of transfer and caching, in bytes.
+	// It is rounded up to a power of two, and should be
+	// at least the database page size.
+	// If zero, 4096 is used.
+	BlockSize int
+
+	// CacheSize is the maximum number of cached blocks.
+	// If zero, 1024 is used.
+	CacheSize int
+
+	// ReadAhead is the maximum number of additional blocks
+	// fetched when a sequential scan is detected.
+	// The read-ahead window doubles on each sequential miss,
+	// and resets on random access.
+	// If zero, 32 is used; if negative, read-ahead is disabled.
+	ReadAhead int
+}
+
+// Reader implements [io.ReaderAt] for a remote resource,
+// using HTTP range requests.
+// Reader is safe for concurrent use.
+type Reader struct {
+	url    string
+	client *http.Client
+	block  int64
+	limit  int
+	ahead  int
+
+	mtx sync.Mutex
+	// +checklocks:mtx
+	size int64
+	// +checklocks:mtx
+	etag string
+	// +checklocks:mtx
+	next int64
+	// +checklocks:mtx
+	window int
+	// +checklocks:mtx
+	cache map[int64]*list.Element
+	// +checklocks:mtx
+	lru list.List
+	// +checklocks:mtx
+	pending map[int64]*fetch
+	// +checklocks:mtx
+	gen uint64
+}
+
+type block struct {
+	index int64
+	data  []byte
+}
+
+// fetch is an in-flight request for blocks.
+// Its results are set before done is closed.
+type fetch struct {
+	done  chan struct{}
+	index int64 // the first block
+	data  []byte
+	err   error
+}
+
+// block returns the data of block index.
+func (f *fetch) block(index, size int64) []byte {
+	off := (index - f.index) * size
+	if off >= int64(len(f.data)) {
+		return nil
+	}
+	return f.data[off:min(off+size, int64(len(f.data)))]
+}
+
+// NewReader creates a Reader for the resource at url.
+// The resource is not accessed until it is first read.
+func NewReader(url string, opts *Options) *Reader {
+	if opts == nil {
+		opts = &Options{}
+	}
+	r := &Reader{
+		url:    url,
+		client: opts.Client,
+		block:  4096,
+		limit:  1024,
+		ahead:  32,
+		size:   -1,
+		cache:  map[int64]*list.Element{},
+
+		pending: map[int64]*fetch{},
+	}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+	if opts.BlockSize > 0 {
+		r.block = 1 << bits.Len(uint(opts.BlockSize-1))
+	}
+	if opts.CacheSize > 0 {
+		r.limit = opts.CacheSize
+	}
+	if opts.ReadAhead != 0 {
+		r.ahead = max(0, opts.ReadAhead)
+	}
+	return r
+}
+
+// Size returns the size of the remote resource.
+func (r *Reader) Size() (int64, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.size < 0 {
+		if _, err := r.load(0); err != nil {
+			return 0, err
+		}
+	}
+	return r.size, nil
+}
+
+// ReadAt implements [io.ReaderAt].
+func (r *Reader) ReadAt(p []byte, off int64) (n int, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for n < len(p) {
+		pos := off + int64(n)
+		if r.size >= 0 && pos >= r.size {
+			return n, io.EOF
+		}
+		index := pos / r.block
+		data, err := r.load(index)
+		if err != nil {
+			return n, err
+		}
+		if rest := pos - index*r.block; rest < int64(len(data)) {
+			n += copy(p[n:], data[rest:])
+		} else {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Reset drops all cached data, and forgets the size and version
+// of the remote resource, which will be fetched again on the next read.
+// After a Reset, a Reader can be reused after the resource changes,
+// but connections using it must be reopened.
+func (r *Reader) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.size = -1
+	r.etag = ""
+	r.reset()
+}
+
+// +checklocks:r.mtx
+func (r *Reader) reset() {
+	clear(r.cache)
+	clear(r.pending)
+	r.lru.Init()
+	r.next = 0
+	r.window = 0
+	r.gen++
+}
+
+// load returns the data of block index.
+// The lock is released while waiting for the remote resource.
+//
+// +checklocks:r.mtx
+func (r *Reader) load(index int64) ([]byte, error) {
+	if e, ok := r.cache[index]; ok {
+		r.lru.MoveToFront(e)
+		return e.Value.(*block).data, nil
+	}
+
+	// Wait for a request that is already fetching the block.
+	if f, ok := r.pending[index]; ok {
+		r.mtx.Unlock()
+		<-f.done
+		r.mtx.Lock()
+		return f.block(index, r.block), f.err
+	}
+
+	// Grow the read-ahead window on sequential misses,
+	// reset it on random access.
+	if index == r.next && index != 0 {
+		r.window = min(max(1, 2*r.window), r.ahead)
+	} else {
+		r.window = 0
+	}
+
+	// Fetch up to the next cached, or pending, block, or the end of the file.
+	end := index + 1
+	for end <= index+int64(r.window) {
+		if _, ok := r.cache[end]; ok {
+			break
+		}
+		if _, ok := r.pending[end]; ok {
+			break
+		}
+		if r.size >= 0 && end*r.block >= r.size {
+			break
+		}
+		end++
+	}
+	r.next = end
+
+	f := &fetch{done: make(chan struct{}), index: index}
+	for i := index; i < end; i++ {
+		r.pending[i] = f
+	}
+	defer close(f.done)
+
+	start, stop := index*r.block, end*r.block
+	if r.size >= 0 {
+		stop = min(stop, r.size)
+	}
+	etag, gen := r.etag, r.gen
+
+	r.mtx.Unlock()
+	data, size, tag, err := r.fetch(start, stop, etag)
+	r.mtx.Lock()
+
+	for i := index; i < end; i++ {
+		if r.pending[i] == f {
+			delete(r.pending, i)
+		}
+	}
+	if err == nil && gen == r.gen {
+		if r.size < 0 {
+			r.size = size
+			r.etag = tag
+		} else if r.size != size || r.etag != tag {
+			err = ErrModified
+		}
+	}
+	if errors.Is(err, ErrModified) {
+		err = r.modified()
+	}
+	if err != nil {
+		f.err = err
+		return nil, err
+	}
+
+	f.data = data
+	if gen == r.gen {
+		for i := index; i < end; i++ {
+			if b := f.block(i, r.block); len(b) > 0 {
+				r.store(i, b)
+			}
+		}
+	}
+	return f.block(index, r.block), nil
+}
+
+// +checklocks:r.mtx
+func (r *Reader) store(index int64, data []byte) {
+	for r.lru.Len() >= r.limit {
+		e := r.lru.Back()
+		delete(r.cache, r.lru.Remove(e).(*block).index)
+	}
+	r.cache[index] = r.lru.PushFront(&block{index, data})
+}
+
+// fetch requests the bytes from start to end of the remote resource,
+// if it matches etag.
+// It returns the data, and the size and ETag of the resource.
+func (r *Reader) fetch(start, end int64, etag string) (data []byte, size int64, tag string, err error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		req.Header.Set("If-Match", etag)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		// Expected.
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Happens for empty files.
+		_, _, size, ok := parseContentRange(res.Header.Get("Content-Range"))
+		if ok && size == 0 {
+			return nil, 0, "", nil
+		}
+		return nil, 0, "", ErrModified
+	case http.StatusPreconditionFailed:
+		return nil, 0, "", ErrModified
+	case http.StatusOK:
+		return nil, 0, "", vfs.SystemError(
+			errors.New("httpvfs: server does not support range requests"),
+			sqlite3.IOERR_READ)
+	default:
+		return nil, 0, "", vfs.SystemError(
+			fmt.Errorf("httpvfs: unexpected status: %s", res.Status),
+			sqlite3.IOERR_READ)
+	}
+
+	// The server must return the requested range,
+	// truncated to the end of the resource.
+	first, last, size, ok := parseContentRange(res.Header.Get("Content-Range"))
+	if !ok || first != start || last+1 != min(end, size) {
+		return nil, 0, "", vfs.SystemError(
+			errors.New("httpvfs: invalid Content-Range"),
+			sqlite3.IOERR_READ)
+	}
+
+	data = make([]byte, last+1-first)
+	if _, err := io.ReadFull(res.Body, data); err != nil {
+		return nil, 0, "", err
+	}
+	return data, size, res.Header.Get("ETag"), nil
+}
+
+// +checklocks:r.mtx
+func (r *Reader) modified() error {
+	r.reset()
+	return vfs.SystemError(ErrModified, sqlite3.IOERR_DATA)
+}
+
+// parseContentRange returns the first and last byte positions,
+// and the complete length, from a Content-Range header value.
+// The positions are -1 for an unsatisfied range.
+func parseContentRange(s string) (first, last, size int64, ok bool) {
+	s, ok = strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return
+	}
+	rng, s, ok := strings.Cut(s, "/")
+	if !ok {
+		return
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, 0, false
+	}
+	if rng == "*" {
+		return -1, -1, size, true
+	}
+	f, l, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, false
+	}
+	first, err1 := strconv.ParseInt(f, 10, 64)
+	last, err2 := strconv.ParseInt(l, 10, 64)
+	if err1 != nil || err2 != nil || first < 0 || last < first || last >= size {
+		return 0, 0, 0, false
+	}
+	return first, last, size, true
+}
@@ -0,0 +1,203 @@
+package httpvfs
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+)
+
+//go:embed testdata/test.db
+var testDB string
+
+func newServer(t *testing.T, etag *atomic.Value, requests *atomic.Int32) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", etag.Load().(string))
+		http.ServeContent(w, r, "test.db", time.Time{}, strings.NewReader(testDB))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	var etag atomic.Value
+	var requests atomic.Int32
+	etag.Store(`"v1"`)
+	url := newServer(t, &etag, &requests)
+
+	r := NewReader(url, &Options{BlockSize: 500, ReadAhead: -1})
+
+	size, err := r.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(testDB)) {
+		t.Errorf("got %d", size)
+	}
+
+	var buf [100]byte
+	n, err := r.ReadAt(buf[:], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != testDB[:100] {
+		t.Error("unexpected data")
+	}
+
+	n, err = r.ReadAt(buf[:], 1000)
+	if err != io.EOF {
+		t.Error(err)
+	}
+	if string(buf[:n]) != testDB[1000:] {
+		t.Error("unexpected data")
+	}
+
+	n, err = r.ReadAt(buf[:], 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != testDB[500:600] {
+		t.Error("unexpected data")
+	}
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests", got)
+	}
+}
+
+func TestReader_readAhead(t *testing.T) {
+	t.Parallel()
+
+	var etag atomic.Value
+	var requests atomic.Int32
+	etag.Store(`"v1"`)
+	url := newServer(t, &etag, &requests)
+
+	r := NewReader(url, &Options{BlockSize: 128})
+
+	var buf [128]byte
+	for off := int64(0); off < int64(len(testDB)); off += int64(len(buf)) {
+		_, err := r.ReadAt(buf[:], off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:]) != testDB[off:off+int64(len(buf))] {
+			t.Fatal("unexpected data")
+		}
+	}
+
+	// Fetches 1, 2, 3 and 2 blocks.
+	if got := requests.Load(); got != 4 {
+		t.Errorf("got %d requests", got)
+	}
+}
+
+func TestReader_modified(t *testing.T) {
+	t.Parallel()
+
+	var etag atomic.Value
+	var requests atomic.Int32
+	etag.Store(`"v1"`)
+	url := newServer(t, &etag, &requests)
+
+	r := NewReader(url, &Options{BlockSize: 512, CacheSize: 1})
+	CreateReader("modified.db", r)
+	defer Delete("modified.db")
+
+	query := func() error {
+		db, err := sqlite3.OpenContext(testcfg.Context(t), "file:modified.db?vfs=http")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.Exec(`SELECT * FROM users`)
+	}
+
+	if err := query(); err != nil {
+		t.Fatal(err)
+	}
+
+	etag.Store(`"v2"`)
+
+	err := query()
+	if !errors.Is(err, ErrModified) {
+		t.Errorf("got %v", err)
+	}
+	if !errors.Is(err, sqlite3.IOERR_DATA) {
+		t.Errorf("got %v", err)
+	}
+
+	r.Reset()
+	if err := query(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReader_concurrent(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		http.ServeContent(w, r, "test.db", time.Time{}, strings.NewReader(testDB))
+	}))
+	t.Cleanup(server.Close)
+
+	r := NewReader(server.URL, &Options{BlockSize: 512, ReadAhead: -1})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			var buf [100]byte
+			n, err := r.ReadAt(buf[:], 100)
+			if err != nil {
+				t.Error(err)
+			}
+			if string(buf[:n]) != testDB[100:200] {
+				t.Error("unexpected data")
+			}
+		})
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests", got)
+	}
+}
+
+func TestReader_contentRange(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignores the requested range.
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-99/%d", len(testDB)))
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, testDB[:100])
+	}))
+	t.Cleanup(server.Close)
+
+	r := NewReader(server.URL, &Options{BlockSize: 512, ReadAhead: -1})
+
+	var buf [100]byte
+	_, err := r.ReadAt(buf[:], 512)
+	if !errors.Is(err, sqlite3.IOERR_READ) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -3,6 +3,12 @@
 // The "memdb" [vfs.VFS] allows the same in-memory database to be shared
 // among multiple database connections in the same process,
 // as long as the database name begins with "/".
+// Shared databases also support WAL mode,
+// with WAL files and the WAL-index kept in memory.
+//
+// Memory usage can be limited per shared database, with [SetLimit],
+// and for all databases, with [SetTotalLimit].
+// Writes that exceed a limit fail with [sqlite3.FULL].
 //
 // Importing package memdb registers the VFS:
 //
@@ -35,9 +41,11 @@ var (
 // and the caller should not use data after this call.
 func Create(name string, data []byte) {
 	db := &memDB{
-		refs: 1,
-		name: name,
-		size: int64(len(data)),
+		refs:   1,
+		pinned: true,
+		name:   name,
+		size:   int64(len(data)),
+		usage:  &usage{},
 	}
 
 	// Convert data from WAL/2 to rollback journal.
@@ -60,21 +68,42 @@ func Create(name string, data []byte) {
 		}
 	}
 
+	usageMtx.Lock()
+	db.usage.size = sectors * sectorSize
+	total.size += db.usage.size
+	usageMtx.Unlock()
+
 	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
+	remove(name)
 	memoryDBs[name] = db
-	memoryMtx.Unlock()
 }
 
 // Delete deletes a shared memory database.
+// Its memory is released once all connections to it are closed.
 func Delete(name string) {
 	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
+	remove(name)
+}
+
+// +checklocks:memoryMtx
+func remove(name string) {
+	db := memoryDBs[name]
+	if db == nil {
+		return
+	}
 	delete(memoryDBs, name)
-	memoryMtx.Unlock()
+	if db.pinned {
+		db.pinned = false
+		db.unref()
+	}
 }
 
 // TestDB creates an empty shared memory database for the test to use.
 // The database is automatically deleted when the test and all its subtests complete.
 // Returns a URI filename appropriate to call Open with.
+// Params may set "vfs" to a VFS that wraps the "memdb" VFS.
 //
 //	func Test_something(t *testing.T) {
 //		t.Parallel()
@@ -97,7 +126,7 @@ func TestDB(tb testing.TB, params ...url.Values) string {
 	tb.Cleanup(func() { Delete(name) })
 	Create(name, nil)
 
-	p := url.Values{"vfs": {"memdb"}}
+	p := url.Values{}
 	for _, v := range params {
 		for k, v := range v {
 			for _, v := range v {
@@ -105,6 +134,9 @@ func TestDB(tb testing.TB, params ...url.Values) string {
 			}
 		}
 	}
+	if !p.Has("vfs") {
+		p.Set("vfs", "memdb")
+	}
 
 	return (&url.URL{
 		Scheme:   "file",
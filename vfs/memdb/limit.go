@@ -0,0 +1,132 @@
+package memdb
+
+import (
+	"sync"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// Callback is called when a write would grow
+// the memory used by databases beyond a limit.
+// Size is the memory that would be used,
+// and limit is the current limit.
+// Callback returns the new limit;
+// if it's still less than the memory used, the write fails.
+//
+// Callback may free memory, e.g. by calling [Delete] to evict databases,
+// but must not use connections to memdb databases.
+type Callback func(size, limit int64) int64
+
+// usage tracks the memory used by databases.
+type usage struct {
+	size     int64    // +checklocks:usageMtx
+	limit    int64    // +checklocks:usageMtx
+	callback Callback // +checklocks:usageMtx
+}
+
+var (
+	usageMtx sync.Mutex
+	// +checklocks:usageMtx
+	total usage
+)
+
+// SetLimit sets the maximum memory used by the shared database name,
+// including its WAL file.
+// Writes that would exceed it fail with [sqlite3.FULL],
+// unless the callback grants more memory.
+//
+// A limit of zero or less disables the limit,
+// but memory usage continues to be tracked.
+// The limit applies until the database is deleted.
+// SetLimit reports whether the database exists.
+func SetLimit(name string, limit int64, callback Callback) bool {
+	memoryMtx.Lock()
+	db := memoryDBs[name]
+	memoryMtx.Unlock()
+	if db == nil {
+		return false
+	}
+
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	db.usage.limit = limit
+	db.usage.callback = callback
+	return true
+}
+
+// SetTotalLimit sets the maximum memory used by all memdb databases,
+// shared or not, including their WAL files.
+// Writes that would exceed it fail with [sqlite3.FULL],
+// unless the callback grants more memory.
+//
+// A limit of zero or less disables the limit.
+func SetTotalLimit(limit int64, callback Callback) {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	total.limit = limit
+	total.callback = callback
+}
+
+// Usage returns the memory used by the shared database name,
+// including its WAL file, and its limit.
+//
+// Memory is allocated in 64KiB sectors,
+// so usage is a multiple of that.
+func Usage(name string) (size, limit int64, ok bool) {
+	memoryMtx.Lock()
+	db := memoryDBs[name]
+	memoryMtx.Unlock()
+	if db == nil {
+		return 0, 0, false
+	}
+
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	return db.usage.size, db.usage.limit, true
+}
+
+// TotalUsage returns the memory used by all memdb databases,
+// shared or not, including their WAL files, and the total limit.
+func TotalUsage() (size, limit int64) {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	return total.size, total.limit
+}
+
+// reserve accounts for n more bytes of memory used by the database,
+// failing if that exceeds its limit, or the total limit.
+func (m *memDB) reserve(n int64) error {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+
+	for _, u := range [...]*usage{m.usage, &total} {
+		if !u.fits(n) && u.callback != nil {
+			// The callback may free memory,
+			// so call it without holding the lock.
+			callback, size, limit := u.callback, u.size+n, u.limit
+			usageMtx.Unlock()
+			limit = callback(size, limit)
+			usageMtx.Lock()
+			u.limit = limit
+		}
+		if !u.fits(n) {
+			return sqlite3.FULL
+		}
+	}
+	m.usage.size += n
+	total.size += n
+	return nil
+}
+
+// unreserve accounts for n fewer bytes of memory used by the database.
+func (m *memDB) unreserve(n int64) {
+	usageMtx.Lock()
+	defer usageMtx.Unlock()
+	m.usage.size -= n
+	total.size -= n
+}
+
+// +checklocks:usageMtx
+func (u *usage) fits(n int64) bool {
+	return u.limit <= 0 || u.size+n <= u.limit
+}
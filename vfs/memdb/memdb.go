@@ -16,9 +16,6 @@ const sectorSize = 65536
 type memVFS struct{}
 
 func (memVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
-	// For simplicity, we do not support reading or writing data
-	// across "sector" boundaries.
-	// This is not a problem for SQLite database files.
 	const databases = vfs.OPEN_MAIN_DB | vfs.OPEN_TEMP_DB | vfs.OPEN_TRANSIENT_DB
 
 	// Temp journals, as used by the sorter, use SliceFile.
@@ -26,6 +23,11 @@ func (memVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, err
 		return &vfsutil.SliceFile{}, flags | vfs.OPEN_MEMORY, nil
 	}
 
+	// WALs are kept with their shared database.
+	if flags&vfs.OPEN_WAL != 0 {
+		return openWAL(name, flags)
+	}
+
 	// Refuse to open all other file types.
 	// Returning OPEN_MEMORY means SQLite won't ask us to.
 	if flags&databases == 0 {
@@ -36,36 +38,96 @@ func (memVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, err
 	// A shared database has a name that begins with "/".
 	shared := strings.HasPrefix(name, "/")
 
+	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
+
 	var db *memDB
 	if shared {
 		name = name[1:]
-		memoryMtx.Lock()
-		defer memoryMtx.Unlock()
 		db = memoryDBs[name]
 	}
 	if db == nil {
 		if flags&vfs.OPEN_CREATE == 0 {
 			return nil, flags, sqlite3.CANTOPEN
 		}
-		db = &memDB{name: name}
+		db = &memDB{name: name, usage: &usage{}}
 	}
+
+	file := &memFile{
+		memDB:    db,
+		readOnly: flags&vfs.OPEN_READONLY != 0,
+	}
+	db.refs++
 	if shared {
-		db.refs++ // +checklocksforce: memoryMtx is held
 		memoryDBs[name] = db
+		// Only shared databases support WAL mode.
+		if flags&vfs.OPEN_MAIN_DB != 0 {
+			file.shm = db.walIndex.SharedMemory()
+		}
 	}
+	return file, flags | vfs.OPEN_MEMORY, nil
+}
+
+func openWAL(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
 
+	db := findWAL(name)
+	if db == nil {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+	if db.wal == nil {
+		if flags&vfs.OPEN_CREATE == 0 {
+			return nil, flags, sqlite3.CANTOPEN
+		}
+		db.wal = &memDB{name: db.name + "-wal", usage: db.usage, parent: db}
+	}
+	db.wal.refs++
 	return &memFile{
-		memDB:    db,
+		memDB:    db.wal,
 		readOnly: flags&vfs.OPEN_READONLY != 0,
-	}, flags | vfs.OPEN_MEMORY, nil
+	}, flags, nil
+}
+
+// findWAL returns the shared database a WAL belongs to.
+//
+// +checklocks:memoryMtx
+func findWAL(name string) *memDB {
+	name, ok := strings.CutPrefix(name, "/")
+	if !ok {
+		return nil
+	}
+	name, ok = strings.CutSuffix(name, "-wal")
+	if !ok {
+		return nil
+	}
+	return memoryDBs[name]
 }
 
 func (memVFS) Delete(name string, dirSync bool) error {
+	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
+
+	// Deleting a WAL discards it.
+	if db := findWAL(name); db != nil && db.wal != nil {
+		if db.wal.refs == 0 {
+			db.wal.free()
+		}
+		db.wal = nil
+		return nil
+	}
 	return sqlite3.IOERR_DELETE_NOENT // used to delete journals
 }
 
 func (memVFS) Access(name string, flag vfs.AccessFlag) (bool, error) {
-	return false, nil // used to check for journals
+	memoryMtx.Lock()
+	defer memoryMtx.Unlock()
+
+	// Check for WALs, and journals.
+	if db := findWAL(name); db != nil {
+		return db.wal != nil, nil
+	}
+	return false, nil
 }
 
 func (memVFS) FullPathname(name string) (string, error) {
@@ -73,7 +135,13 @@ func (memVFS) FullPathname(name string) (string, error) {
 }
 
 type memDB struct {
-	name string
+	name   string
+	usage  *usage // shared with the WAL
+	parent *memDB // the database a WAL belongs to
+
+	// +checklocks:memoryMtx
+	wal      *memDB
+	walIndex vfs.WALIndex
 
 	// +checklocks:lockMtx
 	waiter *sync.Cond
@@ -82,6 +150,7 @@ type memDB struct {
 
 	size     int64 // +checklocks:dataMtx
 	refs     int32 // +checklocks:memoryMtx
+	pinned   bool  // +checklocks:memoryMtx
 	shared   int32 // +checklocks:lockMtx
 	pending  bool  // +checklocks:lockMtx
 	reserved bool  // +checklocks:lockMtx
@@ -92,26 +161,63 @@ type memDB struct {
 
 func (m *memDB) release() {
 	memoryMtx.Lock()
-	if m.refs--; m.refs == 0 && m == memoryDBs[m.name] {
+	defer memoryMtx.Unlock()
+	m.unref()
+}
+
+// unref drops a reference to the database,
+// and frees it once it's no longer in use.
+//
+// +checklocks:memoryMtx
+func (m *memDB) unref() {
+	if m.refs--; m.refs > 0 {
+		return
+	}
+	if m.parent != nil {
+		// A WAL is kept until it's deleted.
+		if m.parent.wal != m {
+			m.free()
+		}
+		return
+	}
+	if m == memoryDBs[m.name] {
 		delete(memoryDBs, m.name)
 	}
-	memoryMtx.Unlock()
+	m.free()
+}
+
+// free releases the memory used by the database, and its WAL.
+//
+// +checklocks:memoryMtx
+func (m *memDB) free() {
+	m.dataMtx.Lock()
+	m.truncate(0)
+	m.dataMtx.Unlock()
+	if m.wal != nil {
+		m.wal.free()
+		m.wal = nil
+	}
 }
 
 type memFile struct {
 	*memDB
+	shm      vfs.SharedMemory
 	lock     vfs.LockLevel
 	readOnly bool
 }
 
 var (
 	// Ensure these interfaces are implemented:
-	_ vfs.FileLockState = &memFile{}
-	_ vfs.FileSizeHint  = &memFile{}
+	_ vfs.FileLockState    = &memFile{}
+	_ vfs.FileSizeHint     = &memFile{}
+	_ vfs.FileSharedMemory = &memFile{}
 )
 
 func (m *memFile) Close() error {
 	m.release()
+	if m.shm != nil {
+		m.shm.Close()
+	}
 	return m.Unlock(vfs.LOCK_NONE)
 }
 
@@ -119,20 +225,15 @@ func (m *memFile) ReadAt(b []byte, off int64) (n int, err error) {
 	m.dataMtx.RLock()
 	defer m.dataMtx.RUnlock()
 
-	if off >= m.size {
-		return 0, io.EOF
-	}
-
-	base := off / sectorSize
-	rest := off % sectorSize
-	have := int64(sectorSize)
-	if m.size < off+int64(len(b)) {
-		have = modRoundUp(m.size, sectorSize)
-	}
-	n = copy(b, (*m.data[base])[rest:have])
-	if n < len(b) {
-		// notest // assume reads are page aligned
-		return 0, io.ErrNoProgress
+	for n < len(b) {
+		pos := off + int64(n)
+		if pos >= m.size {
+			return n, io.EOF
+		}
+		base := pos / sectorSize
+		rest := pos % sectorSize
+		have := min(sectorSize, m.size-base*sectorSize)
+		n += copy(b[n:], (*m.data[base])[rest:have])
 	}
 	return n, nil
 }
@@ -141,19 +242,22 @@ func (m *memFile) WriteAt(b []byte, off int64) (n int, err error) {
 	m.dataMtx.Lock()
 	defer m.dataMtx.Unlock()
 
-	base := off / sectorSize
-	rest := off % sectorSize
-	for base >= int64(len(m.data)) {
-		m.data = append(m.data, new([sectorSize]byte))
+	sectors := divRoundUp(off+int64(len(b)), sectorSize)
+	if sectors > int64(len(m.data)) {
+		if err := m.resize(sectors); err != nil {
+			return 0, err
+		}
+	}
+
+	for n < len(b) {
+		pos := off + int64(n)
+		base := pos / sectorSize
+		rest := pos % sectorSize
+		n += copy((*m.data[base])[rest:], b[n:])
 	}
-	n = copy((*m.data[base])[rest:], b)
 	if size := off + int64(n); size > m.size {
 		m.size = size
 	}
-	if n < len(b) {
-		// notest // assume writes are page aligned
-		return n, io.ErrShortWrite
-	}
 	return n, nil
 }
 
@@ -179,7 +283,10 @@ func (m *memFile) SizeHint(size int64) error {
 }
 
 // +checklocks:m.dataMtx
-func (m *memFile) truncate(size int64) error {
+func (m *memDB) truncate(size int64) error {
+	if err := m.resize(divRoundUp(size, sectorSize)); err != nil {
+		return err
+	}
 	if size < m.size {
 		base := size / sectorSize
 		rest := size % sectorSize
@@ -187,16 +294,31 @@ func (m *memFile) truncate(size int64) error {
 			clear((*m.data[base])[rest:])
 		}
 	}
-	sectors := divRoundUp(size, sectorSize)
-	for sectors > int64(len(m.data)) {
-		m.data = append(m.data, new([sectorSize]byte))
-	}
-	clear(m.data[sectors:])
-	m.data = m.data[:sectors]
 	m.size = size
 	return nil
 }
 
+// resize grows or shrinks the database to a number of sectors,
+// accounting for the memory used.
+//
+// +checklocks:m.dataMtx
+func (m *memDB) resize(sectors int64) error {
+	switch n := (sectors - int64(len(m.data))) * sectorSize; {
+	case n > 0:
+		if err := m.reserve(n); err != nil {
+			return err
+		}
+		for sectors > int64(len(m.data)) {
+			m.data = append(m.data, new([sectorSize]byte))
+		}
+	case n < 0:
+		clear(m.data[sectors:])
+		m.data = m.data[:sectors]
+		m.unreserve(-n)
+	}
+	return nil
+}
+
 func (m *memFile) Lock(lock vfs.LockLevel) error {
 	if m.lock >= lock {
 		return nil
@@ -284,6 +406,10 @@ func (m *memFile) LockState() vfs.LockLevel {
 	return m.lock
 }
 
+func (m *memFile) SharedMemory() vfs.SharedMemory {
+	return m.shm
+}
+
 func (*memFile) Sync(flag vfs.SyncFlag) error { return nil }
 
 func (*memFile) SectorSize() int {
@@ -301,7 +427,3 @@ func (*memFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
 func divRoundUp(a, b int64) int64 {
 	return (a + b - 1) / b
 }
-
-func modRoundUp(a, b int64) int64 {
-	return b - (b-a%b)%b
-}
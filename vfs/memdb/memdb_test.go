@@ -2,6 +2,7 @@ package memdb
 
 import (
 	_ "embed"
+	"errors"
 	"testing"
 
 	"github.com/ncruces/go-sqlite3"
@@ -27,3 +28,142 @@ func Test_wal(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func Test_walMode(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+	dsn := TestDB(t)
+
+	writer, err := sqlite3.OpenContext(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	reader, err := sqlite3.OpenContext(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	stmt, _, err := writer.Prepare(`PRAGMA journal_mode=wal`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "wal" {
+		t.Errorf("got %q", got)
+	}
+	stmt.Close()
+
+	err = writer.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold a read transaction open.
+	count, _, err := reader.Prepare(`SELECT count(*) FROM test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer count.Close()
+
+	err = reader.Exec(`BEGIN`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !count.Step() {
+		t.Fatal(count.Err())
+	}
+	count.Reset()
+
+	// The writer is not blocked by the reader.
+	err = writer.Exec(`INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 100)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The reader sees its snapshot, then the new data.
+	check := func(want int) {
+		t.Helper()
+		defer count.Reset()
+		if !count.Step() {
+			t.Fatal(count.Err())
+		}
+		if got := count.ColumnInt(0); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	}
+	check(0)
+	err = reader.Exec(`COMMIT`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(100)
+
+	err = writer.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_limit(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+	const name = "limit.db"
+	Create(name, nil)
+
+	db, err := sqlite3.OpenContext(ctx, "file:/"+name+"?vfs=memdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	if !SetLimit(name, 4*sectorSize, func(size, limit int64) int64 {
+		calls++
+		return limit
+	}) {
+		t.Fatal("database not found")
+	}
+
+	err = db.Exec(`INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 1000)`)
+	if !errors.Is(err, sqlite3.FULL) {
+		t.Errorf("got %v, want sqlite3.FULL", err)
+	}
+	if calls == 0 {
+		t.Error("callback not called")
+	}
+
+	size, limit, ok := Usage(name)
+	if !ok || size > limit || limit != 4*sectorSize {
+		t.Errorf("got %d, %d, %v", size, limit, ok)
+	}
+	if total, _ := TotalUsage(); total < size {
+		t.Errorf("got total %d, want at least %d", total, size)
+	}
+
+	// Raising the limit allows the write.
+	SetLimit(name, 0, nil)
+	err = db.Exec(`INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 1000)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _, _ := Usage(name); size < 1_000_000 {
+		t.Errorf("got %d", size)
+	}
+
+	// Deleting the database releases its memory once closed.
+	Delete(name)
+	db.Close()
+	if _, _, ok := Usage(name); ok {
+		t.Error("database not deleted")
+	}
+}
@@ -0,0 +1,157 @@
+// Package metricsvfs wraps an SQLite VFS to collect I/O metrics.
+//
+// The [VFS] returned by [Wrap] counts calls, errors and bytes transferred,
+// and records latency histograms, for every VFS and file operation,
+// including shared-memory operations and file controls.
+// Metrics are aggregated per VFS and per file name.
+//
+// The wrapped VFS must be registered to be used:
+//
+//	metrics := metricsvfs.Wrap(vfs.Find(""), nil)
+//	vfs.Register("metrics", metrics)
+package metricsvfs
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// Op identifies an instrumented operation.
+type Op uint8
+
+const (
+	OpOpen Op = iota
+	OpDelete
+	OpAccess
+	OpClose
+	OpRead
+	OpWrite
+	OpTruncate
+	OpSync
+	OpSize
+	OpLock
+	OpUnlock
+	OpCheckReservedLock
+	OpSizeHint
+	OpHasMoved
+	OpOverwrite
+	OpSyncSuper
+	OpCommitPhaseTwo
+	OpBeginAtomicWrite
+	OpCommitAtomicWrite
+	OpRollbackAtomicWrite
+	OpCheckpoint
+	OpPragma
+	OpShmMap
+	OpShmLock
+	OpShmUnlock
+	OpShmUnmap
+	OpShmBarrier
+	numOps
+)
+
+var opNames = [numOps]string{
+	"open", "delete", "access", "close",
+	"read", "write", "truncate", "sync", "size",
+	"lock", "unlock", "check_reserved_lock",
+	"size_hint", "has_moved", "overwrite", "sync_super", "commit_phasetwo",
+	"begin_atomic_write", "commit_atomic_write", "rollback_atomic_write",
+	"checkpoint", "pragma",
+	"shm_map", "shm_lock", "shm_unlock", "shm_unmap", "shm_barrier",
+}
+
+// String implements [fmt.Stringer].
+func (op Op) String() string {
+	if op < numOps {
+		return opNames[op]
+	}
+	return "unknown"
+}
+
+// Histogram is a latency histogram with exponential buckets.
+// Bucket 0 counts operations that took less than 1µs;
+// bucket i counts operations that took at least 2^(i-1)µs,
+// and less than 2^iµs.
+// The last bucket also counts all slower operations.
+type Histogram [24]uint64
+
+func bucket(d time.Duration) int {
+	return min(bits.Len64(uint64(max(0, d.Microseconds()))), len(Histogram{})-1)
+}
+
+// Stats are the metrics of an operation.
+type Stats struct {
+	Calls   uint64        // number of calls
+	Errors  uint64        // number of calls that returned an error
+	Bytes   uint64        // bytes transferred by reads and writes
+	Time    time.Duration // total time spent
+	Latency Histogram
+}
+
+// Metrics are the metrics of all operations with at least one call.
+type Metrics map[Op]Stats
+
+// Event describes a completed operation.
+type Event struct {
+	Name    string // the file or VFS operation name
+	Op      Op
+	Bytes   int
+	Elapsed time.Duration
+	Err     error
+}
+
+// VFS is an instrumented VFS.
+type VFS struct {
+	vfs.VFS
+	observe func(Event)
+	total   counters
+
+	mtx sync.Mutex
+	// +checklocks:mtx
+	files map[string]*counters
+}
+
+// Wrap wraps a base VFS to collect I/O metrics.
+// If observe is not nil, it is called after every operation;
+// it must be safe to call observe concurrently.
+func Wrap(base vfs.VFS, observe func(Event)) *VFS {
+	return &VFS{
+		VFS:     base,
+		observe: observe,
+		files:   map[string]*counters{},
+	}
+}
+
+// Metrics returns a snapshot of the metrics of all files
+// opened through this VFS, and of VFS operations.
+func (v *VFS) Metrics() Metrics {
+	return v.total.snapshot()
+}
+
+// FileMetrics returns a snapshot of the metrics of each file
+// opened through this VFS, indexed by name.
+// Temporary files are indexed by the empty name.
+func (v *VFS) FileMetrics() map[string]Metrics {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	res := make(map[string]Metrics, len(v.files))
+	for name, c := range v.files {
+		res[name] = c.snapshot()
+	}
+	return res
+}
+
+// Reset resets all metrics.
+func (v *VFS) Reset() {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	v.total.reset()
+	for _, c := range v.files {
+		c.reset()
+	}
+}
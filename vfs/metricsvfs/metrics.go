@@ -0,0 +1,64 @@
+package metricsvfs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type counter struct {
+	calls   atomic.Uint64
+	errors  atomic.Uint64
+	bytes   atomic.Uint64
+	nanos   atomic.Int64
+	latency [len(Histogram{})]atomic.Uint64
+}
+
+type counters [numOps]counter
+
+func (c *counters) record(op Op, n int, elapsed time.Duration, err error) {
+	s := &c[op]
+	s.calls.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+	if n > 0 {
+		s.bytes.Add(uint64(n))
+	}
+	s.nanos.Add(int64(elapsed))
+	s.latency[bucket(elapsed)].Add(1)
+}
+
+func (c *counters) snapshot() Metrics {
+	res := Metrics{}
+	for op := range c {
+		s := &c[op]
+		calls := s.calls.Load()
+		if calls == 0 {
+			continue
+		}
+		stats := Stats{
+			Calls:  calls,
+			Errors: s.errors.Load(),
+			Bytes:  s.bytes.Load(),
+			Time:   time.Duration(s.nanos.Load()),
+		}
+		for i := range s.latency {
+			stats.Latency[i] = s.latency[i].Load()
+		}
+		res[Op(op)] = stats
+	}
+	return res
+}
+
+func (c *counters) reset() {
+	for op := range c {
+		s := &c[op]
+		s.calls.Store(0)
+		s.errors.Store(0)
+		s.bytes.Store(0)
+		s.nanos.Store(0)
+		for i := range s.latency {
+			s.latency[i].Store(0)
+		}
+	}
+}
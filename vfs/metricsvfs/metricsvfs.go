@@ -0,0 +1,281 @@
+package metricsvfs
+
+import (
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func (v *VFS) record(file *counters, name string, op Op, n int, elapsed time.Duration, err error) {
+	v.total.record(op, n, elapsed, err)
+	if file != nil {
+		file.record(op, n, elapsed, err)
+	}
+	if v.observe != nil {
+		v.observe(Event{Name: name, Op: op, Bytes: n, Elapsed: elapsed, Err: err})
+	}
+}
+
+func (v *VFS) counters(name string) *counters {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	c := v.files[name]
+	if c == nil {
+		c = new(counters)
+		v.files[name] = c
+	}
+	return c
+}
+
+func (v *VFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	start := time.Now()
+	file, flags, err := vfsutil.WrapOpen(v.VFS, name, flags)
+	return v.wrapFile(name, start, file, flags, err)
+}
+
+func (v *VFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	start := time.Now()
+	file, flags, err := vfsutil.WrapOpenFilename(v.VFS, name, flags)
+	return v.wrapFile(name.String(), start, file, flags, err)
+}
+
+func (v *VFS) wrapFile(name string, start time.Time, file vfs.File, flags vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	c := v.counters(name)
+	v.record(c, name, OpOpen, 0, time.Since(start), err)
+	if err != nil {
+		return file, flags, err
+	}
+	f := &metricsFile{File: file, vfs: v, name: name, counters: c}
+	f.shm = vfs.ObserveSharedMemory(vfsutil.WrapSharedMemory(file), f.observeShm)
+	return f, flags, nil
+}
+
+func (v *VFS) Delete(name string, syncDir bool) error {
+	start := time.Now()
+	err := v.VFS.Delete(name, syncDir)
+	v.record(nil, name, OpDelete, 0, time.Since(start), err)
+	return err
+}
+
+func (v *VFS) Access(name string, flags vfs.AccessFlag) (bool, error) {
+	start := time.Now()
+	ok, err := v.VFS.Access(name, flags)
+	v.record(nil, name, OpAccess, 0, time.Since(start), err)
+	return ok, err
+}
+
+type metricsFile struct {
+	vfs.File
+	vfs      *VFS
+	name     string
+	counters *counters
+	shm      vfs.SharedMemory
+	ckpt     time.Time
+}
+
+func (f *metricsFile) record(op Op, n int, elapsed time.Duration, err error) {
+	// Don't record file controls the base file does not implement.
+	if err == sqlite3.NOTFOUND && OpSizeHint <= op && op <= OpPragma {
+		return
+	}
+	f.vfs.record(f.counters, f.name, op, n, elapsed, err)
+}
+
+func (f *metricsFile) observeShm(op vfs.SharedMemoryOp, elapsed time.Duration, err error) {
+	var o Op
+	switch op.Name {
+	case "map":
+		o = OpShmMap
+	case "lock":
+		o = OpShmLock
+	case "unlock":
+		o = OpShmUnlock
+	case "unmap":
+		o = OpShmUnmap
+	case "barrier":
+		o = OpShmBarrier
+	default:
+		return
+	}
+	f.record(o, 0, elapsed, err)
+}
+
+func (f *metricsFile) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.record(OpClose, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) ReadAt(p []byte, off int64) (n int, err error) {
+	start := time.Now()
+	n, err = f.File.ReadAt(p, off)
+	f.record(OpRead, n, time.Since(start), err)
+	return n, err
+}
+
+func (f *metricsFile) WriteAt(p []byte, off int64) (n int, err error) {
+	start := time.Now()
+	n, err = f.File.WriteAt(p, off)
+	f.record(OpWrite, n, time.Since(start), err)
+	return n, err
+}
+
+func (f *metricsFile) Truncate(size int64) error {
+	start := time.Now()
+	err := f.File.Truncate(size)
+	f.record(OpTruncate, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) Sync(flags vfs.SyncFlag) error {
+	start := time.Now()
+	err := f.File.Sync(flags)
+	f.record(OpSync, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) Size() (int64, error) {
+	start := time.Now()
+	size, err := f.File.Size()
+	f.record(OpSize, 0, time.Since(start), err)
+	return size, err
+}
+
+func (f *metricsFile) Lock(lock vfs.LockLevel) error {
+	start := time.Now()
+	err := f.File.Lock(lock)
+	f.record(OpLock, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) Unlock(lock vfs.LockLevel) error {
+	start := time.Now()
+	err := f.File.Unlock(lock)
+	f.record(OpUnlock, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) CheckReservedLock() (bool, error) {
+	start := time.Now()
+	res, err := f.File.CheckReservedLock()
+	f.record(OpCheckReservedLock, 0, time.Since(start), err)
+	return res, err
+}
+
+func (f *metricsFile) SharedMemory() vfs.SharedMemory {
+	return f.shm
+}
+
+func (f *metricsFile) SizeHint(size int64) error {
+	start := time.Now()
+	err := vfsutil.WrapSizeHint(f.File, size)
+	f.record(OpSizeHint, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) HasMoved() (bool, error) {
+	start := time.Now()
+	moved, err := vfsutil.WrapHasMoved(f.File)
+	f.record(OpHasMoved, 0, time.Since(start), err)
+	return moved, err
+}
+
+func (f *metricsFile) Overwrite() error {
+	start := time.Now()
+	err := vfsutil.WrapOverwrite(f.File)
+	f.record(OpOverwrite, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) SyncSuper(super string) error {
+	start := time.Now()
+	err := vfsutil.WrapSyncSuper(f.File, super)
+	f.record(OpSyncSuper, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) CommitPhaseTwo() error {
+	start := time.Now()
+	err := vfsutil.WrapCommitPhaseTwo(f.File)
+	f.record(OpCommitPhaseTwo, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) BeginAtomicWrite() error {
+	start := time.Now()
+	err := vfsutil.WrapBeginAtomicWrite(f.File)
+	f.record(OpBeginAtomicWrite, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) CommitAtomicWrite() error {
+	start := time.Now()
+	err := vfsutil.WrapCommitAtomicWrite(f.File)
+	f.record(OpCommitAtomicWrite, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) RollbackAtomicWrite() error {
+	start := time.Now()
+	err := vfsutil.WrapRollbackAtomicWrite(f.File)
+	f.record(OpRollbackAtomicWrite, 0, time.Since(start), err)
+	return err
+}
+
+func (f *metricsFile) CheckpointStart() {
+	f.ckpt = time.Now()
+	vfsutil.WrapCheckpointStart(f.File)
+}
+
+func (f *metricsFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(f.File)
+	if !f.ckpt.IsZero() {
+		f.record(OpCheckpoint, 0, time.Since(f.ckpt), nil)
+		f.ckpt = time.Time{}
+	}
+}
+
+func (f *metricsFile) Pragma(name, value string) (string, error) {
+	start := time.Now()
+	res, err := vfsutil.WrapPragma(f.File, name, value)
+	f.record(OpPragma, 0, time.Since(start), err)
+	return res, err
+}
+
+// Wrap optional methods.
+
+func (f *metricsFile) Unwrap() vfs.File {
+	return f.File // notest
+}
+
+func (f *metricsFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(f.File)
+}
+
+func (f *metricsFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(f.File) // notest
+}
+
+func (f *metricsFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(f.File, keepWAL) // notest
+}
+
+func (f *metricsFile) PowersafeOverwrite() bool {
+	return vfsutil.WrapPowersafeOverwrite(f.File) // notest
+}
+
+func (f *metricsFile) SetPowersafeOverwrite(psow bool) {
+	vfsutil.WrapSetPowersafeOverwrite(f.File, psow) // notest
+}
+
+func (f *metricsFile) ChunkSize(size int) {
+	vfsutil.WrapChunkSize(f.File, size) // notest
+}
+
+func (f *metricsFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(f.File, handler) // notest
+}
@@ -0,0 +1,84 @@
+package metricsvfs_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+	"github.com/ncruces/go-sqlite3/vfs/metricsvfs"
+)
+
+func Test_metrics(t *testing.T) {
+	t.Parallel()
+
+	var events atomic.Int32
+	metrics := metricsvfs.Wrap(vfs.Find(""), func(metricsvfs.Event) {
+		events.Add(1)
+	})
+	vfs.Register("metrics", metrics)
+
+	tmp := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite3.OpenContext(testcfg.Context(t), "file:"+filepath.ToSlash(tmp)+"?vfs=metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`
+		PRAGMA journal_mode=wal;
+		CREATE TABLE test (col);
+		INSERT INTO test VALUES (randomblob(10000));
+		PRAGMA wal_checkpoint;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := metrics.Metrics()
+	if s := total[metricsvfs.OpWrite]; s.Calls == 0 || s.Bytes < 10000 {
+		t.Errorf("got %+v", s)
+	}
+	if s := total[metricsvfs.OpSync]; s.Calls == 0 {
+		t.Errorf("got %+v", s)
+	}
+	if s := total[metricsvfs.OpShmLock]; vfs.SupportsSharedMemory && s.Calls == 0 {
+		t.Errorf("got %+v", s)
+	}
+	if s := total[metricsvfs.OpCheckpoint]; s.Calls == 0 {
+		t.Errorf("got %+v", s)
+	}
+
+	var calls uint64
+	for _, n := range total[metricsvfs.OpRead].Latency {
+		calls += n
+	}
+	if calls != total[metricsvfs.OpRead].Calls {
+		t.Errorf("got %d, want %d", calls, total[metricsvfs.OpRead].Calls)
+	}
+
+	files := metrics.FileMetrics()
+	if _, ok := files[tmp]; !ok {
+		t.Errorf("missing %q in %v", tmp, files)
+	}
+	if _, ok := files[tmp+"-wal"]; !ok {
+		t.Errorf("missing %q in %v", tmp+"-wal", files)
+	}
+
+	if events.Load() == 0 {
+		t.Error("no events")
+	}
+
+	metrics.Reset()
+	if len(metrics.Metrics()) != 0 {
+		t.Error("not reset")
+	}
+}
+
+func TestOp_String(t *testing.T) {
+	if s := metricsvfs.OpShmBarrier.String(); s != "shm_barrier" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,47 @@
+// Package multiplex wraps an SQLite VFS to split files into chunks.
+//
+// The "multiplex" [vfs.VFS] wraps the default VFS,
+// and transparently splits databases, journals and WALs
+// into numbered chunk files no larger than a given size,
+// to work around file size limits.
+// The first chunk uses the original file name,
+// subsequent chunks append a three digit number to it
+// (e.g. "demo.db", "demo.db001", "demo.db002", …).
+//
+// Importing package multiplex registers the VFS:
+//
+//	import _ "github.com/ncruces/go-sqlite3/vfs/multiplex"
+//
+// The chunk size can be specified through the "chunksize" [URI] parameter.
+// It is rounded up to a multiple of 64KiB, the largest SQLite page size.
+// All connections to a database must use the same chunk size.
+//
+// This is similar to SQLite's [test_multiplex.c].
+//
+// [URI]: https://sqlite.org/uri.html
+// [test_multiplex.c]: https://sqlite.org/src/file/src/test_multiplex.c
+package multiplex
+
+import "github.com/ncruces/go-sqlite3/vfs"
+
+// DefaultChunkSize is the default chunk size,
+// which fits in a 32-bit file offset.
+const DefaultChunkSize = 2147418112
+
+func init() {
+	vfs.Register("multiplex", Wrap(vfs.Find(""), 0))
+}
+
+// Wrap wraps a base VFS to create a multiplexing VFS.
+// The base VFS must support opening files by name.
+//
+// If chunkSize is zero or less, [DefaultChunkSize] is used.
+func Wrap(base vfs.VFS, chunkSize int64) vfs.VFS {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &multiplexVFS{
+		VFS:  base,
+		size: roundUp(chunkSize),
+	}
+}
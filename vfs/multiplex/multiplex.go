@@ -0,0 +1,352 @@
+package multiplex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// SQLite pages are at most 64KiB.
+const maxPageSize = 65536
+
+func roundUp(i int64) int64 {
+	return (i + (maxPageSize - 1)) &^ (maxPageSize - 1)
+}
+
+type multiplexVFS struct {
+	vfs.VFS
+	size int64
+}
+
+func (m *multiplexVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpen(m.VFS, name, flags)
+	return m.wrapFile(name, m.size, file, flags, err)
+}
+
+func (m *multiplexVFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpenFilename(m.VFS, name, flags)
+	if err != nil {
+		return file, flags, err
+	}
+
+	// Journals and WALs use the chunk size of their database.
+	size := m.size
+	if f, ok := vfsutil.UnwrapFile[*multiplexFile](name.DatabaseFile()); ok {
+		size = f.size
+	} else if s := name.URIParameter("chunksize"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			file.Close()
+			return nil, flags, sqlite3.CANTOPEN
+		}
+		size = roundUp(n)
+	}
+	return m.wrapFile(name.String(), size, file, flags, nil)
+}
+
+func (m *multiplexVFS) wrapFile(name string, size int64, file vfs.File, flags vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	// Temporary and memory files are not split.
+	if err != nil || name == "" || flags&vfs.OPEN_MEMORY != 0 {
+		return file, flags, err
+	}
+	return &multiplexFile{
+		File:   file,
+		base:   m.VFS,
+		name:   name,
+		flags:  flags,
+		size:   size,
+		chunks: []vfs.File{file},
+	}, flags, nil
+}
+
+func (m *multiplexVFS) Delete(name string, syncDir bool) error {
+	err := m.VFS.Delete(name, syncDir)
+	if err != nil {
+		return err
+	}
+	return deleteChunks(m.VFS, name, 1, syncDir)
+}
+
+// deleteChunks deletes chunks starting at first,
+// until one doesn't exist.
+func deleteChunks(base vfs.VFS, name string, first int, syncDir bool) error {
+	for i := first; ; i++ {
+		chunk := chunkName(name, i)
+		ok, err := base.Access(chunk, vfs.ACCESS_EXISTS)
+		if err != nil || !ok {
+			return err
+		}
+		err = base.Delete(chunk, syncDir)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s%03d", name, i)
+}
+
+type multiplexFile struct {
+	vfs.File // the first chunk
+	base     vfs.VFS
+	name     string
+	flags    vfs.OpenFlag
+	size     int64
+	chunks   []vfs.File
+}
+
+// chunk returns the i-th chunk, opening, and possibly creating it.
+// It returns nil if the chunk does not exist, and create is false.
+func (m *multiplexFile) chunk(i int, create bool) (vfs.File, error) {
+	for len(m.chunks) <= i {
+		m.chunks = append(m.chunks, nil)
+	}
+	if f := m.chunks[i]; f != nil {
+		return f, nil
+	}
+
+	name := chunkName(m.name, i)
+	flags := m.flags &^ (vfs.OPEN_EXCLUSIVE | vfs.OPEN_DELETEONCLOSE)
+	if create && flags&vfs.OPEN_READONLY == 0 {
+		flags |= vfs.OPEN_CREATE
+	} else {
+		ok, err := m.base.Access(name, vfs.ACCESS_EXISTS)
+		if err != nil || !ok {
+			return nil, err
+		}
+		flags &^= vfs.OPEN_CREATE
+	}
+
+	f, _, err := m.base.Open(name, flags)
+	if err != nil {
+		return nil, err
+	}
+	m.chunks[i] = f
+	return f, nil
+}
+
+// extend returns the i-th chunk, creating it if needed.
+// Before a chunk is created, all chunks before it are created,
+// and grown to the chunk size, so the file has no gaps.
+func (m *multiplexFile) extend(i int) (vfs.File, error) {
+	f, err := m.chunk(i, false)
+	if f != nil || err != nil {
+		return f, err
+	}
+	for j := range i {
+		f, err := m.chunk(j, true)
+		if err != nil {
+			return nil, err
+		}
+		size, err := f.Size()
+		if err != nil {
+			return nil, err
+		}
+		if size < m.size {
+			err = f.Truncate(m.size)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.chunk(i, true)
+}
+
+// count returns the number of existing chunks.
+// Open chunks are known to exist,
+// so only those past the last open chunk are checked.
+func (m *multiplexFile) count() (int, error) {
+	n := len(m.chunks)
+	for n > 1 && m.chunks[n-1] == nil {
+		n--
+	}
+	for i := max(1, n); ; i++ {
+		ok, err := m.base.Access(chunkName(m.name, i), vfs.ACCESS_EXISTS)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return i, nil
+		}
+	}
+}
+
+func (m *multiplexFile) Close() error {
+	var err error
+	for _, f := range m.chunks {
+		if f == nil {
+			continue
+		}
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	m.chunks = nil
+	return err
+}
+
+func (m *multiplexFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		pos := off + int64(n)
+		i := int(pos / m.size)
+		rest := pos % m.size
+
+		f, err := m.chunk(i, false)
+		if err != nil {
+			return n, err
+		}
+		if f == nil {
+			return n, io.EOF
+		}
+
+		end := min(int64(len(p)), int64(n)+m.size-rest)
+		k, err := f.ReadAt(p[n:end], rest)
+		n += k
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (m *multiplexFile) WriteAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		pos := off + int64(n)
+		i := int(pos / m.size)
+		rest := pos % m.size
+
+		f, err := m.extend(i)
+		if err != nil {
+			return n, err
+		}
+
+		end := min(int64(len(p)), int64(n)+m.size-rest)
+		k, err := f.WriteAt(p[n:end], rest)
+		n += k
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (m *multiplexFile) Size() (int64, error) {
+	c, err := m.count()
+	if err != nil {
+		return 0, err
+	}
+	f, err := m.chunk(c-1, false)
+	if err != nil {
+		return 0, err
+	}
+	if f == nil {
+		// notest // deleted concurrently
+		return 0, sqlite3.IOERR_FSTAT
+	}
+	size, err := f.Size()
+	if err != nil {
+		return 0, err
+	}
+	return int64(c-1)*m.size + size, nil
+}
+
+func (m *multiplexFile) Truncate(size int64) error {
+	last := 0
+	if size > 0 {
+		last = int((size - 1) / m.size)
+	}
+
+	// Close and delete all chunks past the last.
+	for i := last + 1; i < len(m.chunks); i++ {
+		if f := m.chunks[i]; f != nil {
+			f.Close()
+		}
+	}
+	m.chunks = m.chunks[:min(last+1, len(m.chunks))]
+	if err := deleteChunks(m.base, m.name, last+1, false); err != nil {
+		return err
+	}
+
+	f, err := m.extend(last)
+	if err != nil {
+		return err
+	}
+	return f.Truncate(size - int64(last)*m.size)
+}
+
+func (m *multiplexFile) Sync(flags vfs.SyncFlag) error {
+	// Sync the first chunk last,
+	// as it may sync the directory.
+	for i := len(m.chunks) - 1; i >= 0; i-- {
+		if f := m.chunks[i]; f != nil {
+			if err := f.Sync(flags); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *multiplexFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return m.File.DeviceCharacteristics() & (0 |
+		// These flags are safe:
+		vfs.IOCAP_IMMUTABLE |
+		vfs.IOCAP_SEQUENTIAL |
+		vfs.IOCAP_SUBPAGE_READ |
+		vfs.IOCAP_POWERSAFE_OVERWRITE |
+		vfs.IOCAP_UNDELETABLE_WHEN_OPEN)
+}
+
+func (m *multiplexFile) SizeHint(size int64) error {
+	return nil // notest
+}
+
+// Wrap optional methods.
+
+func (m *multiplexFile) Unwrap() vfs.File {
+	return m.File // notest
+}
+
+func (m *multiplexFile) SharedMemory() vfs.SharedMemory {
+	return vfsutil.WrapSharedMemory(m.File)
+}
+
+func (m *multiplexFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(m.File) // notest
+}
+
+func (m *multiplexFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(m.File) // notest
+}
+
+func (m *multiplexFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(m.File, keepWAL) // notest
+}
+
+func (m *multiplexFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(m.File) // notest
+}
+
+func (m *multiplexFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(m.File) // notest
+}
+
+func (m *multiplexFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(m.File) // notest
+}
+
+func (m *multiplexFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(m.File, handler) // notest
+}
+
+func (m *multiplexFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(m.File, name, value) // notest
+}
@@ -0,0 +1,151 @@
+package multiplex_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+	"github.com/ncruces/go-sqlite3/vfs/multiplex"
+)
+
+func Test_multiplex(t *testing.T) {
+	t.Parallel()
+
+	for _, mode := range []string{"delete", "wal"} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			tmp := filepath.Join(t.TempDir(), "test.db")
+			db, err := sqlite3.OpenContext(testcfg.Context(t),
+				"file:"+filepath.ToSlash(tmp)+"?vfs=multiplex&chunksize=65536")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			err = db.Exec(`PRAGMA journal_mode=` + mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = db.Exec(`CREATE TABLE test (col)`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = db.Exec(`INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 500)`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Chunks are no larger than the chunk size.
+			for _, name := range []string{tmp, tmp + "001", tmp + "002"} {
+				fi, err := os.Stat(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Size() > 65536 {
+					t.Errorf("%s: got %d", name, fi.Size())
+				}
+			}
+
+			checkIntegrity(t, db)
+
+			err = db.Exec(`DELETE FROM test`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.Exec(`VACUUM`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Truncation deletes trailing chunks.
+			if _, err := os.Stat(tmp + "001"); !os.IsNotExist(err) {
+				t.Errorf("got %v", err)
+			}
+
+			checkIntegrity(t, db)
+		})
+	}
+}
+
+func checkIntegrity(t *testing.T, db *sqlite3.Conn) {
+	t.Helper()
+
+	stmt, _, err := db.Prepare(`PRAGMA integrity_check`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "ok" {
+		t.Error(got)
+	}
+}
+
+func Test_multiplex_gaps(t *testing.T) {
+	t.Parallel()
+
+	const size = 65536
+	name := filepath.Join(t.TempDir(), "test.db")
+	mvfs := multiplex.Wrap(vfs.Find(""), size)
+
+	f, _, err := mvfs.Open(name, vfs.OPEN_MAIN_DB|vfs.OPEN_CREATE|vfs.OPEN_READWRITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Write past two empty chunks.
+	_, err = f.WriteAt([]byte("hello"), 2*size+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Size(); err != nil {
+		t.Fatal(err)
+	} else if got != 2*size+15 {
+		t.Errorf("got %d", got)
+	}
+
+	buf := make([]byte, 5)
+	_, err = f.ReadAt(buf, 2*size+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("got %q", buf)
+	}
+
+	// Grow past two more chunks.
+	err = f.Truncate(5*size - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Size(); err != nil {
+		t.Fatal(err)
+	} else if got != 5*size-1 {
+		t.Errorf("got %d", got)
+	}
+
+	for _, name := range []string{name + "001", name + "002", name + "003", name + "004"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Error(err)
+		}
+	}
+}
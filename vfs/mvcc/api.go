@@ -7,6 +7,11 @@
 // Importing package mvcc registers the VFS:
 //
 //	import _ "github.com/ncruces/go-sqlite3/vfs/mvcc"
+//
+// Shared databases can keep past versions (see [SetRetention]),
+// which can be opened read-only with the "commit" or "asof" [URI] parameters.
+//
+// [URI]: https://sqlite.org/uri.html
 package mvcc
 
 import (
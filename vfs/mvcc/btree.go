@@ -0,0 +1,581 @@
+package mvcc
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/ncruces/wbt"
+)
+
+// btree reads SQLite b-trees from a snapshot.
+//
+// https://sqlite.org/fileformat.html#b_tree_pages
+type btree struct {
+	data     *wbt.Tree[int64, string]
+	pageSize int
+	usable   int
+	pages    uint32
+}
+
+const maxDepth = 20 // BTCURSOR_MAX_DEPTH
+
+func newBtree(s Snapshot) (*btree, error) {
+	pageSize, err := s.pageSize()
+	if err != nil || pageSize == 0 {
+		return &btree{}, err
+	}
+
+	var hdr [100]byte
+	if _, err := readAt(s.Tree, hdr[:], 0); err != nil {
+		return nil, ErrCorrupt
+	}
+	usable := pageSize - int(hdr[20])
+	if usable < 480 {
+		return nil, ErrCorrupt
+	}
+	return &btree{
+		data:     s.Tree,
+		pageSize: pageSize,
+		usable:   usable,
+		pages:    uint32(min(s.size()/int64(pageSize), math.MaxUint32)),
+	}, nil
+}
+
+// pageSize returns the page size of the database in the snapshot,
+// or zero if it is empty.
+func (s Snapshot) pageSize() (int, error) {
+	if s.size() == 0 {
+		return 0, nil
+	}
+
+	var hdr [100]byte
+	if _, err := readAt(s.Tree, hdr[:], 0); err != nil ||
+		string(hdr[:16]) != "SQLite format 3\x00" {
+		return 0, ErrCorrupt
+	}
+	size := int(binary.BigEndian.Uint16(hdr[16:]))
+	if size == 1 {
+		size = 65536
+	}
+	if size < 512 || size&(size-1) != 0 {
+		return 0, ErrCorrupt
+	}
+	return size, nil
+}
+
+// readPage reads page number pgno,
+// returning nil if it is past the end of the database.
+func readPage(data *wbt.Tree[int64, string], pgno uint32, pageSize int) []byte {
+	buf := make([]byte, pageSize)
+	n, _ := readAt(data, buf, int64(pgno-1)*int64(pageSize))
+	if n == 0 {
+		return nil
+	}
+	return buf
+}
+
+func (b *btree) page(pgno uint32) ([]byte, error) {
+	if pgno < 1 || pgno > b.pages {
+		return nil, ErrCorrupt
+	}
+	if page := readPage(b.data, pgno, b.pageSize); page != nil {
+		return page, nil
+	}
+	return make([]byte, b.pageSize), nil
+}
+
+// schema returns the root pages of all tables,
+// and all indexes, in the schema, by name.
+func (b *btree) schema() (tables, indexes map[string]uint32, err error) {
+	tables = map[string]uint32{}
+	indexes = map[string]uint32{}
+	if b.pages == 0 {
+		return tables, indexes, nil
+	}
+	err = b.walk(1, 0, func(_ int64, payload string) error {
+		rec, err := record(payload)
+		if err != nil {
+			return err
+		}
+		if len(rec) < 4 {
+			return ErrCorrupt
+		}
+		typ, _ := rec[0].(string)
+		name, _ := rec[1].(string)
+		root, _ := rec[3].(int64)
+		if root > 0 {
+			switch typ {
+			case "table":
+				tables[name] = uint32(root)
+			case "index":
+				indexes[name] = uint32(root)
+			}
+		}
+		return nil
+	})
+	return tables, indexes, err
+}
+
+// rows returns the records of the table rooted at page root, keyed by rowid.
+// For WITHOUT ROWID tables, records are keyed by their position.
+func (b *btree) rows(root uint32) (rows map[int64]string, rowid bool, err error) {
+	rows = map[int64]string{}
+	if root == 0 || b.pages == 0 {
+		return rows, true, nil
+	}
+
+	n, err := b.node(root)
+	if err != nil {
+		return nil, false, err
+	}
+	rowid = n.table
+
+	var pos int64
+	err = b.walk(root, 0, func(id int64, payload string) error {
+		if !rowid {
+			id = pos
+			pos++
+		}
+		rows[id] = payload
+		return nil
+	})
+	return rows, rowid, err
+}
+
+// walk calls fn for each cell of the b-tree rooted at page pgno,
+// with the rowid (for table b-trees) and the payload of the cell.
+func (b *btree) walk(pgno uint32, depth int, fn func(rowid int64, payload string) error) error {
+	if depth > maxDepth {
+		return ErrCorrupt
+	}
+	n, err := b.node(pgno)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range n.cells {
+		if !n.leaf {
+			err := b.walk(c.child, depth+1, fn)
+			if err != nil {
+				return err
+			}
+		}
+		if !c.payload {
+			continue
+		}
+		payload, err := b.payload(c, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(c.rowid, payload); err != nil {
+			return err
+		}
+	}
+
+	if !n.leaf {
+		return b.walk(n.right, depth+1, fn)
+	}
+	return nil
+}
+
+// find returns the payload of the row with the given rowid,
+// in the table b-tree rooted at page root.
+func (b *btree) find(root uint32, rowid int64) (payload string, ok bool, err error) {
+	pgno := root
+	for range maxDepth + 1 {
+		n, err := b.node(pgno)
+		if err != nil {
+			return "", false, err
+		}
+		if !n.table {
+			return "", false, ErrCorrupt
+		}
+
+		// Interior cells are keyed by the largest rowid of their left child.
+		i, found := slices.BinarySearchFunc(n.cells, rowid, func(c cell, id int64) int {
+			return cmp.Compare(c.rowid, id)
+		})
+		if n.leaf {
+			if !found {
+				return "", false, nil
+			}
+			payload, err := b.payload(n.cells[i], nil)
+			return payload, err == nil, err
+		}
+		if i < len(n.cells) {
+			pgno = n.cells[i].child
+		} else {
+			pgno = n.right
+		}
+	}
+	return "", false, ErrCorrupt
+}
+
+// node is a decoded b-tree page.
+type node struct {
+	cells []cell
+	right uint32 // the right-most child, for interior pages
+	leaf  bool
+	table bool
+}
+
+// cell is a b-tree cell.
+type cell struct {
+	child   uint32 // the left child, for interior pages
+	rowid   int64  // the rowid, for table b-trees
+	local   []byte // the local part of the payload
+	size    uint64 // the size of the payload
+	payload bool   // leaf table, and index, cells have a payload
+	table   bool
+}
+
+// node decodes the b-tree page pgno.
+//
+// https://sqlite.org/fileformat.html#b_tree_pages
+func (b *btree) node(pgno uint32) (*node, error) {
+	page, err := b.page(pgno)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := 0
+	if pgno == 1 {
+		hdr = 100
+	}
+	var n node
+	switch page[hdr] {
+	case 0x02: // interior index
+	case 0x05: // interior table
+		n.table = true
+	case 0x0a: // leaf index
+		n.leaf = true
+	case 0x0d: // leaf table
+		n.leaf, n.table = true, true
+	default:
+		return nil, ErrCorrupt
+	}
+
+	ptrs := hdr + 8
+	if !n.leaf {
+		n.right = binary.BigEndian.Uint32(page[hdr+8:])
+		ptrs += 4
+	}
+	cells := int(binary.BigEndian.Uint16(page[hdr+3:]))
+	if ptrs+2*cells > b.usable {
+		return nil, ErrCorrupt
+	}
+
+	n.cells = make([]cell, cells)
+	for i := range n.cells {
+		off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+		if off < ptrs || off >= b.usable {
+			return nil, ErrCorrupt
+		}
+		buf := page[off:b.usable]
+		c := &n.cells[i]
+		c.table = n.table
+
+		if !n.leaf {
+			if len(buf) < 4 {
+				return nil, ErrCorrupt
+			}
+			c.child = binary.BigEndian.Uint32(buf)
+			buf = buf[4:]
+		}
+
+		if !n.leaf && n.table {
+			rowid, k := varint(buf)
+			if k == 0 {
+				return nil, ErrCorrupt
+			}
+			c.rowid = int64(rowid)
+			continue
+		}
+
+		size, k := varint(buf)
+		if k == 0 {
+			return nil, ErrCorrupt
+		}
+		buf = buf[k:]
+
+		if n.table {
+			rowid, k := varint(buf)
+			if k == 0 {
+				return nil, ErrCorrupt
+			}
+			c.rowid = int64(rowid)
+			buf = buf[k:]
+		}
+		c.local, c.size, c.payload = buf, size, true
+	}
+	return &n, nil
+}
+
+// payload reads the payload of a cell, following overflow pages,
+// and calling overflow, if not nil, with the number of each one.
+//
+// https://sqlite.org/fileformat.html#cellformat
+func (b *btree) payload(c cell, overflow func(pgno uint32)) (string, error) {
+	if c.size > uint64(b.pages)*uint64(b.pageSize) {
+		return "", ErrCorrupt
+	}
+
+	u := int64(b.usable)
+	p := int64(c.size)
+	x := (u-12)*64/255 - 23
+	if c.table {
+		x = u - 35
+	}
+	local := p
+	if p > x {
+		m := (u-12)*32/255 - 23
+		local = m + (p-m)%(u-4)
+		if local > x {
+			local = m
+		}
+	}
+
+	if int64(len(c.local)) < local {
+		return "", ErrCorrupt
+	}
+	if local == p {
+		return string(c.local[:local]), nil
+	}
+	if int64(len(c.local)) < local+4 {
+		return "", ErrCorrupt
+	}
+
+	var buf strings.Builder
+	buf.Grow(int(p))
+	buf.Write(c.local[:local])
+	next := binary.BigEndian.Uint32(c.local[local:])
+	for i := uint32(0); int64(buf.Len()) < p; i++ {
+		if i >= b.pages {
+			return "", ErrCorrupt
+		}
+		page, err := b.page(next)
+		if err != nil {
+			return "", err
+		}
+		if overflow != nil {
+			overflow(next)
+		}
+		next = binary.BigEndian.Uint32(page)
+		n := min(p-int64(buf.Len()), u-4)
+		buf.Write(page[4 : 4+n])
+	}
+	return buf.String(), nil
+}
+
+// Owners of pages that don't belong to a b-tree.
+const (
+	ownerNone = iota
+	ownerFree // the freelist
+	ownerMeta // the lock-byte page and pointer map pages
+	numOwners
+)
+
+// layout maps the pages of a snapshot to the b-trees they belong to.
+// B-trees are identified by the ids of their names,
+// which are shared by the layouts of all snapshots being compared.
+type layout struct {
+	owner []uint32            // the owner of each page, by page number
+	pages map[uint32][]uint32 // the pages of each b-tree, by id
+	roots map[uint32]uint32   // the root page of each b-tree, by id
+}
+
+// layout reads the structure of the b-trees in roots (keyed by id),
+// and of the freelist, without reading leaf pages.
+func (b *btree) layout(roots map[uint32]uint32) (*layout, error) {
+	l := layout{
+		owner: make([]uint32, b.pages+1),
+		pages: map[uint32][]uint32{},
+		roots: roots,
+	}
+	if b.pages == 0 {
+		return &l, nil
+	}
+
+	for id, root := range roots {
+		err := b.tree(root, func(pgno uint32) error {
+			if pgno < 1 || pgno > b.pages || l.owner[pgno] != ownerNone {
+				return ErrCorrupt
+			}
+			l.owner[pgno] = id
+			l.pages[id] = append(l.pages[id], pgno)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var hdr [100]byte
+	if _, err := readAt(b.data, hdr[:], 0); err != nil {
+		return nil, ErrCorrupt
+	}
+
+	// Freelist trunk pages list freelist leaf pages.
+	// https://sqlite.org/fileformat.html#the_freelist
+	trunk := binary.BigEndian.Uint32(hdr[32:])
+	for i := uint32(0); trunk != 0; i++ {
+		page, err := b.page(trunk)
+		if err != nil || i >= b.pages {
+			return nil, ErrCorrupt
+		}
+		n := binary.BigEndian.Uint32(page[4:])
+		if n > uint32(b.usable/4-2) {
+			return nil, ErrCorrupt
+		}
+		l.owner[trunk] = ownerFree
+		for j := range n {
+			pgno := binary.BigEndian.Uint32(page[8+4*j:])
+			if pgno > b.pages {
+				return nil, ErrCorrupt
+			}
+			l.owner[pgno] = ownerFree
+		}
+		trunk = binary.BigEndian.Uint32(page)
+	}
+
+	// https://sqlite.org/fileformat.html#pointer_map_or_ptrmap_pages
+	if lock := uint32(0x40000000/b.pageSize + 1); lock <= b.pages {
+		l.owner[lock] = ownerMeta
+	}
+	if binary.BigEndian.Uint32(hdr[52:]) != 0 {
+		step := uint32(b.usable/5 + 1)
+		for pgno := uint32(2); pgno <= b.pages; pgno += step {
+			l.owner[pgno] = ownerMeta
+		}
+	}
+	return &l, nil
+}
+
+// tree calls fn for each page of the b-tree rooted at page root,
+// excluding overflow pages.
+// Only interior pages are read: all leaves of a b-tree have the same depth.
+func (b *btree) tree(root uint32, fn func(pgno uint32) error) error {
+	level := []uint32{root}
+	for range maxDepth + 1 {
+		n, err := b.node(level[0])
+		if err != nil {
+			return err
+		}
+		if n.leaf {
+			for _, pgno := range level {
+				if err := fn(pgno); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var next []uint32
+		for i, pgno := range level {
+			if i > 0 {
+				n, err = b.node(pgno)
+				if err != nil {
+					return err
+				}
+				if n.leaf {
+					return ErrCorrupt
+				}
+			}
+			if err := fn(pgno); err != nil {
+				return err
+			}
+			for _, c := range n.cells {
+				next = append(next, c.child)
+			}
+			next = append(next, n.right)
+		}
+		level = next
+	}
+	return ErrCorrupt
+}
+
+// varint decodes an SQLite variable-length integer,
+// returning the number of bytes read, or zero on error.
+func varint(b []byte) (v uint64, n int) {
+	for i := 0; i < 8 && i < len(b); i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	if len(b) < 9 {
+		return 0, 0
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// record decodes an SQLite record.
+//
+// https://sqlite.org/fileformat.html#record_format
+func record(payload string) ([]any, error) {
+	hdrSize, n := varint([]byte(payload[:min(9, len(payload))]))
+	if n == 0 || hdrSize < uint64(n) || hdrSize > uint64(len(payload)) {
+		return nil, ErrCorrupt
+	}
+	hdr := []byte(payload[n:hdrSize])
+	body := payload[hdrSize:]
+
+	var res []any
+	for len(hdr) > 0 {
+		typ, n := varint(hdr)
+		if n == 0 {
+			return nil, ErrCorrupt
+		}
+		hdr = hdr[n:]
+
+		var size uint64
+		switch {
+		case typ <= 4:
+			size = typ
+		case typ == 5:
+			size = 6
+		case typ <= 7:
+			size = 8
+		case typ <= 9:
+			size = 0
+		case typ >= 12:
+			size = (typ - 12) / 2
+		default:
+			return nil, ErrCorrupt
+		}
+		if size > uint64(len(body)) {
+			return nil, ErrCorrupt
+		}
+		val := body[:size]
+		body = body[size:]
+
+		switch {
+		case typ == 0:
+			res = append(res, nil)
+		case typ == 7:
+			res = append(res, math.Float64frombits(binary.BigEndian.Uint64([]byte(val))))
+		case typ == 8:
+			res = append(res, int64(0))
+		case typ == 9:
+			res = append(res, int64(1))
+		case typ <= 6:
+			// Sign extend big-endian integers.
+			var i int64
+			if len(val) > 0 && val[0] >= 0x80 {
+				i = -1
+			}
+			for _, c := range []byte(val) {
+				i = i<<8 | int64(c)
+			}
+			res = append(res, i)
+		case typ%2 == 1:
+			res = append(res, val)
+		default:
+			res = append(res, []byte(val))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,156 @@
+package mvcc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ncruces/wbt"
+)
+
+// Version is a committed version of a database.
+type Version struct {
+	ID   uint64    // commit id, increasing with each commit
+	Time time.Time // commit time
+	Snapshot
+}
+
+// Retention is a policy for keeping past versions of a database.
+//
+// A past version is kept if it is one of the last Commits versions,
+// or if it was superseded less than Window ago.
+// The zero value keeps no past versions.
+type Retention struct {
+	Commits int
+	Window  time.Duration
+}
+
+// SetRetention sets the retention policy of a shared database,
+// which starts keeping past versions from its current version onward.
+//
+// Past versions can be opened read-only, using the "commit" URI parameter
+// to specify a commit id, or the "asof" URI parameter
+// to specify a timestamp in RFC 3339 format:
+//
+//	file:/test.db?vfs=mvcc&commit=42
+//	file:/test.db?vfs=mvcc&asof=2006-01-02T15:04:05Z
+func SetRetention(name string, policy Retention) {
+	name = getName(name)
+
+	memoryMtx.Lock()
+	db := memoryDBs[name]
+	memoryMtx.Unlock()
+
+	if db == nil {
+		return
+	}
+
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.retain = policy
+	if policy == (Retention{}) {
+		db.history = nil
+		return
+	}
+	if len(db.history) == 0 {
+		db.history = []Version{{
+			ID:       db.commits,
+			Time:     time.Now(),
+			Snapshot: Snapshot{db.data},
+		}}
+	}
+	db.prune(time.Now())
+}
+
+// History returns the retained versions of a shared database,
+// oldest first, and ending with the current version.
+// It returns nil if the database does not keep past versions.
+func History(name string) []Version {
+	name = getName(name)
+
+	memoryMtx.Lock()
+	db := memoryDBs[name]
+	memoryMtx.Unlock()
+
+	if db == nil {
+		return nil
+	}
+
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	db.prune(time.Now())
+	return append([]Version(nil), db.history...)
+}
+
+// commit makes data the current version of the database.
+//
+// +checklocks:m.mtx
+func (m *mvccDB) commit(data *wbt.Tree[int64, string]) {
+	if m.data == data {
+		return
+	}
+	m.data = data
+	m.commits++
+	if m.history == nil {
+		return
+	}
+
+	now := time.Now()
+	m.history = append(m.history, Version{
+		ID:       m.commits,
+		Time:     now,
+		Snapshot: Snapshot{data},
+	})
+	m.prune(now)
+}
+
+// prune discards past versions according to the retention policy.
+//
+// +checklocks:m.mtx
+func (m *mvccDB) prune(now time.Time) {
+	var drop int
+	for drop < len(m.history)-1 {
+		past := len(m.history) - 1 - drop
+		if past <= m.retain.Commits ||
+			now.Sub(m.history[drop+1].Time) < m.retain.Window {
+			break
+		}
+		drop++
+	}
+	if drop > 0 {
+		n := copy(m.history, m.history[drop:])
+		clear(m.history[n:])
+		m.history = m.history[:n]
+	}
+}
+
+// version finds a retained version by commit id or timestamp.
+//
+// +checklocks:m.mtx
+func (m *mvccDB) version(commit, asof string) (*wbt.Tree[int64, string], bool) {
+	m.prune(time.Now())
+
+	if commit != "" {
+		id, err := strconv.ParseUint(commit, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		for _, v := range m.history {
+			if v.ID == id {
+				return v.Tree, true
+			}
+		}
+		return nil, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, asof)
+	if err != nil {
+		return nil, false
+	}
+	// The last version committed at, or before, t.
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if !m.history[i].Time.After(t) {
+			return m.history[i].Tree, true
+		}
+	}
+	return nil, false
+}
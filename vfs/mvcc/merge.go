@@ -0,0 +1,536 @@
+package mvcc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// PageDiff is the result of a three-way page-level diff.
+// Pages are numbered from 1.
+type PageDiff struct {
+	PageSize  int      // the database page size
+	Ours      []uint32 // pages changed only in ours
+	Theirs    []uint32 // pages changed only in theirs
+	Same      []uint32 // pages changed identically in both
+	Conflicts []uint32 // pages changed differently in both
+}
+
+// DiffPages compares two snapshots, ours and theirs,
+// that were forked from a common base snapshot,
+// and reports which database pages changed in each.
+//
+// Snapshots share unchanged data,
+// so DiffPages takes time proportional to the size of the changes.
+func DiffPages(base, ours, theirs Snapshot) (PageDiff, error) {
+	var size int
+	for _, s := range []Snapshot{base, ours, theirs} {
+		n, err := s.pageSize()
+		if err != nil {
+			return PageDiff{}, err
+		}
+		if size != 0 && n != 0 && size != n {
+			return PageDiff{}, errors.New("mvcc: snapshots have different page sizes")
+		}
+		size = max(size, n)
+	}
+
+	res := PageDiff{PageSize: size}
+	if size == 0 {
+		return res, nil
+	}
+
+	o := changedPages(base, ours, size)
+	t := changedPages(base, theirs, size)
+	for _, pgno := range o {
+		if _, ok := slices.BinarySearch(t, pgno); !ok {
+			res.Ours = append(res.Ours, pgno)
+		} else if bytes.Equal(
+			readPage(ours.Tree, pgno, size),
+			readPage(theirs.Tree, pgno, size)) {
+			res.Same = append(res.Same, pgno)
+		} else {
+			res.Conflicts = append(res.Conflicts, pgno)
+		}
+	}
+	for _, pgno := range t {
+		if _, ok := slices.BinarySearch(o, pgno); !ok {
+			res.Theirs = append(res.Theirs, pgno)
+		}
+	}
+	return res, nil
+}
+
+// changedPages returns the sorted numbers of the pages that differ
+// between snapshots a and b.
+func changedPages(a, b Snapshot, pageSize int) []uint32 {
+	size := int64(pageSize)
+	pages := map[uint32]struct{}{}
+	diff(a.Tree, b.Tree, func(k int64, v string, _ bool) {
+		end := max(k+int64(len(v)), k+1)
+		for p := k / size; p*size < end; p++ {
+			pages[uint32(p+1)] = struct{}{}
+		}
+	})
+
+	// Extents may be rewritten with the same contents.
+	var res []uint32
+	for _, pgno := range slices.Sorted(maps.Keys(pages)) {
+		pa := readPage(a.Tree, pgno, pageSize)
+		pb := readPage(b.Tree, pgno, pageSize)
+		if (pa == nil) != (pb == nil) || !bytes.Equal(pa, pb) {
+			res = append(res, pgno)
+		}
+	}
+	return res
+}
+
+// RowDiff is the result of a three-way row-level diff of a table.
+// Changed rows were inserted, updated, or deleted.
+type RowDiff struct {
+	Ours      []int64 // rowids of rows changed only in ours
+	Theirs    []int64 // rowids of rows changed only in theirs
+	Conflicts []int64 // rowids of rows changed differently in both
+}
+
+// DiffRows compares two snapshots, ours and theirs,
+// that were forked from a common base snapshot,
+// and reports which rows changed in each, for each table.
+//
+// Tables are compared by name.
+// Tables without changes, internal tables,
+// and WITHOUT ROWID tables are omitted.
+//
+// Only the cells of changed pages are compared,
+// so DiffRows takes time proportional to the size of the changes,
+// and of the interior pages of each table.
+func DiffRows(base, ours, theirs Snapshot) (map[string]RowDiff, error) {
+	d, err := diffRows(base, ours, theirs)
+	return d.rows, err
+}
+
+type rowDiffs struct {
+	rows         map[string]RowDiff
+	withoutRowid []string // WITHOUT ROWID tables changed in theirs
+}
+
+func diffRows(base, ours, theirs Snapshot) (res rowDiffs, err error) {
+	snapshots := []Snapshot{base, ours, theirs}
+	trees := make([]*btree, len(snapshots))
+	var size int
+	for i, s := range snapshots {
+		trees[i], err = newBtree(s)
+		if err != nil {
+			return res, err
+		}
+		if n := trees[i].pageSize; n != 0 {
+			if size != 0 && size != n {
+				return res, errors.New("mvcc: snapshots have different page sizes")
+			}
+			size = n
+		}
+	}
+
+	res.rows = map[string]RowDiff{}
+	if size == 0 {
+		return res, nil
+	}
+
+	// Find the b-tree each page belongs to, in each snapshot.
+	ids := map[string]uint32{}
+	tables := map[uint32]string{}
+	layouts := make([]*layout, len(trees))
+	for i, b := range trees {
+		roots := map[uint32]uint32{}
+		if b.pages > 0 {
+			t, x, err := b.schema()
+			if err != nil {
+				return res, err
+			}
+			for name, root := range t {
+				id := objectID(ids, "table "+name)
+				tables[id] = name
+				roots[id] = root
+			}
+			for name, root := range x {
+				roots[objectID(ids, "index "+name)] = root
+			}
+			roots[objectID(ids, "sqlite_schema")] = 1
+		}
+		layouts[i], err = b.layout(roots)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	// A page changed if its contents, or the b-tree it belongs to, changed.
+	changed := map[uint32]struct{}{}
+	for i := 1; i < len(snapshots); i++ {
+		for _, pgno := range changedPages(base, snapshots[i], size) {
+			changed[pgno] = struct{}{}
+		}
+		a, b := layouts[0].owner, layouts[i].owner
+		for pgno := 1; pgno < max(len(a), len(b)); pgno++ {
+			if pgno >= len(a) || pgno >= len(b) || a[pgno] != b[pgno] {
+				changed[uint32(pgno)] = struct{}{}
+			}
+		}
+	}
+
+	// Changed pages that belong to no b-tree should be overflow pages
+	// of cells in changed pages.
+	// Otherwise, cells in unchanged pages changed their overflow pages,
+	// and all pages must be compared.
+	var cells [3]*pageCells
+	for _, full := range []bool{false, true} {
+		complete := true
+		for i, b := range trees {
+			cells[i], err = b.cells(layouts[i], changed, full)
+			if err != nil {
+				return res, err
+			}
+			complete = complete && cells[i].complete
+		}
+		if complete {
+			break
+		}
+	}
+	full := !cells[0].complete || !cells[1].complete || !cells[2].complete
+
+	for _, id := range slices.Sorted(maps.Keys(tables)) {
+		name := tables[id]
+		if strings.HasPrefix(name, "sqlite_") {
+			continue
+		}
+
+		var rowid = true
+		for i := range trees {
+			if _, ok := layouts[i].roots[id]; ok {
+				rowid = rowid && cells[i].rowid[id]
+			}
+		}
+		if !rowid {
+			if !maps.Equal(cells[0].other[id], cells[2].other[id]) {
+				res.withoutRowid = append(res.withoutRowid, name)
+			}
+			continue
+		}
+
+		// Rows in changed pages of one snapshot
+		// may be in unchanged pages of the others.
+		var rows [3]map[int64]string
+		candidates := map[int64]struct{}{}
+		for i := range trees {
+			rows[i] = maps.Clone(cells[i].rows[id])
+			if rows[i] == nil {
+				rows[i] = map[int64]string{}
+			}
+			for rowid := range rows[i] {
+				candidates[rowid] = struct{}{}
+			}
+		}
+		for i, b := range trees {
+			root, ok := layouts[i].roots[id]
+			if !ok || full {
+				continue
+			}
+			for rowid := range candidates {
+				if _, ok := rows[i][rowid]; ok {
+					continue
+				}
+				payload, ok, err := b.find(root, rowid)
+				if err != nil {
+					return res, err
+				}
+				if ok {
+					rows[i][rowid] = payload
+				}
+			}
+		}
+
+		var diff RowDiff
+		for _, id := range slices.Sorted(maps.Keys(candidates)) {
+			b, inB := rows[0][id]
+			o, inO := rows[1][id]
+			t, inT := rows[2][id]
+			ourChange := inB != inO || b != o
+			theirChange := inB != inT || b != t
+			switch {
+			case ourChange && theirChange:
+				if inO != inT || o != t {
+					diff.Conflicts = append(diff.Conflicts, id)
+				}
+			case ourChange:
+				diff.Ours = append(diff.Ours, id)
+			case theirChange:
+				diff.Theirs = append(diff.Theirs, id)
+			}
+		}
+		if diff.Ours != nil || diff.Theirs != nil || diff.Conflicts != nil {
+			res.rows[name] = diff
+		}
+	}
+	return res, nil
+}
+
+// objectID returns the id of a schema object,
+// shared by all snapshots being compared.
+func objectID(ids map[string]uint32, name string) uint32 {
+	id, ok := ids[name]
+	if !ok {
+		id = uint32(numOwners + len(ids))
+		ids[name] = id
+	}
+	return id
+}
+
+// pageCells are the cells in the changed pages of a snapshot,
+// by b-tree id.
+type pageCells struct {
+	rows     map[uint32]map[int64]string // the rows of rowid tables
+	other    map[uint32]map[string]int   // the cells of other b-trees
+	rowid    map[uint32]bool             // whether the b-tree is a rowid table
+	complete bool                        // whether every changed page was accounted for
+}
+
+// cells reads the cells in the changed pages of each b-tree in l,
+// or in all pages, if full is true.
+func (b *btree) cells(l *layout, changed map[uint32]struct{}, full bool) (*pageCells, error) {
+	res := pageCells{
+		rows:  map[uint32]map[int64]string{},
+		other: map[uint32]map[string]int{},
+		rowid: map[uint32]bool{},
+	}
+	overflow := map[uint32]struct{}{}
+	addOverflow := func(pgno uint32) { overflow[pgno] = struct{}{} }
+
+	for id, pages := range l.pages {
+		rows := map[int64]string{}
+		other := map[string]int{}
+		for i, pgno := range pages {
+			_, ok := changed[pgno]
+			if !ok && !full && i > 0 {
+				continue
+			}
+			n, err := b.node(pgno)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				// The root page tells us the kind of b-tree.
+				res.rowid[id] = n.table
+				if !ok && !full {
+					continue
+				}
+			}
+			for _, c := range n.cells {
+				if !c.payload {
+					continue
+				}
+				payload, err := b.payload(c, addOverflow)
+				if err != nil {
+					return nil, err
+				}
+				if n.table {
+					rows[c.rowid] = payload
+				} else {
+					other[payload]++
+				}
+			}
+		}
+		res.rows[id] = rows
+		res.other[id] = other
+	}
+
+	res.complete = true
+	for pgno := range changed {
+		if pgno >= uint32(len(l.owner)) || l.owner[pgno] != ownerNone {
+			continue
+		}
+		if _, ok := overflow[pgno]; !ok {
+			res.complete = false
+			break
+		}
+	}
+	return &res, nil
+}
+
+// ConflictError is returned by [Merge] when rows were changed differently
+// in both snapshots.
+// It maps table names to the rowids of the conflicting rows.
+type ConflictError map[string][]int64
+
+func (e ConflictError) Error() string {
+	var n int
+	for _, ids := range e {
+		n += len(ids)
+	}
+	return fmt.Sprintf("mvcc: merge conflict in %d rows", n)
+}
+
+// Merge applies to ours the row changes made in theirs since base,
+// and returns the merged snapshot.
+//
+// Changes are applied through SQL, so indexes are kept up to date,
+// and constraints are checked.
+// Rows changed in theirs are updated, inserted, or deleted in ours,
+// and triggers fire as usual for those statements.
+//
+// Merge fails with a [ConflictError]
+// if any row was changed differently in both snapshots,
+// and with an error if theirs changed the schema,
+// or the contents of a WITHOUT ROWID table.
+func Merge(base, ours, theirs Snapshot) (Snapshot, error) {
+	// Check that theirs changed only rows of rowid tables.
+	same, err := sameSchema(base, theirs)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if !same {
+		return Snapshot{}, errors.New("mvcc: cannot merge schema changes")
+	}
+
+	d, err := diffRows(base, ours, theirs)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if d.withoutRowid != nil {
+		return Snapshot{}, fmt.Errorf("mvcc: cannot merge changes to WITHOUT ROWID table %q", d.withoutRowid[0])
+	}
+
+	diffs := d.rows
+	conflicts := ConflictError{}
+	for name, diff := range diffs {
+		if diff.Conflicts != nil {
+			conflicts[name] = diff.Conflicts
+		}
+	}
+	if len(conflicts) > 0 {
+		return Snapshot{}, conflicts
+	}
+
+	name := "mvcc_merge_" + rand.Text()
+	Create(name, ours)
+	defer Delete(name)
+	Create(name+"_theirs", theirs)
+	defer Delete(name + "_theirs")
+
+	err = apply(name, diffs)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return TakeSnapshot(name), nil
+}
+
+func sameSchema(a, b Snapshot) (bool, error) {
+	var rows [2]map[int64]string
+	for i, s := range []Snapshot{a, b} {
+		t, err := newBtree(s)
+		if err != nil {
+			return false, err
+		}
+		if t.pages > 0 {
+			rows[i], _, err = t.rows(1)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return maps.Equal(rows[0], rows[1]), nil
+}
+
+// apply copies rows changed in theirs to the database name.
+func apply(name string, diffs map[string]RowDiff) (err error) {
+	db, err := sqlite3.Open("file:/" + name + "?vfs=mvcc")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	err = db.Exec(`ATTACH ` + sqlite3.Quote("file:/"+name+"_theirs?vfs=mvcc") + ` AS theirs`)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	defer tx.End(&err)
+
+	for _, table := range slices.Sorted(maps.Keys(diffs)) {
+		ids := diffs[table].Theirs
+		if ids == nil {
+			continue
+		}
+
+		cols, err := columns(db, table)
+		if err != nil {
+			return err
+		}
+
+		// Rows in both snapshots are updated, so that update triggers fire,
+		// and foreign key actions on delete don't.
+		id := sqlite3.QuoteIdentifier(table)
+		var stmts [3]*sqlite3.Stmt
+		for i, sql := range []string{
+			`UPDATE main.` + id + ` SET (` + cols + `) = ` +
+				`(SELECT ` + cols + ` FROM theirs.` + id + ` WHERE rowid = ?1) ` +
+				`WHERE rowid = ?1 AND EXISTS (SELECT 1 FROM theirs.` + id + ` WHERE rowid = ?1)`,
+			`INSERT INTO main.` + id + ` (rowid, ` + cols + `) ` +
+				`SELECT rowid, ` + cols + ` FROM theirs.` + id + ` WHERE rowid = ?1 ` +
+				`AND NOT EXISTS (SELECT 1 FROM main.` + id + ` WHERE rowid = ?1)`,
+			`DELETE FROM main.` + id + ` WHERE rowid = ?1 ` +
+				`AND NOT EXISTS (SELECT 1 FROM theirs.` + id + ` WHERE rowid = ?1)`,
+		} {
+			stmts[i], _, err = db.Prepare(sql)
+			if err != nil {
+				return err
+			}
+			defer stmts[i].Close()
+		}
+
+		for _, rowid := range ids {
+			for _, stmt := range stmts {
+				if err := stmt.BindInt64(1, rowid); err != nil {
+					return err
+				}
+				if err := stmt.Exec(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// columns returns the quoted, comma separated,
+// names of the columns of a table in theirs.
+func columns(db *sqlite3.Conn, table string) (string, error) {
+	stmt, _, err := db.Prepare(`SELECT name FROM pragma_table_info(?, 'theirs')`)
+	if err != nil {
+		return "", err
+	}
+	defer stmt.Close()
+
+	err = stmt.BindText(1, table)
+	if err != nil {
+		return "", err
+	}
+
+	var cols []string
+	for stmt.Step() {
+		cols = append(cols, sqlite3.QuoteIdentifier(stmt.ColumnText(0)))
+	}
+	if err := stmt.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(cols, ", "), nil
+}
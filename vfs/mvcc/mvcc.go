@@ -55,6 +55,42 @@ func (mvccVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, er
 	}, flags | vfs.OPEN_MEMORY, nil
 }
 
+func (mvccVFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	commit := name.URIParameter("commit")
+	asof := name.URIParameter("asof")
+	if flags&vfs.OPEN_MAIN_DB == 0 || commit == "" && asof == "" {
+		return mvccVFS{}.Open(name.String(), flags)
+	}
+
+	// Historical versions are only kept for shared databases.
+	path, ok := strings.CutPrefix(name.String(), "/")
+	if !ok {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+
+	memoryMtx.Lock()
+	db := memoryDBs[path]
+	memoryMtx.Unlock()
+	if db == nil {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+
+	db.mtx.Lock()
+	data, ok := db.version(commit, asof)
+	db.mtx.Unlock()
+	if !ok {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+
+	// Open a private, read-only, copy of the version.
+	flags &^= vfs.OPEN_READWRITE | vfs.OPEN_CREATE
+	flags |= vfs.OPEN_READONLY
+	return &mvccFile{
+		mvccDB:   &mvccDB{name: path, data: data},
+		readOnly: true,
+	}, flags | vfs.OPEN_MEMORY, nil
+}
+
 func (mvccVFS) Delete(name string, dirSync bool) error {
 	return sqlite3.IOERR_DELETE_NOENT // used to delete journals
 }
@@ -68,9 +104,12 @@ func (mvccVFS) FullPathname(name string) (string, error) {
 }
 
 type mvccDB struct {
-	data   *wbt.Tree[int64, string] // +checklocks:mtx
-	owner  *mvccFile                // +checklocks:mtx
-	waiter *sync.Cond               // +checklocks:mtx
+	data    *wbt.Tree[int64, string] // +checklocks:mtx
+	owner   *mvccFile                // +checklocks:mtx
+	waiter  *sync.Cond               // +checklocks:mtx
+	history []Version                // +checklocks:mtx
+	retain  Retention                // +checklocks:mtx
+	commits uint64                   // +checklocks:mtx
 
 	name string
 	refs int // +checklocks:memoryMtx
@@ -121,6 +160,10 @@ func (m *mvccFile) ReadAt(b []byte, off int64) (n int, err error) {
 		data = m.mvccDB.data
 	}
 
+	return readAt(data, b, off)
+}
+
+func readAt(data *wbt.Tree[int64, string], b []byte, off int64) (n int, err error) {
 	for k, v := range data.AscendFloor(off) {
 		if i := k - off; i >= 0 {
 			if +i > int64(n) {
@@ -286,7 +329,7 @@ func (m *mvccFile) Unlock(lock vfs.LockLevel) error {
 	if m.owner == m {
 		m.owner = nil
 		if m.lock == vfs.LOCK_EXCLUSIVE {
-			m.mvccDB.data = m.data
+			m.commit(m.data)
 		}
 		if m.waiter != nil {
 			m.waiter.Broadcast()
@@ -313,7 +356,7 @@ func (m *mvccFile) CommitPhaseTwo() error {
 	// Modified without lock, commit changes.
 	if m.lock > vfs.LOCK_EXCLUSIVE {
 		m.mtx.Lock()
-		m.mvccDB.data = m.data
+		m.commit(m.data)
 		m.lock = vfs.LOCK_NONE
 		m.data = nil
 		m.mtx.Unlock()
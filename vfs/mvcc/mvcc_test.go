@@ -1,11 +1,16 @@
 package mvcc
 
 import (
+	"bytes"
 	_ "embed"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ncruces/go-sqlite3"
 	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/wbt"
 )
 
 //go:embed testdata/wal.db
@@ -26,3 +31,278 @@ func Test_wal(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestSnapshot_WriteDelta(t *testing.T) {
+	t.Parallel()
+	dsn := TestDB(t, NewSnapshot(walDB))
+
+	db, err := sqlite3.OpenContext(testcfg.Context(t), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE users (id INT, name VARCHAR(10))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := TakeSnapshot(dsn)
+
+	err = db.Exec(`INSERT INTO users VALUES (1, 'go'), (2, 'zig')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := TakeSnapshot(dsn)
+
+	var buf bytes.Buffer
+	if _, err := base.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.WriteDelta(&buf, base); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	restored, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wbt.Equal(restored.Tree, base.Tree) {
+		t.Error("snapshots differ")
+	}
+	restored, err = restored.ReadDelta(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wbt.Equal(restored.Tree, next.Tree) {
+		t.Error("snapshots differ")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %d trailing bytes", buf.Len())
+	}
+
+	// The delta does not apply to the wrong base.
+	var delta bytes.Buffer
+	if _, err := next.WriteDelta(&delta, base); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next.ReadDelta(&delta); !errors.Is(err, ErrMismatch) {
+		t.Errorf("got %v", err)
+	}
+
+	// Corruption is detected.
+	data[len(data)-1] ^= 0xff
+	r := bytes.NewReader(data)
+	if _, err := ReadSnapshot(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := base.ReadDelta(r); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v", err)
+	}
+
+	db2, err := sqlite3.OpenContext(testcfg.Context(t), TestDB(t, restored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	stmt, _, err := db2.Prepare(`SELECT count(*) FROM users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnInt(0); got != 2 {
+		t.Errorf("got %d", got)
+	}
+}
+
+func Test_diff(t *testing.T) {
+	// Not parallel: counts allocations.
+	var old *wbt.Tree[int64, string]
+	for i := range 1 << 16 {
+		old = old.Put(int64(i)*4096, "page")
+	}
+	new := old.Put(100*4096, "changed").Delete(200*4096).Put(1<<16*4096, "new")
+
+	var got []string
+	diff(old, new, func(k int64, v string, del bool) {
+		got = append(got, fmt.Sprintf("%d %s %v", k/4096, v, del))
+	})
+	if fmt.Sprint(got) != "[100 changed false 200 page true 65536 new false]" {
+		t.Errorf("got %v", got)
+	}
+
+	// Shared subtrees are skipped, without rebuilding either tree.
+	allocs := testing.AllocsPerRun(10, func() {
+		diff(old, new, func(int64, string, bool) {})
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocs", allocs)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+
+	exec := func(dsn string, sql string) Snapshot {
+		t.Helper()
+		db, err := sqlite3.OpenContext(ctx, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.Exec(sql); err != nil {
+			t.Fatal(err)
+		}
+		return TakeSnapshot(dsn)
+	}
+
+	base := exec(TestDB(t, Snapshot{}), `
+		CREATE TABLE t (id INTEGER PRIMARY KEY, val TEXT UNIQUE);
+		INSERT INTO t VALUES (1, 'one'), (2, 'two'), (3, 'three');
+		-- Merge must update rows, not delete and reinsert them.
+		CREATE TRIGGER t_delete BEFORE DELETE ON t WHEN old.id <> 3 BEGIN
+			SELECT RAISE(ABORT, 'deleted');
+		END;
+	`)
+	ours := exec(TestDB(t, base), `
+		UPDATE t SET val = 'uno' WHERE id = 1;
+	`)
+	theirs := exec(TestDB(t, base), `
+		UPDATE t SET val = 'dos' WHERE id = 2;
+		DELETE FROM t WHERE id = 3;
+		INSERT INTO t VALUES (10, 'ten');
+	`)
+
+	pages, err := DiffPages(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages.PageSize != 4096 || pages.Conflicts == nil {
+		t.Errorf("got %+v", pages)
+	}
+
+	rows, err := DiffRows(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(rows); got != "map[t:{[1] [2 3 10] []}]" {
+		t.Errorf("got %s", got)
+	}
+
+	merged, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlite3.OpenContext(ctx, TestDB(t, merged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, _, err := db.Prepare(`SELECT group_concat(id || val, ',') FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "1uno,2dos,10ten" {
+		t.Errorf("got %q", got)
+	}
+
+	// Both change the same row.
+	theirs = exec(TestDB(t, base), `
+		UPDATE t SET val = 'un' WHERE id = 1;
+	`)
+	_, err = Merge(base, ours, theirs)
+	var conflict ConflictError
+	if !errors.As(err, &conflict) || fmt.Sprint(conflict) != "mvcc: merge conflict in 1 rows" {
+		t.Errorf("got %v", err)
+	}
+	if ids := conflict["t"]; len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("got %v", ids)
+	}
+
+	// Theirs changes the schema.
+	theirs = exec(TestDB(t, base), `
+		CREATE INDEX t_id ON t (id, val);
+	`)
+	_, err = Merge(base, ours, theirs)
+	if err == nil {
+		t.Error("want error")
+	}
+}
+
+func TestSetRetention(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+	dsn := TestDB(t, Snapshot{})
+
+	SetRetention(dsn, Retention{Commits: 2})
+
+	db, err := sqlite3.OpenContext(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE t (id INTEGER PRIMARY KEY)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		err = db.Exec(`INSERT INTO t VALUES (NULL)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := History(dsn)
+	if len(history) != 3 {
+		t.Fatalf("got %d versions", len(history))
+	}
+
+	count := func(dsn string) int {
+		t.Helper()
+		db, err := sqlite3.OpenContext(ctx, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		stmt, _, err := db.Prepare(`SELECT count(*) FROM t`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		if err := db.Exec(`INSERT INTO t VALUES (NULL)`); !errors.Is(err, sqlite3.READONLY) {
+			t.Errorf("got %v", err)
+		}
+		return stmt.ColumnInt(0)
+	}
+
+	if got := count(fmt.Sprintf("%s&commit=%d", dsn, history[0].ID)); got != 1 {
+		t.Errorf("got %d", got)
+	}
+	if got := count(dsn + "&asof=" + history[2].Time.Format(time.RFC3339Nano)); got != 3 {
+		t.Errorf("got %d", got)
+	}
+
+	_, err = sqlite3.OpenContext(ctx, dsn+"&commit=1")
+	if !errors.Is(err, sqlite3.CANTOPEN) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package mvcc
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/ncruces/wbt"
+)
+
+// ErrCorrupt is returned when decoding a malformed snapshot or delta.
+var ErrCorrupt = errors.New("mvcc: corrupt snapshot")
+
+// ErrMismatch is returned when applying a delta to the wrong base snapshot.
+var ErrMismatch = errors.New("mvcc: delta does not apply to snapshot")
+
+const (
+	magic     = "mvcc\x00\x01"
+	kindFull  = 'F'
+	kindDelta = 'D'
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteTo writes the snapshot to w.
+// It implements [io.WriterTo].
+//
+// The encoding is compact and self-delimiting,
+// and ends with a checksum.
+// Use [ReadSnapshot] to decode it.
+func (s Snapshot) WriteTo(w io.Writer) (int64, error) {
+	e := newEncoder(w, kindFull)
+	e.uvarint(uint64(s.Len()))
+	for k, v := range s.Ascend() {
+		e.uvarint(uint64(k))
+		e.string(v)
+	}
+	return e.close()
+}
+
+// WriteDelta writes to w the changes that turn base into the snapshot.
+//
+// Snapshots taken from the same database share most of their contents,
+// and writing the delta between them takes time and space
+// proportional to the size of the changes.
+// Use [Snapshot.ReadDelta] to apply it to base.
+func (s Snapshot) WriteDelta(w io.Writer, base Snapshot) (int64, error) {
+	e := newEncoder(w, kindDelta)
+	e.uvarint(uint64(base.Len()))
+	e.uvarint(uint64(base.size()))
+
+	type change struct {
+		key int64
+		val string
+		del bool
+	}
+	var changes []change
+	diff(base.Tree, s.Tree, func(k int64, v string, del bool) {
+		changes = append(changes, change{k, v, del})
+	})
+
+	e.uvarint(uint64(len(changes)))
+	for _, c := range changes {
+		e.uvarint(uint64(c.key))
+		if c.del {
+			e.uvarint(0)
+		} else {
+			e.uvarint(uint64(len(c.val)) + 1)
+			e.bytes(c.val)
+		}
+	}
+	return e.close()
+}
+
+// ReadSnapshot reads a snapshot written by [Snapshot.WriteTo] from r.
+//
+// If r implements [io.ByteReader],
+// ReadSnapshot does not read past the end of the snapshot,
+// so multiple snapshots and deltas can be read from the same stream.
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	d, err := newDecoder(r, kindFull)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var tree *wbt.Tree[int64, string]
+	var end int64
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		k := d.int64()
+		v := d.string(d.uvarint())
+		if k < end {
+			d.fail(ErrCorrupt)
+			break
+		}
+		tree = tree.Put(k, v)
+		end = k + int64(len(v))
+	}
+	if err := d.close(); err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{tree}, nil
+}
+
+// ReadDelta reads a delta written by [Snapshot.WriteDelta] from r,
+// and applies it to the snapshot,
+// which must be the base the delta was written against.
+//
+// If r implements [io.ByteReader],
+// ReadDelta does not read past the end of the delta,
+// so multiple snapshots and deltas can be read from the same stream.
+func (s Snapshot) ReadDelta(r io.Reader) (Snapshot, error) {
+	d, err := newDecoder(r, kindDelta)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	tree := s.Tree
+	count, size := d.uvarint(), d.uvarint()
+	if d.err == nil && (count != uint64(s.Len()) || size != uint64(s.size())) {
+		d.fail(ErrMismatch)
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		k := d.int64()
+		if l := d.uvarint(); l == 0 {
+			tree = tree.Delete(k)
+		} else {
+			tree = tree.Put(k, d.string(l-1))
+		}
+	}
+	if err := d.close(); err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{tree}, nil
+}
+
+// size returns the size of the database in the snapshot.
+func (s Snapshot) size() int64 {
+	if s.Tree == nil {
+		return 0
+	}
+	last := s.Max()
+	return last.Key() + int64(len(last.Value()))
+}
+
+// diff calls fn for each extent that must be put into, or deleted from,
+// the old tree to obtain the new tree, in increasing key order.
+// Subtrees shared by both trees are skipped,
+// so diff takes time proportional to the number of changes,
+// times the height of the trees.
+func diff(old, new *wbt.Tree[int64, string], fn func(k int64, v string, del bool)) {
+	diffRange(old, new, math.MinInt64, math.MaxInt64, fn)
+}
+
+// diffRange is diff, restricted to keys from lo to hi.
+// All keys of new are in this range; those of old may not be.
+func diffRange(old, new *wbt.Tree[int64, string], lo, hi int64, fn func(k int64, v string, del bool)) {
+	// Find the subtree of old that holds all its keys in the range.
+	for old != nil {
+		if k := old.Key(); k < lo {
+			old = old.Right()
+		} else if k > hi {
+			old = old.Left()
+		} else {
+			break
+		}
+	}
+	if old == new {
+		return
+	}
+	if new == nil {
+		for k, v := range old.AscendCeil(lo) {
+			if k > hi {
+				break
+			}
+			fn(k, v, true)
+		}
+		return
+	}
+
+	k := new.Key()
+	if k > lo {
+		diffRange(old, new.Left(), lo, k-1, fn)
+	}
+	if v, ok := old.Get(k); !ok || v != new.Value() {
+		fn(k, new.Value(), false)
+	}
+	if k < hi {
+		diffRange(old, new.Right(), k+1, hi, fn)
+	}
+}
+
+type encoder struct {
+	w   io.Writer
+	h   hash.Hash32
+	buf []byte
+	n   int64
+	err error
+}
+
+func newEncoder(w io.Writer, kind byte) *encoder {
+	e := &encoder{w: w, h: crc32.New(castagnoli)}
+	e.bytes(magic)
+	e.buf = append(e.buf, kind)
+	return e
+}
+
+func (e *encoder) uvarint(i uint64) {
+	e.buf = binary.AppendUvarint(e.buf, i)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.bytes(s)
+}
+
+func (e *encoder) bytes(s string) {
+	// Write large values directly.
+	if len(s) < 4096 {
+		e.buf = append(e.buf, s...)
+		if len(e.buf) >= 32768 {
+			e.flush()
+		}
+		return
+	}
+	e.flush()
+	e.write([]byte(s))
+}
+
+func (e *encoder) flush() {
+	e.write(e.buf)
+	e.buf = e.buf[:0]
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err != nil || len(p) == 0 {
+		return
+	}
+	e.h.Write(p)
+	n, err := e.w.Write(p)
+	e.n += int64(n)
+	e.err = err
+}
+
+func (e *encoder) close() (int64, error) {
+	e.flush()
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, e.h.Sum32())
+	e.flush()
+	return e.n, e.err
+}
+
+type decoder struct {
+	r   io.Reader
+	br  io.ByteReader
+	h   hash.Hash32
+	err error
+}
+
+func newDecoder(r io.Reader, kind byte) (*decoder, error) {
+	d := &decoder{r: r, h: crc32.New(castagnoli)}
+	d.br, _ = r.(io.ByteReader)
+
+	var hdr [len(magic) + 1]byte
+	d.read(hdr[:])
+	if d.err != nil {
+		return nil, d.err
+	}
+	if string(hdr[:len(magic)]) != magic || hdr[len(magic)] != kind {
+		return nil, ErrCorrupt
+	}
+	return d, nil
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+}
+
+func (d *decoder) read(p []byte) {
+	if d.err != nil {
+		return
+	}
+	n, err := io.ReadFull(d.r, p)
+	d.h.Write(p[:n])
+	d.fail(err)
+}
+
+func (d *decoder) ReadByte() (byte, error) {
+	var b [1]byte
+	if d.br == nil {
+		d.read(b[:])
+		return b[0], d.err
+	}
+	c, err := d.br.ReadByte()
+	if err != nil {
+		d.fail(err)
+		return 0, d.err
+	}
+	b[0] = c
+	d.h.Write(b[:])
+	return c, nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	i, err := binary.ReadUvarint(d)
+	if err != nil {
+		d.fail(ErrCorrupt)
+	}
+	return i
+}
+
+func (d *decoder) int64() int64 {
+	i := d.uvarint()
+	if i > 1<<62 {
+		d.fail(ErrCorrupt)
+	}
+	return int64(i)
+}
+
+func (d *decoder) string(n uint64) string {
+	if d.err != nil {
+		return ""
+	}
+	if n > 1<<62 {
+		d.fail(ErrCorrupt)
+		return ""
+	}
+	// Don't trust n to preallocate.
+	var buf strings.Builder
+	buf.Grow(int(min(n, 1<<20)))
+	m, err := io.CopyN(&buf, hashReader{d}, int64(n))
+	if m < int64(n) && err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	d.fail(err)
+	return buf.String()
+}
+
+func (d *decoder) close() error {
+	sum := d.h.Sum32()
+	var b [4]byte
+	d.read(b[:])
+	if d.err == nil && binary.LittleEndian.Uint32(b[:]) != sum {
+		d.err = ErrCorrupt
+	}
+	return d.err
+}
+
+type hashReader struct{ d *decoder }
+
+func (r hashReader) Read(p []byte) (int, error) {
+	n, err := r.d.r.Read(p)
+	r.d.h.Write(p[:n])
+	return n, err
+}
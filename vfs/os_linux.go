@@ -91,14 +91,36 @@ func osLock(file *os.File, typ int16, start, len int64, timeout time.Duration, d
 	}
 	var err error
 	switch {
-	default:
-		err = unix.FcntlFlock(file.Fd(), unix.F_OFD_SETLK, &lock)
 	case timeout < 0:
 		err = unix.FcntlFlock(file.Fd(), unix.F_OFD_SETLKW, &lock)
+	case timeout <= time.Millisecond:
+		// Probes (e.g. EXCLUSIVE and DMS locks) try only once.
+		err = unix.FcntlFlock(file.Fd(), unix.F_OFD_SETLK, &lock)
+	default:
+		err = osLockTimeout(file, &lock, timeout)
 	}
 	return osLockErrorCode(err, def)
 }
 
+// osLockPoll acquires a lock, waiting at most timeout for it,
+// by polling with exponential backoff until the deadline.
+func osLockPoll(file *os.File, lock *unix.Flock_t, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Microsecond
+	for {
+		err := unix.FcntlFlock(file.Fd(), unix.F_OFD_SETLK, lock)
+		if err != unix.EAGAIN && err != unix.EACCES && err != unix.EINTR {
+			return err
+		}
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return err
+		}
+		time.Sleep(min(delay, timeout))
+		delay = min(2*delay, 10*time.Millisecond)
+	}
+}
+
 func osUnlock(file *os.File, start, len int64) error {
 	lock := unix.Flock_t{
 		Type:  unix.F_UNLCK,
@@ -0,0 +1,101 @@
+//go:build (amd64 || arm64 || riscv64) && !sqlite3_flock
+
+package vfs
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	_SIG_DFL    = 0
+	_SIG_IGN    = 1
+	_SA_RESTART = 0x10000000
+)
+
+// osLockTimeout acquires a lock, waiting at most timeout for it.
+//
+// Linux has no F_OFD_SETLKWTIMEOUT, so wait in F_OFD_SETLKW
+// on a locked OS thread, and interrupt the wait by sending SIGALRM
+// to that thread once the timeout expires.
+// If SIGALRM can't interrupt the wait, poll instead.
+func osLockTimeout(file *os.File, lock *unix.Flock_t, timeout time.Duration) error {
+	if !osAlarmInterrupts() {
+		return osLockPoll(file, lock, timeout)
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	pid := unix.Getpid()
+	tid := unix.Gettid()
+	deadline := time.Now().Add(timeout)
+
+	var mtx sync.Mutex
+	var done bool
+	var timer *time.Timer
+	mtx.Lock()
+	timer = time.AfterFunc(timeout, func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !done {
+			unix.Tgkill(pid, tid, unix.SIGALRM)
+			// The signal may arrive before the thread starts waiting.
+			timer.Reset(time.Millisecond)
+		}
+	})
+	mtx.Unlock()
+
+	// Never signal the thread after it's unlocked.
+	defer func() {
+		mtx.Lock()
+		defer mtx.Unlock()
+		done = true
+		timer.Stop()
+	}()
+
+	for {
+		err := unix.FcntlFlock(file.Fd(), unix.F_OFD_SETLKW, lock)
+		if err != unix.EINTR || !time.Now().Before(deadline) {
+			return err
+		}
+	}
+}
+
+var osAlarmMtx sync.Mutex
+
+// osAlarmInterrupts reports whether SIGALRM interrupts system calls.
+//
+// Go ignores SIGALRM, unless asked to deliver it with [signal.Notify],
+// but installs its handler with SA_RESTART,
+// which restarts F_OFD_SETLKW after the handler returns.
+// So, clear SA_RESTART from the handler,
+// unless SIGALRM is ignored, or its default action (exit) is set.
+//
+// This is checked for every wait, as [signal.Notify]
+// and [signal.Reset] reinstall the handler.
+func osAlarmInterrupts() bool {
+	osAlarmMtx.Lock()
+	defer osAlarmMtx.Unlock()
+
+	// The kernel's struct sigaction starts with the handler and the flags,
+	// and fits in 4 words on these architectures.
+	var act [4]uint64
+	_, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGALRM),
+		0, uintptr(unsafe.Pointer(&act)), 8, 0, 0)
+	if errno != 0 || act[0] == _SIG_DFL || act[0] == _SIG_IGN {
+		return false
+	}
+	if act[1]&_SA_RESTART == 0 {
+		return true
+	}
+	act[1] &^= _SA_RESTART
+	_, _, errno = unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGALRM),
+		uintptr(unsafe.Pointer(&act)), 0, 8, 0, 0)
+	return errno == 0
+}
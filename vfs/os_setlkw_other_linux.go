@@ -0,0 +1,14 @@
+//go:build !(amd64 || arm64 || riscv64) && !sqlite3_flock
+
+package vfs
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func osLockTimeout(file *os.File, lock *unix.Flock_t, timeout time.Duration) error {
+	return osLockPoll(file, lock, timeout)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"runtime"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -50,3 +51,67 @@ func Test_osAllocate(t *testing.T) {
 		t.Fatalf("got %d, want %d", stat.Blocks*512, 16*1024*1024)
 	}
 }
+
+func Test_osLockTimeout(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip()
+	}
+
+	name := t.TempDir() + "/file"
+	f1, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+
+	f2, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+
+	err = osWriteLock(f1, 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Times out while the lock is held.
+	start := time.Now()
+	err = osWriteLock(f2, 0, 1, 50*time.Millisecond)
+	if err != _BUSY {
+		t.Fatalf("got %v, want BUSY", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned after %v", elapsed)
+	}
+
+	// Acquires the lock once it's released.
+	time.AfterFunc(20*time.Millisecond, func() { osUnlock(f1, 0, 1) })
+	err = osWriteLock(f2, 0, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upgrades a read lock once others release theirs.
+	err = osUnlock(f2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = osReadLock(f1, 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = osReadLock(f2, 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, func() { osUnlock(f2, 0, 1) })
+	start = time.Now()
+	err = osWriteLock(f1, 0, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
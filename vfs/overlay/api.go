@@ -0,0 +1,97 @@
+// Package overlay implements a copy-on-write SQLite VFS.
+//
+// The "overlay" [vfs.VFS] opens a read-only base database,
+// and stores modified pages in a delta file,
+// reading through to the base for unmodified pages.
+// Many delta files can share the same base,
+// so each user can cheaply modify a private copy
+// of a large reference database.
+//
+// Importing package overlay registers the VFS:
+//
+//	import _ "github.com/ncruces/go-sqlite3/vfs/overlay"
+//
+// Base databases are registered with [Create],
+// and selected with the "base" [URI] parameter:
+//
+//	overlay.Create("reference", base)
+//	db, err := sqlite3.Open("file:user.db?vfs=overlay&base=reference")
+//
+// Here, "user.db" is the delta file, stored using the default VFS,
+// which also stores journals and WAL files.
+// The base must not change while delta files refer to it.
+// WAL mode requires EXCLUSIVE locking mode.
+//
+// Use [Flatten] to produce a standalone database
+// from a base and a delta file.
+//
+// [URI]: https://sqlite.org/uri.html
+package overlay
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ncruces/go-sqlite3/util/ioutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func init() {
+	vfs.Register("overlay", Wrap(vfs.Find("")))
+}
+
+var (
+	baseMtx sync.RWMutex
+	// +checklocks:baseMtx
+	baseDBs = map[string]ioutil.SizeReaderAt{}
+)
+
+// Create registers base as a read-only base database.
+// A [vfs.File] can also be used as a base.
+// The caller should ensure that data from base does not mutate,
+// otherwise SQLite might return incorrect query results and/or [sqlite3.CORRUPT] errors.
+func Create(name string, base ioutil.SizeReaderAt) {
+	baseMtx.Lock()
+	baseDBs[name] = base
+	baseMtx.Unlock()
+}
+
+// Delete unregisters a base database.
+func Delete(name string) {
+	baseMtx.Lock()
+	delete(baseDBs, name)
+	baseMtx.Unlock()
+}
+
+// Wrap wraps a VFS to create an overlay VFS.
+// The wrapped VFS stores delta files, journals and WAL files,
+// and must support opening files by name.
+func Wrap(delta vfs.VFS) vfs.VFS {
+	return &overlayVFS{delta}
+}
+
+// Flatten writes to w the database obtained by applying
+// the changes in delta to base, producing a standalone database.
+//
+// The delta file must not have a hot journal:
+// all connections to it should have been closed cleanly.
+func Flatten(w io.Writer, base, delta ioutil.SizeReaderAt) (n int64, err error) {
+	o := overlay{base: base, delta: delta}
+	if err := o.load(); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, blockSize)
+	for pos := int64(0); pos < o.size; pos += blockSize {
+		b := buf[:min(blockSize, o.size-pos)]
+		if err := o.read(b, pos); err != nil {
+			return n, err
+		}
+		m, err := w.Write(b)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,436 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/ioutil"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// The delta file starts with a header block.
+// It's followed by groups of an index block and the data blocks it maps.
+// Each 8-byte entry of an index block holds the number (plus one)
+// of the database block stored in the corresponding data block,
+// or zero if the data block is free.
+const (
+	blockSize  = 4096
+	entries    = blockSize / 8
+	headerSize = 52
+	magic      = "SQLite overlay\x00\x01"
+)
+
+// entryOffset returns the offset of the index entry for a slot.
+func entryOffset(slot int64) int64 {
+	g, i := slot/entries, slot%entries
+	return blockSize*(1+g*(entries+1)) + 8*i
+}
+
+// dataOffset returns the offset of the data block for a slot.
+func dataOffset(slot int64) int64 {
+	g, i := slot/entries, slot%entries
+	return blockSize * (1 + g*(entries+1) + 1 + i)
+}
+
+type overlayVFS struct {
+	vfs.VFS
+}
+
+func (o *overlayVFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	// notest // OpenFilename is called instead
+	if flags&vfs.OPEN_MAIN_DB != 0 {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+	return vfsutil.WrapOpen(o.VFS, name, flags)
+}
+
+func (o *overlayVFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	// Journals and WALs are not overlaid.
+	if flags&vfs.OPEN_MAIN_DB == 0 {
+		return vfsutil.WrapOpenFilename(o.VFS, name, flags)
+	}
+
+	baseMtx.RLock()
+	base, ok := baseDBs[name.URIParameter("base")]
+	baseMtx.RUnlock()
+	if !ok {
+		return nil, flags, sqlite3.CANTOPEN
+	}
+
+	file, flags, err := vfsutil.WrapOpenFilename(o.VFS, name, flags)
+	if err != nil {
+		return file, flags, err
+	}
+
+	f := &overlayFile{
+		File:    file,
+		overlay: overlay{base: base, delta: file},
+	}
+	// Check that the delta file matches the base.
+	// The index is reloaded once the file is locked.
+	if err := f.load(); err != nil {
+		file.Close()
+		return nil, flags, err
+	}
+	f.loaded = false
+	return f, flags, nil
+}
+
+// overlay reads a database from a base and a delta file.
+type overlay struct {
+	base   ioutil.SizeReaderAt
+	delta  ioutil.SizeReaderAt
+	blocks map[int64]int64 // database blocks to slots
+	free   []int64         // free slots
+	slots  int64           // number of slots
+	size   int64           // database size
+	limit  int64           // base data past this reads as zeros
+	gen    uint64          // incremented by each write transaction
+
+	baseSize int64
+	baseSum  uint32
+	loaded   bool
+}
+
+// load reads the header and index of the delta file,
+// unless they're unchanged since last loaded.
+func (o *overlay) load() error {
+	if o.blocks == nil {
+		if err := o.checksum(); err != nil {
+			return err
+		}
+	}
+
+	var hdr [headerSize]byte
+	n, err := o.delta.ReadAt(hdr[:], 0)
+	if n == 0 && (err == nil || err == io.EOF) {
+		// An empty delta file has no changes.
+		o.blocks = map[int64]int64{}
+		o.free = nil
+		o.slots = 0
+		o.size = o.baseSize
+		o.limit = o.baseSize
+		o.gen = 0
+		o.loaded = true
+		return nil
+	}
+	if n < headerSize || string(hdr[:len(magic)]) != magic {
+		return sqlite3.NOTADB
+	}
+	if int64(binary.LittleEndian.Uint64(hdr[40:])) != o.baseSize ||
+		binary.LittleEndian.Uint32(hdr[48:]) != o.baseSum {
+		return sqlite3.CANTOPEN
+	}
+
+	gen := binary.LittleEndian.Uint64(hdr[32:])
+	if o.loaded && o.gen == gen {
+		return nil
+	}
+	o.size = int64(binary.LittleEndian.Uint64(hdr[16:]))
+	o.limit = int64(binary.LittleEndian.Uint64(hdr[24:]))
+	o.gen = gen
+	if o.size < 0 || o.limit < 0 || o.limit > o.baseSize {
+		return sqlite3.CORRUPT
+	}
+
+	size, err := o.delta.Size()
+	if err != nil {
+		return err
+	}
+
+	o.blocks = map[int64]int64{}
+	o.free = nil
+	o.slots = 0
+	buf := make([]byte, blockSize)
+	for first := int64(0); entryOffset(first) < size; first += entries {
+		n, err := o.delta.ReadAt(buf, entryOffset(first))
+		if err != nil && err != io.EOF {
+			return err
+		}
+		clear(buf[n:])
+		for i := range int64(entries) {
+			e := binary.LittleEndian.Uint64(buf[8*i:])
+			if e == 0 {
+				continue
+			}
+			if e > 1<<62 {
+				return sqlite3.CORRUPT
+			}
+			o.blocks[int64(e-1)] = first + i
+			o.slots = first + i + 1
+		}
+	}
+
+	used := make([]bool, o.slots)
+	for _, slot := range o.blocks {
+		used[slot] = true
+	}
+	for slot, used := range used {
+		if !used {
+			o.free = append(o.free, int64(slot))
+		}
+	}
+	o.loaded = true
+	return nil
+}
+
+// checksum computes the size and checksum of the base,
+// which are used to check that a delta file matches it.
+func (o *overlay) checksum() error {
+	size, err := o.base.Size()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, min(blockSize, size))
+	if _, err := o.base.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return err
+	}
+	o.baseSize = size
+	o.baseSum = crc32.ChecksumIEEE(buf)
+	return nil
+}
+
+// read reads p from the database at pos, within a single block.
+func (o *overlay) read(p []byte, pos int64) error {
+	b, rest := pos/blockSize, pos%blockSize
+	if slot, ok := o.blocks[b]; ok {
+		n, err := o.delta.ReadAt(p, dataOffset(slot)+rest)
+		if err == io.EOF {
+			clear(p[n:])
+			err = nil
+		}
+		return err
+	}
+
+	clear(p)
+	if pos < o.limit {
+		n := min(int64(len(p)), o.limit-pos)
+		_, err := o.base.ReadAt(p[:n], pos)
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+type overlayFile struct {
+	vfs.File // the delta file
+	overlay
+	changed bool
+}
+
+func (f *overlayFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+		k := int(min(int64(len(p)-n), blockSize-pos%blockSize, f.size-pos))
+		if err := f.read(p[n:n+k], pos); err != nil {
+			return n, err
+		}
+		n += k
+	}
+	return n, nil
+}
+
+func (f *overlayFile) WriteAt(p []byte, off int64) (n int, err error) {
+	if err := f.begin(); err != nil {
+		return 0, err
+	}
+	for n < len(p) {
+		pos := off + int64(n)
+		rest := pos % blockSize
+		k := int(min(int64(len(p)-n), blockSize-rest))
+
+		slot, err := f.slot(pos/blockSize, k == blockSize)
+		if err != nil {
+			return n, err
+		}
+		m, err := f.File.WriteAt(p[n:n+k], dataOffset(slot)+rest)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	if end := off + int64(n); end > f.size {
+		f.size = end
+		return n, f.writeHeader()
+	}
+	return n, nil
+}
+
+// slot returns the slot that stores a database block,
+// copying the block into a new slot if needed.
+// If overwrite is true, the whole block is about to be written,
+// so it isn't copied.
+func (f *overlayFile) slot(b int64, overwrite bool) (slot int64, err error) {
+	if slot, ok := f.blocks[b]; ok {
+		return slot, nil
+	}
+
+	if n := len(f.free); n > 0 {
+		slot = f.free[n-1]
+		f.free = f.free[:n-1]
+	} else {
+		slot = f.slots
+		f.slots++
+	}
+	defer func() {
+		if err != nil {
+			f.free = append(f.free, slot)
+		}
+	}()
+
+	if !overwrite {
+		buf := make([]byte, blockSize)
+		if err := f.read(buf, b*blockSize); err != nil {
+			return 0, err
+		}
+		if _, err := f.File.WriteAt(buf, dataOffset(slot)); err != nil {
+			return 0, err
+		}
+	}
+	if err := f.writeEntry(slot, uint64(b)+1); err != nil {
+		return 0, err
+	}
+	f.blocks[b] = slot
+	return slot, nil
+}
+
+func (f *overlayFile) Truncate(size int64) error {
+	if err := f.begin(); err != nil {
+		return err
+	}
+	if size < f.size {
+		f.limit = min(f.limit, size)
+
+		// Free blocks past the end, and clear the tail of the last one.
+		for b, slot := range f.blocks {
+			switch end := size - b*blockSize; {
+			case end <= 0:
+				if err := f.writeEntry(slot, 0); err != nil {
+					return err
+				}
+				delete(f.blocks, b)
+				f.free = append(f.free, slot)
+			case end < blockSize:
+				zeros := make([]byte, blockSize-end)
+				if _, err := f.File.WriteAt(zeros, dataOffset(slot)+end); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	f.size = size
+	return f.writeHeader()
+}
+
+func (f *overlayFile) Size() (int64, error) {
+	return f.size, nil
+}
+
+func (f *overlayFile) Lock(lock vfs.LockLevel) error {
+	if err := f.File.Lock(lock); err != nil {
+		return err
+	}
+	// Other connections may have changed the delta file.
+	if lock == vfs.LOCK_SHARED {
+		if err := f.load(); err != nil {
+			f.File.Unlock(vfs.LOCK_NONE)
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *overlayFile) Unlock(lock vfs.LockLevel) error {
+	if lock < vfs.LOCK_RESERVED {
+		f.changed = false
+	}
+	return f.File.Unlock(lock)
+}
+
+// begin marks the delta file as changed, once per write transaction,
+// so that other connections reload its index.
+func (f *overlayFile) begin() error {
+	if f.changed {
+		return nil
+	}
+	f.changed = true
+	f.gen++
+	return f.writeHeader()
+}
+
+func (f *overlayFile) writeHeader() error {
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.LittleEndian.PutUint64(hdr[16:], uint64(f.size))
+	binary.LittleEndian.PutUint64(hdr[24:], uint64(f.limit))
+	binary.LittleEndian.PutUint64(hdr[32:], f.gen)
+	binary.LittleEndian.PutUint64(hdr[40:], uint64(f.baseSize))
+	binary.LittleEndian.PutUint32(hdr[48:], f.baseSum)
+	_, err := f.File.WriteAt(hdr[:], 0)
+	return err
+}
+
+func (f *overlayFile) writeEntry(slot int64, e uint64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], e)
+	_, err := f.File.WriteAt(buf[:], entryOffset(slot))
+	return err
+}
+
+func (f *overlayFile) DeviceCharacteristics() vfs.DeviceCharacteristic {
+	return f.File.DeviceCharacteristics() & (0 |
+		// These flags are safe:
+		vfs.IOCAP_SEQUENTIAL |
+		vfs.IOCAP_SUBPAGE_READ |
+		vfs.IOCAP_POWERSAFE_OVERWRITE |
+		vfs.IOCAP_UNDELETABLE_WHEN_OPEN)
+}
+
+func (f *overlayFile) SizeHint(size int64) error {
+	return nil // notest
+}
+
+// Wrap optional methods.
+
+func (f *overlayFile) Unwrap() vfs.File {
+	return f.File // notest
+}
+
+func (f *overlayFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(f.File) // notest
+}
+
+func (f *overlayFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(f.File) // notest
+}
+
+func (f *overlayFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(f.File, keepWAL) // notest
+}
+
+func (f *overlayFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(f.File) // notest
+}
+
+func (f *overlayFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(f.File) // notest
+}
+
+func (f *overlayFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(f.File) // notest
+}
+
+func (f *overlayFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(f.File, handler) // notest
+}
+
+func (f *overlayFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(f.File, name, value) // notest
+}
@@ -0,0 +1,134 @@
+package overlay_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/util/ioutil"
+	"github.com/ncruces/go-sqlite3/vfs/overlay"
+)
+
+func Test_overlay(t *testing.T) {
+	t.Parallel()
+	ctx := testcfg.Context(t)
+	dir := t.TempDir()
+
+	// Create the base database.
+	name := filepath.Join(dir, "base.db")
+	db, err := sqlite3.OpenContext(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TABLE test (col); INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 100)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	base := ioutil.NewSizeReaderAt(file)
+	overlay.Create(t.Name(), base)
+	defer overlay.Delete(t.Name())
+
+	open := func(delta string) *sqlite3.Conn {
+		t.Helper()
+		db, err := sqlite3.OpenContext(ctx, "file:"+filepath.ToSlash(filepath.Join(dir, delta))+
+			"?vfs=overlay&base="+t.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	count := func(db *sqlite3.Conn, want int) {
+		t.Helper()
+		stmt, _, err := db.Prepare(`SELECT count(*) FROM test`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if !stmt.Step() {
+			t.Fatal(stmt.Err())
+		}
+		if got := stmt.ColumnInt(0); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	}
+
+	user1 := open("user1.db")
+	defer user1.Close()
+	user2 := open("user2.db")
+	defer user2.Close()
+
+	err = user1.Exec(`INSERT INTO test SELECT randomblob(1000) FROM generate_series(1, 100)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = user2.Exec(`DELETE FROM test WHERE rowid > 10`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are private to each delta file.
+	count(user1, 200)
+	count(user2, 10)
+
+	// Another connection sees the changes.
+	again := open("user1.db")
+	count(again, 200)
+	again.Close()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user1.Close()
+
+	delta, err := os.Open(filepath.Join(dir, "user1.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer delta.Close()
+
+	// Flatten produces a standalone database.
+	var buf bytes.Buffer
+	_, err = overlay.Flatten(&buf, base, ioutil.NewSizeReaderAt(delta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(buf.Bytes(), data) {
+		t.Error("got the base")
+	}
+
+	flat := filepath.Join(dir, "flat.db")
+	err = os.WriteFile(flat, buf.Bytes(), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = sqlite3.OpenContext(ctx, flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	count(db, 200)
+
+	stmt, _, err := db.Prepare(`PRAGMA integrity_check`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if !stmt.Step() {
+		t.Fatal(stmt.Err())
+	}
+	if got := stmt.ColumnText(0); got != "ok" {
+		t.Error(got)
+	}
+}
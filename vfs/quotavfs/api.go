@@ -0,0 +1,119 @@
+// Package quotavfs wraps an SQLite VFS to enforce disk quotas.
+//
+// The [VFS] returned by [Wrap] groups files by glob pattern,
+// and tracks the total size of the files in each group.
+// Writes that would grow a group beyond its limit fail with [sqlite3.FULL],
+// unless a callback grants more space.
+//
+// Journal and WAL files are matched using the name of their
+// main database file, so they count towards the same group.
+//
+// The wrapped VFS must be registered to be used:
+//
+//	quota := quotavfs.Wrap(vfs.Find(""))
+//	quota.SetQuota("/data/tenant1/*", 1<<30, nil)
+//	vfs.Register("quota", quota)
+//
+// This is similar to SQLite's [test_quota.c].
+//
+// [test_quota.c]: https://sqlite.org/src/file/src/test_quota.c
+package quotavfs
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// VFS is a quota enforcing VFS.
+type VFS struct {
+	vfs.VFS
+
+	mtx sync.Mutex
+	// +checklocks:mtx
+	groups []*group
+}
+
+// Wrap wraps a base VFS to create a quota enforcing VFS.
+func Wrap(base vfs.VFS) *VFS {
+	return &VFS{VFS: base}
+}
+
+// Callback is called when a write would grow a group
+// beyond its limit.
+// Name is the file being written,
+// size is the total size the group would have,
+// and limit is its current limit.
+// Callback returns the new limit for the group;
+// if it's still less than size, the write fails.
+// Callback is called without holding locks,
+// so it may free space, e.g. by deleting files.
+type Callback func(name string, size, limit int64) int64
+
+type group struct {
+	pattern  string
+	limit    int64
+	size     int64
+	callback Callback
+	files    map[string]int64
+}
+
+// SetQuota creates or modifies the quota group for pattern.
+// Patterns use the [filepath.Match] syntax.
+//
+// Files that match multiple patterns belong to the first group created.
+// Only files opened after a group is created count towards its size.
+//
+// A limit of zero or less disables the limit,
+// but size continues to be tracked.
+func (v *VFS) SetQuota(pattern string, limit int64, callback Callback) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, g := range v.groups {
+		if g.pattern == pattern {
+			g.limit = limit
+			g.callback = callback
+			return nil
+		}
+	}
+	v.groups = append(v.groups, &group{
+		pattern:  pattern,
+		limit:    limit,
+		callback: callback,
+		files:    map[string]int64{},
+	})
+	return nil
+}
+
+// Usage returns the total size of the files in the quota group for pattern,
+// and its limit.
+func (v *VFS) Usage(pattern string) (size, limit int64, ok bool) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, g := range v.groups {
+		if g.pattern == pattern {
+			return g.size, g.limit, true
+		}
+	}
+	return 0, 0, false
+}
+
+// +checklocks:v.mtx
+func (v *VFS) find(name string) *group {
+	if name == "" {
+		return nil
+	}
+	for _, g := range v.groups {
+		if ok, _ := filepath.Match(g.pattern, name); ok {
+			return g
+		}
+	}
+	return nil
+}
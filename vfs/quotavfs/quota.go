@@ -0,0 +1,216 @@
+package quotavfs
+
+import (
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func (v *VFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpen(v.VFS, name, flags)
+	return v.wrapFile(name, name, file, flags, err)
+}
+
+func (v *VFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	file, flags, err := vfsutil.WrapOpenFilename(v.VFS, name, flags)
+
+	// Journals and WALs count towards their database's group.
+	match := name.String()
+	if flags&(vfs.OPEN_MAIN_JOURNAL|vfs.OPEN_WAL) != 0 {
+		if db := name.Database(); db != "" {
+			match = db
+		}
+	}
+	return v.wrapFile(name.String(), match, file, flags, err)
+}
+
+func (v *VFS) wrapFile(name, match string, file vfs.File, flags vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	if err != nil {
+		return file, flags, err
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	g := v.find(match)
+	if g == nil {
+		return file, flags, nil
+	}
+
+	size, err := file.Size()
+	if err != nil {
+		file.Close()
+		return nil, flags, err
+	}
+	g.size += size - g.files[name]
+	g.files[name] = size
+	return &quotaFile{File: file, vfs: v, group: g, name: name}, flags, nil
+}
+
+func (v *VFS) Delete(name string, syncDir bool) error {
+	err := v.VFS.Delete(name, syncDir)
+	if err == nil {
+		v.mtx.Lock()
+		for _, g := range v.groups {
+			if size, ok := g.files[name]; ok {
+				g.size -= size
+				delete(g.files, name)
+			}
+		}
+		v.mtx.Unlock()
+	}
+	return err
+}
+
+type quotaFile struct {
+	vfs.File
+	vfs   *VFS
+	group *group
+	name  string
+}
+
+// reserve accounts for growing the file to size,
+// failing if that exceeds the quota.
+func (f *quotaFile) reserve(size int64) error {
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+
+	g := f.group
+	delta := size - g.files[f.name]
+	if delta <= 0 {
+		return nil
+	}
+
+	if newSize := g.size + delta; g.limit > 0 && newSize > g.limit && g.callback != nil {
+		// The callback may free space,
+		// so call it without holding the lock.
+		callback, limit := g.callback, g.limit
+		f.vfs.mtx.Unlock()
+		limit = callback(f.name, newSize, limit)
+		f.vfs.mtx.Lock()
+		g.limit = limit
+
+		delta = size - g.files[f.name]
+		if delta <= 0 {
+			return nil
+		}
+	}
+	if g.limit > 0 && g.size+delta > g.limit {
+		return sqlite3.FULL
+	}
+	g.size += delta
+	g.files[f.name] = size
+	return nil
+}
+
+// refresh accounts for the actual size of the file,
+// after it shrinks, or an operation fails.
+func (f *quotaFile) refresh() {
+	size, err := f.File.Size()
+	if err != nil {
+		return
+	}
+	f.vfs.mtx.Lock()
+	defer f.vfs.mtx.Unlock()
+	f.group.size += size - f.group.files[f.name]
+	f.group.files[f.name] = size
+}
+
+func (f *quotaFile) WriteAt(p []byte, off int64) (n int, err error) {
+	if err := f.reserve(off + int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err = f.File.WriteAt(p, off)
+	if err != nil {
+		f.refresh()
+	}
+	return n, err
+}
+
+func (f *quotaFile) Truncate(size int64) error {
+	if err := f.reserve(size); err != nil {
+		return err
+	}
+	err := f.File.Truncate(size)
+	f.refresh()
+	return err
+}
+
+func (f *quotaFile) SizeHint(size int64) error {
+	if err := f.reserve(size); err != nil {
+		return err
+	}
+	err := vfsutil.WrapSizeHint(f.File, size)
+	f.refresh()
+	return err
+}
+
+// Wrap optional methods.
+
+func (f *quotaFile) Unwrap() vfs.File {
+	return f.File // notest
+}
+
+func (f *quotaFile) SharedMemory() vfs.SharedMemory {
+	return vfsutil.WrapSharedMemory(f.File)
+}
+
+func (f *quotaFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(f.File) // notest
+}
+
+func (f *quotaFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(f.File) // notest
+}
+
+func (f *quotaFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(f.File, keepWAL) // notest
+}
+
+func (f *quotaFile) ChunkSize(size int) {
+	vfsutil.WrapChunkSize(f.File, size) // notest
+}
+
+func (f *quotaFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(f.File) // notest
+}
+
+func (f *quotaFile) Overwrite() error {
+	return vfsutil.WrapOverwrite(f.File) // notest
+}
+
+func (f *quotaFile) SyncSuper(super string) error {
+	return vfsutil.WrapSyncSuper(f.File, super) // notest
+}
+
+func (f *quotaFile) CommitPhaseTwo() error {
+	return vfsutil.WrapCommitPhaseTwo(f.File) // notest
+}
+
+func (f *quotaFile) BeginAtomicWrite() error {
+	return vfsutil.WrapBeginAtomicWrite(f.File) // notest
+}
+
+func (f *quotaFile) CommitAtomicWrite() error {
+	return vfsutil.WrapCommitAtomicWrite(f.File) // notest
+}
+
+func (f *quotaFile) RollbackAtomicWrite() error {
+	return vfsutil.WrapRollbackAtomicWrite(f.File) // notest
+}
+
+func (f *quotaFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(f.File) // notest
+}
+
+func (f *quotaFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(f.File) // notest
+}
+
+func (f *quotaFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(f.File, handler) // notest
+}
+
+func (f *quotaFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(f.File, name, value) // notest
+}
@@ -0,0 +1,76 @@
+package quotavfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+	"github.com/ncruces/go-sqlite3/vfs/quotavfs"
+)
+
+func Test_quota(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "*")
+
+	var grant int64
+	quota := quotavfs.Wrap(vfs.Find(""))
+	err := quota.SetQuota(pattern, 64*1024, func(name string, size, limit int64) int64 {
+		// Doesn't deadlock.
+		if cur, _, _ := quota.Usage(pattern); cur >= size {
+			t.Errorf("got %d, want less than %d", cur, size)
+		}
+		return limit + grant
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vfs.Register("quota", quota)
+
+	tmp := filepath.Join(dir, "test.db")
+	db, err := sqlite3.OpenContext(testcfg.Context(t), "file:"+filepath.ToSlash(tmp)+"?vfs=quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec(`CREATE TABLE test (col)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, limit, ok := quota.Usage(pattern)
+	if !ok || size == 0 || limit != 64*1024 {
+		t.Errorf("got %d, %d, %v", size, limit, ok)
+	}
+
+	err = db.Exec(`INSERT INTO test VALUES (randomblob(100000))`)
+	if !errors.Is(err, sqlite3.FULL) {
+		t.Errorf("got %v", err)
+	}
+
+	grant = 1024 * 1024
+	err = db.Exec(`INSERT INTO test VALUES (randomblob(100000))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, limit, _ = quota.Usage(pattern)
+	if size < 100000 || limit <= 64*1024 {
+		t.Errorf("got %d, %d", size, limit)
+	}
+}
+
+func TestVFS_SetQuota(t *testing.T) {
+	quota := quotavfs.Wrap(vfs.Find(""))
+	if err := quota.SetQuota("[", 0, nil); err == nil {
+		t.Error("want error")
+	}
+	if _, _, ok := quota.Usage("*"); ok {
+		t.Error("want not ok")
+	}
+}
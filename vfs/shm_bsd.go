@@ -186,7 +186,7 @@ func (s *vfsShm) shmLock(offset, n int32, flags _ShmFlag) error {
 	defer s.Unlock()
 
 	// Check if we can obtain/release locks locally.
-	err := s.shmMemLock(offset, n, flags)
+	err := shmMemLock(&s.vfsShmParent.lock, &s.lock, offset, n, flags)
 	if err != nil {
 		return err
 	}
@@ -229,7 +229,7 @@ func (s *vfsShm) shmLock(offset, n int32, flags _ShmFlag) error {
 
 	if err != nil {
 		// Release the local locks we had acquired.
-		s.shmMemLock(offset, n, flags^(_SHM_UNLOCK|_SHM_LOCK))
+		shmMemLock(&s.vfsShmParent.lock, &s.lock, offset, n, flags^(_SHM_UNLOCK|_SHM_LOCK))
 	}
 	return err
 }
@@ -1,15 +1,9 @@
-//go:build sqlite3_dotlk
-
 package vfs
 
 import (
-	"sync/atomic"
 	"unsafe"
-)
 
-const (
-	_WALINDEX_HDR_SIZE = 136
-	_WALINDEX_PGSZ     = 32768
+	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
 )
 
 // This seems a safe way of keeping the WAL-index in sync.
@@ -30,18 +24,15 @@ const (
 //
 // https://sqlite.org/walformat.html#the_wal_index_file_format
 
-func (s *vfsShm) shmAcquire(errp *error) {
-	if errp != nil && *errp != nil {
-		return
-	}
-	if len(s.ptrs) == 0 || shmEqual(s.shadow[0][:], s.shared[0][:]) {
+func shmAcquire(wrp *sqlite3_wrap.Wrapper, ptrs []ptr_t, shared, shadow [][_WALINDEX_PGSZ]byte) {
+	if len(ptrs) == 0 || shmEqual(shadow[0][:], shared[0][:]) {
 		return
 	}
 	// Copies modified words from shared to private memory.
-	for id, p := range s.ptrs {
-		shared := shmPage(s.shared[id][:])
-		shadow := shmPage(s.shadow[id][:])
-		privat := shmPage(s.wrp.Bytes(p, _WALINDEX_PGSZ))
+	for id, p := range ptrs {
+		shared := shmPage(shared[id][:])
+		shadow := shmPage(shadow[id][:])
+		privat := shmPage(wrp.Bytes(p, _WALINDEX_PGSZ))
 		for i, shared := range shared {
 			if shadow[i] != shared {
 				shadow[i] = shared
@@ -51,15 +42,15 @@ func (s *vfsShm) shmAcquire(errp *error) {
 	}
 }
 
-func (s *vfsShm) shmRelease() {
-	if len(s.ptrs) == 0 || shmEqual(s.shadow[0][:], s.wrp.Bytes(s.ptrs[0], _WALINDEX_HDR_SIZE)) {
+func shmRelease(wrp *sqlite3_wrap.Wrapper, ptrs []ptr_t, shared, shadow [][_WALINDEX_PGSZ]byte) {
+	if len(ptrs) == 0 || shmEqual(shadow[0][:], wrp.Bytes(ptrs[0], _WALINDEX_HDR_SIZE)) {
 		return
 	}
 	// Copies modified words from private to shared memory.
-	for id, p := range s.ptrs {
-		shared := shmPage(s.shared[id][:])
-		shadow := shmPage(s.shadow[id][:])
-		privat := shmPage(s.wrp.Bytes(p, _WALINDEX_PGSZ))
+	for id, p := range ptrs {
+		shared := shmPage(shared[id][:])
+		shadow := shmPage(shadow[id][:])
+		privat := shmPage(wrp.Bytes(p, _WALINDEX_PGSZ))
 		for i, privat := range privat {
 			if shadow[i] != privat {
 				shadow[i] = privat
@@ -69,15 +60,6 @@ func (s *vfsShm) shmRelease() {
 	}
 }
 
-func (s *vfsShm) shmBarrier() {
-	var b atomic.Bool
-	s.Lock()
-	s.shmAcquire(nil)
-	b.Swap(true)
-	s.shmRelease()
-	s.Unlock()
-}
-
 //go:nosplit
 func shmPage(s []byte) *[_WALINDEX_PGSZ / 4]uint32 {
 	p := (*uint32)(unsafe.Pointer(unsafe.SliceData(s)))
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/fs"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ncruces/go-sqlite3/internal/dotlk"
 	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
@@ -147,7 +148,7 @@ func (s *vfsShm) shmLock(offset, n int32, flags _ShmFlag) (err error) {
 		s.shmRelease()
 	}
 
-	return s.shmMemLock(offset, n, flags)
+	return shmMemLock(&s.vfsShmParent.lock, &s.lock, offset, n, flags)
 }
 
 func (s *vfsShm) shmUnmap(delete bool) {
@@ -166,3 +167,25 @@ func (s *vfsShm) shmUnmap(delete bool) {
 	s.ptrs = nil
 	s.shadow = nil
 }
+
+// +checklocks:s.Mutex
+func (s *vfsShm) shmAcquire(errp *error) {
+	if errp != nil && *errp != nil {
+		return
+	}
+	shmAcquire(s.wrp, s.ptrs, s.shared, s.shadow)
+}
+
+// +checklocks:s.Mutex
+func (s *vfsShm) shmRelease() {
+	shmRelease(s.wrp, s.ptrs, s.shared, s.shadow)
+}
+
+func (s *vfsShm) shmBarrier() {
+	var b atomic.Bool
+	s.Lock()
+	s.shmAcquire(nil)
+	b.Swap(true)
+	s.shmRelease()
+	s.Unlock()
+}
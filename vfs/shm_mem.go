@@ -0,0 +1,131 @@
+package vfs
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
+)
+
+// WALIndex is a process-local shared-memory WAL-index,
+// for databases that are only accessed from this process,
+// such as in-memory databases.
+//
+// All connections to a database must share the same WALIndex,
+// each using their own [SharedMemory] returned by [WALIndex.SharedMemory].
+// The zero value is ready for use.
+type WALIndex struct {
+	shared [][_WALINDEX_PGSZ]byte // +checklocks:mtx
+	lock   [_SHM_NLOCK]int8       // +checklocks:mtx
+	mtx    sync.Mutex
+}
+
+// SharedMemory returns a shared-memory WAL-index backed by idx,
+// for a single connection to the database.
+func (idx *WALIndex) SharedMemory() SharedMemory {
+	return &memShm{WALIndex: idx}
+}
+
+type memShm struct {
+	*WALIndex
+	wrp    *sqlite3_wrap.Wrapper
+	shadow [][_WALINDEX_PGSZ]byte
+	ptrs   []ptr_t
+	lock   [_SHM_NLOCK]bool
+}
+
+func (s *memShm) Close() error {
+	// Unlock everything.
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return shmMemLock(&s.WALIndex.lock, &s.lock, 0, _SHM_NLOCK, _SHM_UNLOCK)
+}
+
+func (s *memShm) shmMap(wrp *sqlite3_wrap.Wrapper, id, size int32, extend bool) (ptr_t, error) {
+	if size != _WALINDEX_PGSZ {
+		return 0, _IOERR_SHMMAP
+	}
+	if s.wrp == nil {
+		s.wrp = wrp
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	defer s.shmAcquire(nil)
+
+	// Extend shared memory.
+	if int(id) >= len(s.shared) {
+		if !extend {
+			return 0, nil
+		}
+		s.shared = append(s.shared, make([][_WALINDEX_PGSZ]byte, int(id)-len(s.shared)+1)...)
+	}
+
+	// Allocate shadow memory.
+	if int(id) >= len(s.shadow) {
+		s.shadow = append(s.shadow, make([][_WALINDEX_PGSZ]byte, int(id)-len(s.shadow)+1)...)
+	}
+
+	// Allocate local memory.
+	for int(id) >= len(s.ptrs) {
+		ptr := wrp.Xsqlite3_malloc64(int64(size))
+		if ptr == 0 {
+			return 0, _IOERR_NOMEM
+		}
+		clear(wrp.Bytes(ptr_t(ptr), _WALINDEX_PGSZ))
+		s.ptrs = append(s.ptrs, ptr_t(ptr))
+	}
+
+	s.shadow[0][4] = 1
+	return s.ptrs[id], nil
+}
+
+func (s *memShm) shmLock(offset, n int32, flags _ShmFlag) (err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	switch {
+	case flags&_SHM_LOCK != 0:
+		defer s.shmAcquire(&err)
+	case flags&_SHM_EXCLUSIVE != 0:
+		s.shmRelease()
+	}
+	return shmMemLock(&s.WALIndex.lock, &s.lock, offset, n, flags)
+}
+
+func (s *memShm) shmUnmap(delete bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.shmRelease()
+	for _, p := range s.ptrs {
+		s.wrp.Xsqlite3_free(int32(p))
+	}
+	s.ptrs = nil
+	s.shadow = nil
+	if delete {
+		s.shared = nil
+	}
+}
+
+func (s *memShm) shmBarrier() {
+	var b atomic.Bool
+	s.mtx.Lock()
+	s.shmAcquire(nil)
+	b.Swap(true)
+	s.shmRelease()
+	s.mtx.Unlock()
+}
+
+// +checklocks:s.mtx
+func (s *memShm) shmAcquire(errp *error) {
+	if errp != nil && *errp != nil {
+		return
+	}
+	shmAcquire(s.wrp, s.ptrs, s.shared, s.shadow)
+}
+
+// +checklocks:s.mtx
+func (s *memShm) shmRelease() {
+	shmRelease(s.wrp, s.ptrs, s.shared, s.shadow)
+}
@@ -1,49 +1,49 @@
-//go:build freebsd || openbsd || netbsd || dragonfly || illumos || sqlite3_flock || sqlite3_dotlk
-
 package vfs
 
 import "github.com/ncruces/go-sqlite3/internal/errutil"
 
-// +checklocks:s.Mutex
-func (s *vfsShm) shmMemLock(offset, n int32, flags _ShmFlag) error {
+// shmMemLock obtains or releases shared-memory locks in process memory.
+// Shared counts the locks held by all connections,
+// and local the locks held by this connection.
+func shmMemLock(shared *[_SHM_NLOCK]int8, local *[_SHM_NLOCK]bool, offset, n int32, flags _ShmFlag) error {
 	switch {
 	case flags&_SHM_UNLOCK != 0:
 		for i := offset; i < offset+n; i++ {
-			if s.lock[i] {
-				if s.vfsShmParent.lock[i] <= 0 {
-					s.vfsShmParent.lock[i] = 0
+			if local[i] {
+				if shared[i] <= 0 {
+					shared[i] = 0
 				} else {
-					s.vfsShmParent.lock[i]--
+					shared[i]--
 				}
-				s.lock[i] = false
+				local[i] = false
 			}
 		}
 	case flags&_SHM_SHARED != 0:
 		for i := offset; i < offset+n; i++ {
-			if !s.lock[i] &&
-				s.vfsShmParent.lock[i]+1 <= 0 {
+			if !local[i] &&
+				shared[i]+1 <= 0 {
 				return _BUSY
 			}
 		}
 		for i := offset; i < offset+n; i++ {
-			if !s.lock[i] {
-				s.vfsShmParent.lock[i]++
-				s.lock[i] = true
+			if !local[i] {
+				shared[i]++
+				local[i] = true
 			}
 		}
 	case flags&_SHM_EXCLUSIVE != 0:
 		for i := offset; i < offset+n; i++ {
-			if s.lock[i] {
+			if local[i] {
 				// SQLite never requests an exclusive lock that it already holds.
 				panic(errutil.AssertErr())
 			}
-			if s.vfsShmParent.lock[i] != 0 {
+			if shared[i] != 0 {
 				return _BUSY
 			}
 		}
 		for i := offset; i < offset+n; i++ {
-			s.vfsShmParent.lock[i] = -1
-			s.lock[i] = true
+			shared[i] = -1
+			local[i] = true
 		}
 	default:
 		panic(errutil.AssertErr())
@@ -0,0 +1,90 @@
+package vfs
+
+import (
+	"time"
+
+	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
+)
+
+// SharedMemoryOp is an operation on a shared-memory WAL-index,
+// as observed by [ObserveSharedMemory].
+//
+// https://sqlite.org/c3ref/io_methods.html
+type SharedMemoryOp struct {
+	Name      string // "map", "lock", "unlock", "unmap" or "barrier"
+	Region    int32  // map: the region index
+	Size      int32  // map: the region size
+	Extend    bool   // map: whether to extend the WAL-index
+	Mapped    bool   // map: whether the region was mapped
+	Offset    int32  // lock, unlock: the first lock slot
+	N         int32  // lock, unlock: the number of lock slots
+	Exclusive bool   // lock, unlock: whether the lock is exclusive
+	Delete    bool   // unmap: whether to delete the WAL-index
+}
+
+// ObserveSharedMemory wraps a shared-memory WAL-index,
+// calling observe after each operation
+// with the operation and its arguments, its duration, and its result.
+// It returns nil if shm is nil.
+func ObserveSharedMemory(shm SharedMemory, observe func(op SharedMemoryOp, elapsed time.Duration, err error)) SharedMemory {
+	if shm == nil {
+		return nil
+	}
+	o := observedShm{shm, observe}
+	if _, ok := shm.(blockingSharedMemory); ok {
+		return &observedBlockingShm{o}
+	}
+	return &o
+}
+
+type observedShm struct {
+	SharedMemory
+	observe func(op SharedMemoryOp, elapsed time.Duration, err error)
+}
+
+func (s *observedShm) shmMap(wrp *sqlite3_wrap.Wrapper, id, size int32, extend bool) (ptr_t, error) {
+	start := time.Now()
+	p, err := s.SharedMemory.shmMap(wrp, id, size, extend)
+	s.observe(SharedMemoryOp{
+		Name:   "map",
+		Region: id,
+		Size:   size,
+		Extend: extend,
+		Mapped: p != 0,
+	}, time.Since(start), err)
+	return p, err
+}
+
+func (s *observedShm) shmLock(offset, n int32, flags _ShmFlag) error {
+	op := SharedMemoryOp{
+		Name:      "lock",
+		Offset:    offset,
+		N:         n,
+		Exclusive: flags&_SHM_EXCLUSIVE != 0,
+	}
+	if flags&_SHM_UNLOCK != 0 {
+		op.Name = "unlock"
+	}
+	start := time.Now()
+	err := s.SharedMemory.shmLock(offset, n, flags)
+	s.observe(op, time.Since(start), err)
+	return err
+}
+
+func (s *observedShm) shmUnmap(delete bool) {
+	start := time.Now()
+	s.SharedMemory.shmUnmap(delete)
+	s.observe(SharedMemoryOp{Name: "unmap", Delete: delete}, time.Since(start), nil)
+}
+
+func (s *observedShm) shmBarrier() {
+	start := time.Now()
+	s.SharedMemory.shmBarrier()
+	s.observe(SharedMemoryOp{Name: "barrier"}, time.Since(start), nil)
+}
+
+type observedBlockingShm struct{ observedShm }
+
+func (s *observedBlockingShm) shmEnableBlocking(timeout time.Duration) {
+	s.SharedMemory.(blockingSharedMemory).shmEnableBlocking(timeout)
+}
@@ -20,7 +20,7 @@ type vfsShm struct {
 	regions  []*sqlite3_wrap.MappedRegion
 	readOnly bool
 	fileLock bool
-	blocking bool
+	blocking time.Duration
 }
 
 var _ blockingSharedMemory = &vfsShm{}
@@ -138,10 +138,7 @@ func (s *vfsShm) shmLock(offset, n int32, flags _ShmFlag) error {
 		return _IOERR_SHMLOCK
 	}
 
-	var timeout time.Duration
-	if s.blocking {
-		timeout = time.Millisecond
-	}
+	timeout := s.blocking
 
 	switch {
 	case flags&_SHM_UNLOCK != 0:
@@ -180,6 +177,6 @@ func (s *vfsShm) shmBarrier() {
 	b.Swap(true)
 }
 
-func (s *vfsShm) shmEnableBlocking(block bool) {
-	s.blocking = block
+func (s *vfsShm) shmEnableBlocking(timeout time.Duration) {
+	s.blocking = timeout
 }
@@ -11,8 +11,6 @@ import (
 	"github.com/ncruces/go-sqlite3/internal/sqlite3_wrap"
 )
 
-const _WALINDEX_PGSZ = 32768
-
 type vfsShm struct {
 	*os.File
 	path     string
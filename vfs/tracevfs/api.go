@@ -0,0 +1,145 @@
+// Package tracevfs records and replays traces of SQLite VFS operations.
+//
+// The [VFS] returned by [Wrap] writes a compact binary log
+// of every VFS and file operation to an [io.Writer]:
+// the operation and its arguments, when it started,
+// how long it took, and its result.
+// File contents are never recorded.
+//
+// The wrapped VFS must be registered to be used:
+//
+//	trace := tracevfs.Wrap(vfs.Find(""), w)
+//	vfs.Register("trace", trace)
+//
+// A trace can be decoded with [NewReader],
+// or replayed against a registered VFS with [Replay],
+// to benchmark it under the same sequence of operations.
+// Shared-memory operations are recorded with their arguments
+// (regions, lock slots and flags), but are not replayed.
+package tracevfs
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+const magic = "SQLite VFS trace\x00"
+
+// Op identifies a traced operation.
+type Op uint8
+
+const (
+	OpOpen Op = iota
+	OpDelete
+	OpAccess
+	OpClose
+	OpRead
+	OpWrite
+	OpTruncate
+	OpSync
+	OpSize
+	OpLock
+	OpUnlock
+	OpCheckReservedLock
+	OpSizeHint
+	OpShmMap
+	OpShmLock
+	OpShmUnlock
+	OpShmUnmap
+	OpShmBarrier
+	numOps
+)
+
+var opNames = [numOps]string{
+	"open", "delete", "access", "close",
+	"read", "write", "truncate", "sync", "size",
+	"lock", "unlock", "check_reserved_lock", "size_hint",
+	"shm_map", "shm_lock", "shm_unlock", "shm_unmap", "shm_barrier",
+}
+
+// String implements [fmt.Stringer].
+func (op Op) String() string {
+	if op < numOps {
+		return opNames[op]
+	}
+	return "unknown"
+}
+
+// Record is a traced operation.
+//
+// The meaning of Flags, Offset and N depends on the operation:
+//   - open: Flags are the requested [vfs.OpenFlag], N the returned ones;
+//   - delete: Flags is 1 if the directory was synced;
+//   - access: Flags is the [vfs.AccessFlag], N is 1 if access was granted;
+//   - read, write: Offset and Len are the requested range,
+//     N is the number of bytes transferred;
+//   - truncate, size hint: Offset is the requested size;
+//   - size: Offset is the returned size;
+//   - sync: Flags is the [vfs.SyncFlag];
+//   - lock, unlock: Flags is the [vfs.LockLevel];
+//   - check reserved lock: N is 1 if a lock is held;
+//   - shm map: Offset is the region index, Len its size,
+//     Flags is 1 to extend the WAL-index, N is 1 if the region was mapped;
+//   - shm lock, shm unlock: Offset is the first lock slot,
+//     Len the number of slots, Flags is 1 if the lock is exclusive;
+//   - shm unmap: Flags is 1 if the WAL-index was deleted.
+type Record struct {
+	Op      Op
+	File    uint64        // the file handle; 0 for VFS operations
+	Start   time.Duration // when the operation started, relative to the start of the trace
+	Elapsed time.Duration // how long the operation took
+	Err     sqlite3.ExtendedErrorCode
+	Name    string // the file name of open, delete and access
+	Flags   uint32
+	Offset  int64
+	Len     int
+	N       int
+}
+
+// VFS is a tracing VFS.
+type VFS struct {
+	vfs.VFS
+	start time.Time
+	files atomic.Uint64
+
+	mtx sync.Mutex
+	// +checklocks:mtx
+	w *bufio.Writer
+	// +checklocks:mtx
+	buf []byte
+	// +checklocks:mtx
+	last time.Duration
+	// +checklocks:mtx
+	err error
+}
+
+// Wrap wraps a base VFS to record a trace of its operations to w.
+// Records are buffered: call [VFS.Flush] to write them out.
+func Wrap(base vfs.VFS, w io.Writer) *VFS {
+	v := &VFS{
+		VFS:   base,
+		start: time.Now(),
+		w:     bufio.NewWriter(w),
+	}
+	_, v.err = v.w.WriteString(magic)
+	return v
+}
+
+// Flush writes any buffered records to the underlying writer.
+// It returns the first error encountered writing the trace;
+// after an error, no further records are written.
+func (v *VFS) Flush() error {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.err == nil {
+		v.err = v.w.Flush()
+	}
+	return v.err
+}
@@ -0,0 +1,198 @@
+package tracevfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+)
+
+// ErrCorrupt is returned when a trace cannot be decoded.
+var ErrCorrupt = errors.New("tracevfs: corrupt trace")
+
+// Each record is encoded as:
+//   - the operation (1 byte);
+//   - the file handle (uvarint);
+//   - the start time, as a delta from the previous record (varint, ns);
+//   - the elapsed time (uvarint, ns);
+//   - the extended error code (uvarint);
+//   - the arguments and results of the operation (see Record).
+//
+// Strings are encoded as a uvarint length, followed by their bytes.
+
+func appendRecord(buf []byte, r *Record, last time.Duration) []byte {
+	buf = append(buf, byte(r.Op))
+	buf = binary.AppendUvarint(buf, r.File)
+	buf = binary.AppendVarint(buf, int64(r.Start-last))
+	buf = binary.AppendUvarint(buf, uint64(r.Elapsed))
+	buf = binary.AppendUvarint(buf, uint64(r.Err))
+
+	switch r.Op {
+	case OpOpen, OpAccess:
+		buf = appendString(buf, r.Name)
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+		buf = binary.AppendUvarint(buf, uint64(r.N))
+	case OpDelete:
+		buf = appendString(buf, r.Name)
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+	case OpRead, OpWrite:
+		buf = binary.AppendUvarint(buf, uint64(r.Offset))
+		buf = binary.AppendUvarint(buf, uint64(r.Len))
+		buf = binary.AppendUvarint(buf, uint64(r.N))
+	case OpTruncate, OpSize, OpSizeHint:
+		buf = binary.AppendUvarint(buf, uint64(r.Offset))
+	case OpSync, OpLock, OpUnlock:
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+	case OpCheckReservedLock:
+		buf = binary.AppendUvarint(buf, uint64(r.N))
+	case OpShmMap:
+		buf = binary.AppendUvarint(buf, uint64(r.Offset))
+		buf = binary.AppendUvarint(buf, uint64(r.Len))
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+		buf = binary.AppendUvarint(buf, uint64(r.N))
+	case OpShmLock, OpShmUnlock:
+		buf = binary.AppendUvarint(buf, uint64(r.Offset))
+		buf = binary.AppendUvarint(buf, uint64(r.Len))
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+	case OpShmUnmap:
+		buf = binary.AppendUvarint(buf, uint64(r.Flags))
+	}
+	return buf
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// Reader decodes a trace.
+type Reader struct {
+	r      *bufio.Reader
+	err    error
+	last   time.Duration
+	header bool
+}
+
+// NewReader creates a new trace decoder reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next decodes the next record of the trace.
+// It returns [io.EOF] at the end of the trace.
+func (r *Reader) Next() (rec Record, err error) {
+	if r.err != nil {
+		return rec, r.err
+	}
+	defer func() { r.err = err }()
+
+	if !r.header {
+		var hdr [len(magic)]byte
+		if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = ErrCorrupt
+			}
+			return rec, err
+		}
+		if string(hdr[:]) != magic {
+			return rec, ErrCorrupt
+		}
+		r.header = true
+	}
+
+	op, err := r.r.ReadByte()
+	if err != nil {
+		return rec, err // io.EOF
+	}
+	if rec.Op = Op(op); rec.Op >= numOps {
+		return rec, ErrCorrupt
+	}
+
+	var start int64
+	var elapsed, code, flags, offset, length, n uint64
+	r.uvarint(&rec.File)
+	r.varint(&start)
+	r.uvarint(&elapsed)
+	r.uvarint(&code)
+
+	switch rec.Op {
+	case OpOpen, OpAccess:
+		r.string(&rec.Name)
+		r.uvarint(&flags)
+		r.uvarint(&n)
+	case OpDelete:
+		r.string(&rec.Name)
+		r.uvarint(&flags)
+	case OpRead, OpWrite:
+		r.uvarint(&offset)
+		r.uvarint(&length)
+		r.uvarint(&n)
+	case OpTruncate, OpSize, OpSizeHint:
+		r.uvarint(&offset)
+	case OpSync, OpLock, OpUnlock:
+		r.uvarint(&flags)
+	case OpCheckReservedLock:
+		r.uvarint(&n)
+	case OpShmMap:
+		r.uvarint(&offset)
+		r.uvarint(&length)
+		r.uvarint(&flags)
+		r.uvarint(&n)
+	case OpShmLock, OpShmUnlock:
+		r.uvarint(&offset)
+		r.uvarint(&length)
+		r.uvarint(&flags)
+	case OpShmUnmap:
+		r.uvarint(&flags)
+	}
+	if r.err != nil {
+		return rec, r.err
+	}
+
+	r.last += time.Duration(start)
+	rec.Start = r.last
+	rec.Elapsed = time.Duration(elapsed)
+	rec.Err = sqlite3.ExtendedErrorCode(code)
+	rec.Flags = uint32(flags)
+	rec.Offset = int64(offset)
+	rec.Len = int(length)
+	rec.N = int(n)
+	return rec, nil
+}
+
+func (r *Reader) uvarint(v *uint64) {
+	if r.err == nil {
+		*v, r.err = binary.ReadUvarint(r.r)
+		r.fixEOF()
+	}
+}
+
+func (r *Reader) varint(v *int64) {
+	if r.err == nil {
+		*v, r.err = binary.ReadVarint(r.r)
+		r.fixEOF()
+	}
+}
+
+func (r *Reader) string(s *string) {
+	var n uint64
+	r.uvarint(&n)
+	if r.err == nil && n > 1<<16 {
+		r.err = ErrCorrupt
+	}
+	if r.err == nil {
+		buf := make([]byte, n)
+		_, r.err = io.ReadFull(r.r, buf)
+		r.fixEOF()
+		*s = string(buf)
+	}
+}
+
+func (r *Reader) fixEOF() {
+	if r.err == io.EOF || r.err == io.ErrUnexpectedEOF {
+		r.err = ErrCorrupt
+	}
+}
@@ -0,0 +1,213 @@
+package tracevfs
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+// ReplayOptions configure [Replay].
+type ReplayOptions struct {
+	// Rename maps the names of recorded files
+	// to the names of the files to replay against.
+	// If nil, the recorded names are used.
+	// The names of temporary files (empty) are never mapped.
+	Rename func(name string) string
+
+	// Paced replays operations no faster than they were recorded,
+	// waiting to start each operation at its recorded start time.
+	Paced bool
+}
+
+// Stats compare the recorded and replayed cost of an operation.
+type Stats struct {
+	Calls      uint64        // number of calls replayed
+	Mismatches uint64        // number of calls whose result differed from the recorded one
+	Bytes      uint64        // bytes transferred by replayed reads and writes
+	Recorded   time.Duration // total time spent when recorded
+	Replayed   time.Duration // total time spent when replayed
+}
+
+// Result are the stats of all replayed operations.
+type Result map[Op]Stats
+
+// Replay reads a trace from r, and replays its operations,
+// in the recorded order, against the VFS registered as vfsName
+// (see [vfs.Find]).
+//
+// Reads and writes transfer the same ranges as when recorded,
+// but writes store zeros, since file contents are not recorded:
+// replay against scratch files (see [ReplayOptions.Rename]).
+// Shared-memory operations are not replayed:
+// mapping a WAL-index region needs the memory of an SQLite connection,
+// and WAL-index locks can't be taken without mapping it first.
+// So, replaying a WAL trace does not reproduce its locking:
+// read the recorded shm lock operations instead.
+// Files left open by the trace are closed.
+func Replay(r io.Reader, vfsName string, opts ReplayOptions) (Result, error) {
+	target := vfs.Find(vfsName)
+	if target == nil {
+		return nil, fmt.Errorf("tracevfs: no such vfs: %q", vfsName)
+	}
+
+	p := replayer{
+		vfs:   target,
+		opts:  opts,
+		files: map[uint64]vfs.File{},
+		res:   Result{},
+		start: time.Now(),
+	}
+	defer p.close()
+
+	trace := NewReader(r)
+	for {
+		rec, err := trace.Next()
+		if err == io.EOF {
+			return p.res, nil
+		}
+		if err != nil {
+			return p.res, err
+		}
+		p.replay(&rec)
+	}
+}
+
+type replayer struct {
+	vfs   vfs.VFS
+	opts  ReplayOptions
+	files map[uint64]vfs.File
+	res   Result
+	buf   []byte
+	start time.Time
+}
+
+func (p *replayer) close() {
+	for _, f := range p.files {
+		f.Close()
+	}
+}
+
+func (p *replayer) replay(rec *Record) {
+	if rec.Op >= OpShmMap {
+		return
+	}
+
+	var file vfs.File
+	if rec.Op != OpOpen && rec.File != 0 {
+		file = p.files[rec.File]
+		if file == nil {
+			// The file was not opened (or failed to open) when replayed.
+			s := p.res[rec.Op]
+			s.Calls++
+			s.Mismatches++
+			s.Recorded += rec.Elapsed
+			p.res[rec.Op] = s
+			return
+		}
+	}
+
+	if p.opts.Paced {
+		time.Sleep(time.Until(p.start.Add(rec.Start)))
+	}
+
+	var res Record
+	start := time.Now()
+	err := p.exec(rec, file, &res)
+	elapsed := time.Since(start)
+
+	s := p.res[rec.Op]
+	s.Calls++
+	s.Recorded += rec.Elapsed
+	s.Replayed += elapsed
+	if rec.Op == OpRead || rec.Op == OpWrite {
+		s.Bytes += uint64(res.N)
+	}
+	if errorCode(err) != rec.Err || res.N != rec.N || res.Offset != rec.Offset {
+		s.Mismatches++
+	}
+	p.res[rec.Op] = s
+}
+
+func (p *replayer) exec(rec *Record, file vfs.File, res *Record) (err error) {
+	// Record the results that are compared with the recorded ones.
+	// Results that are not recorded must match the record.
+	res.N = rec.N
+	res.Offset = rec.Offset
+
+	switch rec.Op {
+	case OpOpen:
+		name := rec.Name
+		if name != "" && p.opts.Rename != nil {
+			name = p.opts.Rename(name)
+		}
+		var out vfs.OpenFlag
+		file, out, err = vfsutil.WrapOpen(p.vfs, name, vfs.OpenFlag(rec.Flags))
+		if err == nil {
+			p.files[rec.File] = file
+		}
+		res.N = int(out)
+
+	case OpDelete:
+		name := rec.Name
+		if p.opts.Rename != nil {
+			name = p.opts.Rename(name)
+		}
+		err = p.vfs.Delete(name, rec.Flags != 0)
+
+	case OpAccess:
+		name := rec.Name
+		if p.opts.Rename != nil {
+			name = p.opts.Rename(name)
+		}
+		var ok bool
+		ok, err = p.vfs.Access(name, vfs.AccessFlag(rec.Flags))
+		res.N = boolInt(ok)
+
+	case OpClose:
+		delete(p.files, rec.File)
+		err = file.Close()
+
+	case OpRead:
+		res.N, err = file.ReadAt(p.buffer(rec.Len), rec.Offset)
+
+	case OpWrite:
+		res.N, err = file.WriteAt(p.buffer(rec.Len), rec.Offset)
+
+	case OpTruncate:
+		err = file.Truncate(rec.Offset)
+
+	case OpSync:
+		err = file.Sync(vfs.SyncFlag(rec.Flags))
+
+	case OpSize:
+		res.Offset, err = file.Size()
+
+	case OpLock:
+		err = file.Lock(vfs.LockLevel(rec.Flags))
+
+	case OpUnlock:
+		err = file.Unlock(vfs.LockLevel(rec.Flags))
+
+	case OpCheckReservedLock:
+		var ok bool
+		ok, err = file.CheckReservedLock()
+		res.N = boolInt(ok)
+
+	case OpSizeHint:
+		err = vfsutil.WrapSizeHint(file, rec.Offset)
+	}
+	return err
+}
+
+// buffer returns a zeroed buffer of length n.
+func (p *replayer) buffer(n int) []byte {
+	if cap(p.buf) < n {
+		p.buf = make([]byte, n)
+	}
+	buf := p.buf[:n]
+	clear(buf)
+	return buf
+}
@@ -0,0 +1,261 @@
+package tracevfs
+
+import (
+	"io"
+	"time"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/util/vfsutil"
+	"github.com/ncruces/go-sqlite3/vfs"
+)
+
+func (v *VFS) record(r *Record, start time.Time, err error) {
+	r.Start = start.Sub(v.start)
+	r.Elapsed = time.Since(start)
+	r.Err = errorCode(err)
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	if v.err != nil {
+		return
+	}
+	v.buf = appendRecord(v.buf[:0], r, v.last)
+	v.last = r.Start
+	_, v.err = v.w.Write(v.buf)
+}
+
+func errorCode(err error) sqlite3.ExtendedErrorCode {
+	if err == io.EOF {
+		return sqlite3.IOERR_SHORT_READ
+	}
+	return vfs.ErrorCode(err, sqlite3.ExtendedErrorCode(sqlite3.IOERR))
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (v *VFS) Open(name string, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	start := time.Now()
+	file, out, err := vfsutil.WrapOpen(v.VFS, name, flags)
+	return v.wrapFile(name, start, file, flags, out, err)
+}
+
+func (v *VFS) OpenFilename(name *vfs.Filename, flags vfs.OpenFlag) (vfs.File, vfs.OpenFlag, error) {
+	start := time.Now()
+	file, out, err := vfsutil.WrapOpenFilename(v.VFS, name, flags)
+	return v.wrapFile(name.String(), start, file, flags, out, err)
+}
+
+func (v *VFS) wrapFile(name string, start time.Time, file vfs.File, flags, out vfs.OpenFlag, err error) (vfs.File, vfs.OpenFlag, error) {
+	id := v.files.Add(1)
+	v.record(&Record{Op: OpOpen, File: id, Name: name, Flags: uint32(flags), N: int(out)}, start, err)
+	if err != nil {
+		return file, out, err
+	}
+	f := &traceFile{File: file, vfs: v, id: id}
+	f.shm = vfs.ObserveSharedMemory(vfsutil.WrapSharedMemory(file), f.observeShm)
+	return f, out, nil
+}
+
+func (v *VFS) Delete(name string, syncDir bool) error {
+	start := time.Now()
+	err := v.VFS.Delete(name, syncDir)
+	v.record(&Record{Op: OpDelete, Name: name, Flags: uint32(boolInt(syncDir))}, start, err)
+	return err
+}
+
+func (v *VFS) Access(name string, flags vfs.AccessFlag) (bool, error) {
+	start := time.Now()
+	ok, err := v.VFS.Access(name, flags)
+	v.record(&Record{Op: OpAccess, Name: name, Flags: uint32(flags), N: boolInt(ok)}, start, err)
+	return ok, err
+}
+
+type traceFile struct {
+	vfs.File
+	vfs *VFS
+	id  uint64
+	shm vfs.SharedMemory
+}
+
+func (f *traceFile) record(r Record, start time.Time, err error) {
+	r.File = f.id
+	f.vfs.record(&r, start, err)
+}
+
+func (f *traceFile) observeShm(op vfs.SharedMemoryOp, elapsed time.Duration, err error) {
+	var r Record
+	switch op.Name {
+	case "map":
+		r = Record{Op: OpShmMap, Offset: int64(op.Region), Len: int(op.Size),
+			Flags: uint32(boolInt(op.Extend)), N: boolInt(op.Mapped)}
+	case "lock":
+		r = Record{Op: OpShmLock, Offset: int64(op.Offset), Len: int(op.N),
+			Flags: uint32(boolInt(op.Exclusive))}
+	case "unlock":
+		r = Record{Op: OpShmUnlock, Offset: int64(op.Offset), Len: int(op.N),
+			Flags: uint32(boolInt(op.Exclusive))}
+	case "unmap":
+		r = Record{Op: OpShmUnmap, Flags: uint32(boolInt(op.Delete))}
+	case "barrier":
+		r = Record{Op: OpShmBarrier}
+	default:
+		return
+	}
+	f.record(r, time.Now().Add(-elapsed), err)
+}
+
+func (f *traceFile) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.record(Record{Op: OpClose}, start, err)
+	return err
+}
+
+func (f *traceFile) ReadAt(p []byte, off int64) (n int, err error) {
+	start := time.Now()
+	n, err = f.File.ReadAt(p, off)
+	f.record(Record{Op: OpRead, Offset: off, Len: len(p), N: n}, start, err)
+	return n, err
+}
+
+func (f *traceFile) WriteAt(p []byte, off int64) (n int, err error) {
+	start := time.Now()
+	n, err = f.File.WriteAt(p, off)
+	f.record(Record{Op: OpWrite, Offset: off, Len: len(p), N: n}, start, err)
+	return n, err
+}
+
+func (f *traceFile) Truncate(size int64) error {
+	start := time.Now()
+	err := f.File.Truncate(size)
+	f.record(Record{Op: OpTruncate, Offset: size}, start, err)
+	return err
+}
+
+func (f *traceFile) Sync(flags vfs.SyncFlag) error {
+	start := time.Now()
+	err := f.File.Sync(flags)
+	f.record(Record{Op: OpSync, Flags: uint32(flags)}, start, err)
+	return err
+}
+
+func (f *traceFile) Size() (int64, error) {
+	start := time.Now()
+	size, err := f.File.Size()
+	f.record(Record{Op: OpSize, Offset: size}, start, err)
+	return size, err
+}
+
+func (f *traceFile) Lock(lock vfs.LockLevel) error {
+	start := time.Now()
+	err := f.File.Lock(lock)
+	f.record(Record{Op: OpLock, Flags: uint32(lock)}, start, err)
+	return err
+}
+
+func (f *traceFile) Unlock(lock vfs.LockLevel) error {
+	start := time.Now()
+	err := f.File.Unlock(lock)
+	f.record(Record{Op: OpUnlock, Flags: uint32(lock)}, start, err)
+	return err
+}
+
+func (f *traceFile) CheckReservedLock() (bool, error) {
+	start := time.Now()
+	res, err := f.File.CheckReservedLock()
+	f.record(Record{Op: OpCheckReservedLock, N: boolInt(res)}, start, err)
+	return res, err
+}
+
+func (f *traceFile) SharedMemory() vfs.SharedMemory {
+	return f.shm
+}
+
+func (f *traceFile) SizeHint(size int64) error {
+	start := time.Now()
+	err := vfsutil.WrapSizeHint(f.File, size)
+	if err != sqlite3.NOTFOUND {
+		f.record(Record{Op: OpSizeHint, Offset: size}, start, err)
+	}
+	return err
+}
+
+// Wrap optional methods.
+
+func (f *traceFile) Unwrap() vfs.File {
+	return f.File // notest
+}
+
+func (f *traceFile) LockState() vfs.LockLevel {
+	return vfsutil.WrapLockState(f.File)
+}
+
+func (f *traceFile) PersistWAL() bool {
+	return vfsutil.WrapPersistWAL(f.File) // notest
+}
+
+func (f *traceFile) SetPersistWAL(keepWAL bool) {
+	vfsutil.WrapSetPersistWAL(f.File, keepWAL) // notest
+}
+
+func (f *traceFile) PowersafeOverwrite() bool {
+	return vfsutil.WrapPowersafeOverwrite(f.File) // notest
+}
+
+func (f *traceFile) SetPowersafeOverwrite(psow bool) {
+	vfsutil.WrapSetPowersafeOverwrite(f.File, psow) // notest
+}
+
+func (f *traceFile) ChunkSize(size int) {
+	vfsutil.WrapChunkSize(f.File, size) // notest
+}
+
+func (f *traceFile) HasMoved() (bool, error) {
+	return vfsutil.WrapHasMoved(f.File) // notest
+}
+
+func (f *traceFile) Overwrite() error {
+	return vfsutil.WrapOverwrite(f.File) // notest
+}
+
+func (f *traceFile) SyncSuper(super string) error {
+	return vfsutil.WrapSyncSuper(f.File, super) // notest
+}
+
+func (f *traceFile) CommitPhaseTwo() error {
+	return vfsutil.WrapCommitPhaseTwo(f.File) // notest
+}
+
+func (f *traceFile) BeginAtomicWrite() error {
+	return vfsutil.WrapBeginAtomicWrite(f.File) // notest
+}
+
+func (f *traceFile) CommitAtomicWrite() error {
+	return vfsutil.WrapCommitAtomicWrite(f.File) // notest
+}
+
+func (f *traceFile) RollbackAtomicWrite() error {
+	return vfsutil.WrapRollbackAtomicWrite(f.File) // notest
+}
+
+func (f *traceFile) CheckpointStart() {
+	vfsutil.WrapCheckpointStart(f.File) // notest
+}
+
+func (f *traceFile) CheckpointDone() {
+	vfsutil.WrapCheckpointDone(f.File) // notest
+}
+
+func (f *traceFile) Pragma(name, value string) (string, error) {
+	return vfsutil.WrapPragma(f.File, name, value) // notest
+}
+
+func (f *traceFile) BusyHandler(handler func() bool) {
+	vfsutil.WrapBusyHandler(f.File, handler) // notest
+}
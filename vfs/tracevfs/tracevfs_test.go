@@ -0,0 +1,227 @@
+package tracevfs_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3"
+	"github.com/ncruces/go-sqlite3/internal/testcfg"
+	"github.com/ncruces/go-sqlite3/vfs"
+	"github.com/ncruces/go-sqlite3/vfs/tracevfs"
+)
+
+func Test_trace(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	trace := tracevfs.Wrap(vfs.Find(""), &buf)
+	vfs.Register("trace", trace)
+
+	dir := t.TempDir()
+	tmp := filepath.Join(dir, "test.db")
+	db, err := sqlite3.OpenContext(testcfg.Context(t), "file:"+filepath.ToSlash(tmp)+"?vfs=trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		CREATE TABLE test (col);
+		INSERT INTO test VALUES (randomblob(10000));
+		SELECT * FROM test;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = trace.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written int
+	counts := map[tracevfs.Op]int{}
+	r := tracevfs.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Op == tracevfs.OpWrite {
+			written += rec.N
+		}
+		counts[rec.Op]++
+	}
+	if counts[tracevfs.OpOpen] == 0 || counts[tracevfs.OpOpen] != counts[tracevfs.OpClose] {
+		t.Errorf("got %v", counts)
+	}
+	if counts[tracevfs.OpLock] == 0 || counts[tracevfs.OpSync] == 0 {
+		t.Errorf("got %v", counts)
+	}
+	if written < 10000 {
+		t.Errorf("got %d", written)
+	}
+
+	replay := t.TempDir()
+	res, err := tracevfs.Replay(bytes.NewReader(buf.Bytes()), "", tracevfs.ReplayOptions{
+		Rename: func(name string) string {
+			return filepath.Join(replay, filepath.Base(name))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for op, s := range res {
+		if s.Calls != uint64(counts[op]) {
+			t.Errorf("%v: got %d, want %d", op, s.Calls, counts[op])
+		}
+		if s.Mismatches != 0 {
+			t.Errorf("%v: got %+v", op, s)
+		}
+	}
+	if s := res[tracevfs.OpWrite]; s.Bytes != uint64(written) {
+		t.Errorf("got %d, want %d", s.Bytes, written)
+	}
+
+	_, err = tracevfs.Replay(bytes.NewReader(buf.Bytes()), "missing", tracevfs.ReplayOptions{})
+	if err == nil {
+		t.Error("want error")
+	}
+	_, err = tracevfs.Replay(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), "", tracevfs.ReplayOptions{
+		Rename: func(name string) string {
+			return filepath.Join(replay, filepath.Base(name))
+		},
+	})
+	if err != tracevfs.ErrCorrupt {
+		t.Errorf("got %v", err)
+	}
+}
+
+func Test_trace_busy(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	trace := tracevfs.Wrap(vfs.Find(""), &buf)
+	vfs.Register("trace_busy", trace)
+
+	dir := t.TempDir()
+	tmp := "file:" + filepath.ToSlash(filepath.Join(dir, "test.db")) + "?vfs=trace_busy"
+	db1, err := sqlite3.OpenContext(testcfg.Context(t), tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db1.Close()
+
+	db2, err := sqlite3.OpenContext(testcfg.Context(t), tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	err = db1.Exec(`CREATE TABLE test (col); BEGIN EXCLUSIVE;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db2.Exec(`SELECT * FROM test`)
+	if !errors.Is(err, sqlite3.BUSY) {
+		t.Fatalf("got %v, want BUSY", err)
+	}
+	err = trace.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var busy int
+	r := tracevfs.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Op != tracevfs.OpLock {
+			continue
+		}
+		switch rec.Err {
+		case 0:
+		case sqlite3.ExtendedErrorCode(sqlite3.BUSY):
+			busy++
+		default:
+			t.Errorf("got %+v", rec)
+		}
+	}
+	if busy == 0 {
+		t.Error("want a BUSY lock")
+	}
+}
+
+func Test_trace_wal(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	trace := tracevfs.Wrap(vfs.Find(""), &buf)
+	vfs.Register("trace_wal", trace)
+
+	dir := t.TempDir()
+	tmp := "file:" + filepath.ToSlash(filepath.Join(dir, "test.db")) + "?vfs=trace_wal"
+	db, err := sqlite3.OpenContext(testcfg.Context(t), tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec(`
+		PRAGMA journal_mode=wal;
+		CREATE TABLE test (col);
+		INSERT INTO test VALUES (1);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = trace.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var maps, exclusive int
+	r := tracevfs.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch rec.Op {
+		case tracevfs.OpShmMap:
+			if rec.Len != 32768 {
+				t.Errorf("got %+v", rec)
+			}
+			maps++
+		case tracevfs.OpShmLock, tracevfs.OpShmUnlock:
+			if rec.Len < 1 || rec.Offset < 0 || rec.Offset+int64(rec.Len) > 8 {
+				t.Errorf("got %+v", rec)
+			}
+			if rec.Op == tracevfs.OpShmLock && rec.Flags != 0 {
+				exclusive++
+			}
+		}
+	}
+	if maps == 0 || exclusive == 0 {
+		t.Errorf("got %d maps, %d exclusive locks", maps, exclusive)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"reflect"
 	"strings"
+	"time"
 	_ "unsafe"
 
 	"github.com/ncruces/go-sqlite3/internal/errutil"
@@ -297,7 +298,8 @@ func vfsFileControlImpl(wrp *sqlite3_wrap.Wrapper, file File, op _FcntlOpcode, p
 	case _FCNTL_LOCK_TIMEOUT:
 		if file, ok := file.(FileSharedMemory); ok {
 			if shm, ok := file.SharedMemory().(blockingSharedMemory); ok {
-				shm.shmEnableBlocking(mem.ReadBool(pArg))
+				ms := max(0, int32(mem.Read32(pArg)))
+				shm.shmEnableBlocking(time.Duration(ms) * time.Millisecond)
 				return _OK
 			}
 		}
@@ -388,27 +390,36 @@ func vfsFileClose(wrp *sqlite3_wrap.Wrapper, pFile ptr_t) error {
 }
 
 func vfsErrorCode(wrp *sqlite3_wrap.Wrapper, err error, code _ErrorCode) _ErrorCode {
-	var sys error
+	code, wrp.SysError = errorCode(err, code)
+	return code
+}
 
+// errorCode returns the error code for err,
+// or def if err doesn't carry one,
+// and the underlying system error, if any.
+func errorCode(err error, def _ErrorCode) (_ErrorCode, error) {
 	switch err := err.(type) {
 	case nil:
-		code = _OK
+		return _OK, nil
 	case _ErrorCode:
-		code = err
+		return err, nil
 	case sysError:
-		code = err.code
-		sys = err.error
+		return err.code, err.error
 	default:
 		switch v := reflect.ValueOf(err); v.Kind() {
 		case reflect.Uint8, reflect.Uint16:
-			code = _ErrorCode(v.Uint())
-		default:
-			sys = err
+			return _ErrorCode(v.Uint()), nil
 		}
 	}
+	return def, err
+}
 
-	wrp.SysError = sys
-	return code
+// ErrorCode returns the sqlite3.ErrorCode or sqlite3.ExtendedErrorCode
+// that SQLite gets when a VFS method returns err,
+// or def if err doesn't carry one.
+func ErrorCode[T interface{ ~uint8 | ~uint16 }](err error, def T) T {
+	code, _ := errorCode(err, _ErrorCode(def))
+	return T(code)
 }
 
 // SystemError tags an error with a given
@@ -4,6 +4,7 @@ package sqlite3
 import (
 	"context"
 	"crypto/rand"
+	"io"
 	"math/bits"
 	"time"
 	_ "unsafe"
@@ -26,9 +27,62 @@ func WithMaxMemory(ctx context.Context, max int64) context.Context {
 	return context.WithValue(ctx, configKey{}, max/65536)
 }
 
+type (
+	clockKey struct{}
+	randKey  struct{}
+)
+
+// A Clock tells the time, and sleeps.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// WithClock returns a derived context that configures
+// each SQLite connection to use clock instead of the system clock,
+// e.g. for datetime('now'), and sqlite3_sleep.
+//
+// Busy timeouts are measured using the system clock.
+func WithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// WithRandomness returns a derived context that configures
+// each SQLite connection to read randomness from r instead of [rand.Reader],
+// e.g. for random(), and randomblob().
+//
+// Connections opened with the same context share r,
+// which must be safe for concurrent use.
+// For reproducible results, use a separate context for each connection.
+func WithRandomness(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, randKey{}, r)
+}
+
+// entropy returns the clock and source of randomness configured in ctx.
+func entropy(ctx context.Context) (Clock, io.Reader) {
+	clock, ok := ctx.Value(clockKey{}).(Clock)
+	if !ok {
+		clock = systemClock{}
+	}
+	r, ok := ctx.Value(randKey{}).(io.Reader)
+	if !ok {
+		r = rand.Reader
+	}
+	return clock, r
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
 var _ sqlite3_wasm.Xenv = &env{}
 
-type env struct{ *sqlite3_wrap.Wrapper }
+type env struct {
+	*sqlite3_wrap.Wrapper
+	clock Clock
+	rand  io.Reader
+}
 
 func createWrapper(ctx context.Context) (*sqlite3_wrap.Wrapper, error) {
 	mem := &sqlite3_wrap.Memory{Max: 4096} // 256MB
@@ -40,7 +94,8 @@ func createWrapper(ctx context.Context) (*sqlite3_wrap.Wrapper, error) {
 	}
 	mem.Grow(5 /*320KB*/, mem.Max)
 
-	env := &env{&sqlite3_wrap.Wrapper{Memory: mem}}
+	env := &env{Wrapper: &sqlite3_wrap.Wrapper{Memory: mem}}
+	env.clock, env.rand = entropy(ctx)
 	env.Module = sqlite3_wasm.New(env)
 	env.X_initialize()
 	return env.Wrapper, nil
@@ -52,17 +107,17 @@ func (e *env) Xmemory() sqlite3_wasm.Memory { return e.Memory }
 
 func (e *env) Xgo_randomness(pVfs, nByte, zByte int32) int32 {
 	mem := e.Bytes(ptr_t(zByte), int64(nByte))
-	n, _ := rand.Reader.Read(mem)
+	n, _ := io.ReadFull(e.rand, mem)
 	return int32(n)
 }
 
 func (e *env) Xgo_sleep(pVfs, nMicro int32) int32 {
-	time.Sleep(time.Duration(nMicro) * time.Microsecond)
+	e.clock.Sleep(time.Duration(nMicro) * time.Microsecond)
 	return _OK
 }
 
 func (e *env) Xgo_current_time_64(pVfs, nMicro int32) int32 {
-	day, nsec := julianday.Date(time.Now())
+	day, nsec := julianday.Date(e.clock.Now())
 	msec := day*86_400_000 + nsec/1_000_000
 	e.Write64(ptr_t(nMicro), uint64(msec))
 	return int32(_OK)